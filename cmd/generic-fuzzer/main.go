@@ -53,6 +53,7 @@ func initApp() *cli.App {
 		common.SkipTraceFlag,
 		common.ThreadFlag,
 		common.LocationFlag,
+		common.WorkDirFlag,
 		engineFlag,
 		forkFlag,
 	)
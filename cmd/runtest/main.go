@@ -37,6 +37,7 @@ func initApp() *cli.App {
 	app.Flags = append(app.Flags, common.VmFlags...)
 	app.Flags = append(app.Flags, common.SkipTraceFlag)
 	app.Flags = append(app.Flags, common.ThreadFlag)
+	app.Flags = append(app.Flags, common.WorkDirFlag)
 	app.Action = startFuzzer
 	return app
 }
@@ -26,6 +26,8 @@ import (
 	"github.com/rgeraldes24/goevmlab/ui"
 )
 
+var gasCostFlag = flag.Bool("gascost", false, "Also highlight steps which differ only in the reported gasCost")
+
 func init() {
 	flag.Usage = func() {
 		fmt.Fprintln(os.Stderr, "Usage:", os.Args[0], "filename1 filename2")
@@ -74,5 +76,5 @@ func main() {
 	}
 	ui.NewDiffviewManager([]*traces.Traces{
 		trace1, trace2,
-	})
+	}, traces.CompareOptions{IgnoreGasCost: !*gasCostFlag})
 }
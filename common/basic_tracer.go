@@ -31,7 +31,29 @@ var (
 	_ tracers.Tracer = (*BasicTracer)(nil)
 )
 
-type BasicTracer struct{}
+// BasicTracer implements the tracer interface, doing nothing except for some
+// bookkeeping of the steps. Tracers which embed it and override CaptureState
+// need to call BasicTracer.CaptureState to keep the bookkeeping up to date.
+type BasicTracer struct {
+	lastOp vm.OpCode
+	depth  int
+	steps  uint64
+}
+
+// LastOp returns the op of the last step.
+func (n *BasicTracer) LastOp() vm.OpCode {
+	return n.lastOp
+}
+
+// Depth returns the call depth of the last step.
+func (n *BasicTracer) Depth() int {
+	return n.depth
+}
+
+// StepCount returns the number of steps so far.
+func (n *BasicTracer) StepCount() uint64 {
+	return n.steps
+}
 
 func (n *BasicTracer) CaptureTxStart(uint64) {}
 func (n *BasicTracer) CaptureTxEnd(uint64)   {}
@@ -41,7 +63,10 @@ func (n *BasicTracer) CaptureEnd([]byte, uint64, error) {}
 func (n *BasicTracer) CaptureEnter(vm.OpCode, common.Address, common.Address, []byte, uint64, *big.Int) {
 }
 func (n *BasicTracer) CaptureExit([]byte, uint64, error) {}
-func (n *BasicTracer) CaptureState(uint64, vm.OpCode, uint64, uint64, *vm.ScopeContext, []byte, int, error) {
+func (n *BasicTracer) CaptureState(pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, rData []byte, depth int, err error) {
+	n.lastOp = op
+	n.depth = depth
+	n.steps++
 }
 func (n *BasicTracer) CaptureFault(pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, depth int, err error) {
 	fmt.Printf("CaptureFault %v\n", err)
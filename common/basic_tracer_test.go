@@ -0,0 +1,82 @@
+// Copyright 2022 Martin Holst Swende
+// This file is part of the go-evmlab library.
+//
+// The library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the goevmlab library. If not, see <http://www.gnu.org/licenses/>.
+
+package common
+
+import (
+	"testing"
+
+	"github.com/rgeraldes24/goevmlab/ops"
+	"github.com/rgeraldes24/goevmlab/program"
+	"github.com/theQRL/go-zond/common"
+	"github.com/theQRL/go-zond/core/rawdb"
+	"github.com/theQRL/go-zond/core/state"
+	"github.com/theQRL/go-zond/core/vm"
+	"github.com/theQRL/go-zond/core/vm/runtime"
+)
+
+// depthTracer embeds BasicTracer, and records the maximum depth.
+type depthTracer struct {
+	BasicTracer
+	maxDepth int
+}
+
+func (t *depthTracer) CaptureState(pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, rData []byte, depth int, err error) {
+	t.BasicTracer.CaptureState(pc, op, gas, cost, scope, rData, depth, err)
+	if t.Depth() > t.maxDepth {
+		t.maxDepth = t.Depth()
+	}
+}
+
+func TestBasicTracerBookkeeping(t *testing.T) {
+	var (
+		a          = common.HexToAddress("0xaa")
+		b          = common.HexToAddress("0xbb")
+		statedb, _ = state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	)
+	// 8 steps, the call, then 2 more steps
+	outer := program.NewProgram()
+	outer.Call(nil, b, 0, 0, 0, 0, 0)
+	outer.Op(ops.POP)
+	outer.Op(ops.STOP)
+	// 3 steps in the callee
+	inner := program.NewProgram()
+	inner.Push(1)
+	inner.Op(ops.POP)
+	inner.Op(ops.STOP)
+	statedb.CreateAccount(a)
+	statedb.SetCode(a, outer.Bytecode())
+	statedb.CreateAccount(b)
+	statedb.SetCode(b, inner.Bytecode())
+
+	tracer := new(depthTracer)
+	cfg := &runtime.Config{State: statedb, EVMConfig: vm.Config{Tracer: tracer}}
+	if _, _, err := runtime.Call(a, nil, cfg); err != nil {
+		t.Fatal(err)
+	}
+	if have, want := tracer.StepCount(), uint64(13); have != want {
+		t.Errorf("steps: got %d expected %d", have, want)
+	}
+	if have, want := tracer.maxDepth, 2; have != want {
+		t.Errorf("max depth: got %d expected %d", have, want)
+	}
+	if have, want := tracer.Depth(), 1; have != want {
+		t.Errorf("final depth: got %d expected %d", have, want)
+	}
+	if have, want := tracer.LastOp(), vm.STOP; have != want {
+		t.Errorf("last op: got %v expected %v", have, want)
+	}
+}
@@ -0,0 +1,43 @@
+// Copyright 2024 Martin Holst Swende
+// This file is part of the goevmlab library.
+//
+// The library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the goevmlab library. If not, see <http://www.gnu.org/licenses/>.
+
+package common
+
+import (
+	"math/big"
+
+	"github.com/theQRL/go-zond/params"
+	"github.com/theQRL/go-zond/tests"
+)
+
+// ChainConfigFor returns a chain config with all forks up to and including
+// the given fork activated at genesis, or nil if the fork is not known.
+// The configs are the same as the ones used for statetests, see tests.Forks.
+// Since Shanghai is the first fork of zond, it is currently the only one.
+func ChainConfigFor(fork string) *params.ChainConfig {
+	config, ok := tests.Forks[fork]
+	if !ok {
+		return nil
+	}
+	// Return a copy, so the caller can modify it
+	cpy := *config
+	cpy.ChainID = new(big.Int).Set(config.ChainID)
+	if config.ShanghaiTime != nil {
+		t := *config.ShanghaiTime
+		cpy.ShanghaiTime = &t
+	}
+	return &cpy
+}
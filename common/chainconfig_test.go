@@ -0,0 +1,46 @@
+// Copyright 2024 Martin Holst Swende
+// This file is part of the goevmlab library.
+//
+// The library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the goevmlab library. If not, see <http://www.gnu.org/licenses/>.
+
+package common
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestChainConfigFor(t *testing.T) {
+	config := ChainConfigFor("Shanghai")
+	if config == nil {
+		t.Fatal("expected config")
+	}
+	if config.ShanghaiTime == nil || *config.ShanghaiTime != 0 {
+		t.Errorf("expected Shanghai to be active at genesis")
+	}
+	if config.ChainID.Cmp(big.NewInt(1)) != 0 {
+		t.Errorf("got chain id %v expected 1", config.ChainID)
+	}
+	// Modifying the config should not affect later configs
+	*config.ShanghaiTime = 100
+	if again := ChainConfigFor("Shanghai"); *again.ShanghaiTime != 0 {
+		t.Errorf("got shanghai time %d expected 0", *again.ShanghaiTime)
+	}
+	// Unknown forks
+	for _, fork := range []string{"Cancun", "Prague", ""} {
+		if config := ChainConfigFor(fork); config != nil {
+			t.Errorf("fork %q: got %v expected nil", fork, config)
+		}
+	}
+}
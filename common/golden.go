@@ -0,0 +1,80 @@
+// Copyright 2024 Martin Holst Swende
+// This file is part of the goevmlab library.
+//
+// The library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the goevmlab library. If not, see <http://www.gnu.org/licenses/>.
+
+package common
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	"github.com/rgeraldes24/goevmlab/evms"
+	"github.com/theQRL/go-zond/core/vm/runtime"
+	"github.com/theQRL/go-zond/zond/tracers/logger"
+)
+
+// UpdateGoldenEnvVar is the environment variable which, if set, makes
+// AssertGolden write the golden files instead of comparing against them.
+const UpdateGoldenEnvVar = "GOEVMLAB_UPDATE_GOLDEN"
+
+// RecordTrace executes the code in-process, with the given config (which may
+// be nil), and returns the normalized trace. The trace only depends on the
+// code and the config, so it can be used for regression testing.
+func RecordTrace(code []byte, cfg *runtime.Config) []byte {
+	var c runtime.Config
+	if cfg != nil {
+		c = *cfg
+	}
+	tracer := logger.NewStructLogger(&logger.Config{})
+	c.EVMConfig.Tracer = tracer
+	// Execution errors are part of the trace
+	_, _, _ = runtime.Execute(code, nil, &c)
+	var out bytes.Buffer
+	for _, log := range tracer.StructLogs() {
+		out.Write(evms.FastMarshal(&log))
+		out.WriteByte('\n')
+	}
+	return out.Bytes()
+}
+
+// TestingT is the subset of testing.TB used by AssertGolden, so that the
+// testing package is not imported outside of tests.
+type TestingT interface {
+	Helper()
+	Fatal(args ...any)
+	Errorf(format string, args ...any)
+}
+
+// AssertGolden records the trace of the code (see RecordTrace), and fails the
+// test unless it equals the content of the golden file. If the environment
+// variable GOEVMLAB_UPDATE_GOLDEN is set, the golden file is written instead.
+func AssertGolden(t TestingT, code []byte, cfg *runtime.Config, goldenPath string) {
+	t.Helper()
+	trace := RecordTrace(code, cfg)
+	if os.Getenv(UpdateGoldenEnvVar) != "" {
+		if err := os.WriteFile(goldenPath, trace, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(goldenPath)
+	if err != nil {
+		t.Fatal(fmt.Sprintf("reading golden file (set %v to create it): %v", UpdateGoldenEnvVar, err))
+	}
+	if !bytes.Equal(trace, want) {
+		t.Errorf("trace differs from %v (set %v to update)\ngot:\n%s\nexpected:\n%s", goldenPath, UpdateGoldenEnvVar, trace, want)
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright 2024 Martin Holst Swende
+// This file is part of the goevmlab library.
+//
+// The library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the goevmlab library. If not, see <http://www.gnu.org/licenses/>.
+
+package common
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rgeraldes24/goevmlab/ops"
+	"github.com/rgeraldes24/goevmlab/program"
+)
+
+// goldenProgram stores a value, calls an empty account, and returns the
+// stored value.
+func goldenProgram() []byte {
+	b := program.NewProgram()
+	b.Sstore(1, 0x42)
+	b.Call(nil, 0xdead, 0, 0, 0, 0, 0)
+	b.Op(ops.POP)
+	b.Push(1)
+	b.Op(ops.SLOAD)
+	b.Push(0)
+	b.Op(ops.MSTORE)
+	b.Return(0, 32)
+	return b.Bytecode()
+}
+
+func TestGoldenTrace(t *testing.T) {
+	code := goldenProgram()
+	trace := RecordTrace(code, nil)
+	if len(trace) == 0 {
+		t.Fatal("empty trace")
+	}
+	for i := 0; i < 3; i++ {
+		if again := RecordTrace(code, nil); !bytes.Equal(trace, again) {
+			t.Fatalf("run %d: trace differs:\n%s\n%s", i, trace, again)
+		}
+	}
+	AssertGolden(t, code, nil, filepath.Join("testdata", "golden_b.jsonl"))
+
+	// In update-mode, the golden file is written
+	path := filepath.Join(t.TempDir(), "golden.jsonl")
+	t.Setenv(UpdateGoldenEnvVar, "1")
+	AssertGolden(t, code, nil, path)
+	if have, err := os.ReadFile(path); err != nil || !bytes.Equal(have, trace) {
+		t.Errorf("golden file not written: %v", err)
+	}
+}
+
+// recordingT is a TestingT which records failures, instead of failing.
+type recordingT struct{ failed bool }
+
+func (t *recordingT) Helper()               {}
+func (t *recordingT) Fatal(args ...any)     { t.failed = true }
+func (t *recordingT) Errorf(string, ...any) { t.failed = true }
+
+func TestGoldenMismatch(t *testing.T) {
+	rt := new(recordingT)
+	AssertGolden(rt, []byte{byte(ops.STOP)}, nil, filepath.Join("testdata", "golden_b.jsonl"))
+	if !rt.failed {
+		t.Errorf("expected a differing trace to fail")
+	}
+	rt = new(recordingT)
+	AssertGolden(rt, goldenProgram(), nil, filepath.Join(t.TempDir(), "missing.jsonl"))
+	if !rt.failed {
+		t.Errorf("expected a missing golden file to fail")
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright 2024 Martin Holst Swende
+// This file is part of the goevmlab library.
+//
+// The library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the goevmlab library. If not, see <http://www.gnu.org/licenses/>.
+
+package common
+
+import (
+	"encoding/json"
+
+	"github.com/theQRL/go-zond/core/vm"
+	"github.com/theQRL/go-zond/zond/tracers"
+)
+
+var (
+	// compile time type check
+	_ tracers.Tracer = (*MemGrowthTracer)(nil)
+)
+
+// MemGrowth is a memory expansion, caused by the op at Pc.
+type MemGrowth struct {
+	Pc    uint64    `json:"pc"`
+	Op    vm.OpCode `json:"op"`
+	Depth int       `json:"depth"`
+	Size  int       `json:"size"` // The new memory size
+}
+
+// MemGrowthTracer records each step which caused the memory to grow.
+// Since the tracer sees the memory before each step, the growth is detected
+// at the following step in the same call frame. Expansion done by the last
+// op of a frame (e.g. RETURN) is thus not recorded.
+type MemGrowthTracer struct {
+	BasicTracer
+	Events []MemGrowth
+
+	frames []memFrame
+}
+
+type memFrame struct {
+	pc   uint64
+	op   vm.OpCode
+	size int
+}
+
+func (t *MemGrowthTracer) CaptureState(pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, rData []byte, depth int, err error) {
+	t.BasicTracer.CaptureState(pc, op, gas, cost, scope, rData, depth, err)
+	// Adjust the frames to the current depth
+	for len(t.frames) > depth {
+		t.frames = t.frames[:len(t.frames)-1]
+	}
+	size := scope.Memory.Len()
+	if len(t.frames) < depth {
+		t.frames = append(t.frames, memFrame{pc, op, size})
+		return
+	}
+	frame := &t.frames[depth-1]
+	if size > frame.size {
+		t.Events = append(t.Events, MemGrowth{frame.pc, frame.op, depth, size})
+	}
+	*frame = memFrame{pc, op, size}
+}
+
+func (t *MemGrowthTracer) GetResult() (json.RawMessage, error) {
+	return json.Marshal(t.Events)
+}
@@ -0,0 +1,60 @@
+// Copyright 2024 Martin Holst Swende
+// This file is part of the goevmlab library.
+//
+// The library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the goevmlab library. If not, see <http://www.gnu.org/licenses/>.
+
+package common
+
+import (
+	"testing"
+
+	"github.com/rgeraldes24/goevmlab/ops"
+	"github.com/rgeraldes24/goevmlab/program"
+	"github.com/theQRL/go-zond/core/vm"
+	"github.com/theQRL/go-zond/core/vm/runtime"
+)
+
+func TestMemGrowthTracer(t *testing.T) {
+	p := program.NewProgram()
+	p.Push(1)
+	p.Push(0x1000)
+	p.Op(ops.MSTORE) // Expands memory to 0x1020
+	p.Push(2)
+	p.Push(0x20)
+	p.Op(ops.MSTORE) // No expansion
+	p.Op(ops.STOP)
+
+	tracer := new(MemGrowthTracer)
+	cfg := &runtime.Config{EVMConfig: vm.Config{Tracer: tracer}}
+	if _, _, err := runtime.Execute(p.Bytecode(), nil, cfg); err != nil {
+		t.Fatal(err)
+	}
+	if len(tracer.Events) != 1 {
+		t.Fatalf("expected 1 event, got %v", tracer.Events)
+	}
+	want := MemGrowth{Pc: 5, Op: vm.MSTORE, Depth: 1, Size: 0x1020}
+	if have := tracer.Events[0]; have != want {
+		t.Errorf("got %+v expected %+v", have, want)
+	}
+	// The bookkeeping of the embedded BasicTracer is kept up to date
+	if have, want := tracer.StepCount(), uint64(7); have != want {
+		t.Errorf("steps: got %d expected %d", have, want)
+	}
+	if have, want := tracer.LastOp(), vm.STOP; have != want {
+		t.Errorf("last op: got %v expected %v", have, want)
+	}
+	if have, want := tracer.Depth(), 1; have != want {
+		t.Errorf("depth: got %d expected %d", have, want)
+	}
+}
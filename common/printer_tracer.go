@@ -39,6 +39,7 @@ func (n *PrintingTracer) CaptureStart(vm *vm.EVM, from, to common.Address, creat
 	fmt.Printf("Start: from %x to %x, value: %#x\n", from, to, value)
 }
 func (n *PrintingTracer) CaptureState(pc uint64, op vm.OpCode, gas uint64, cost uint64, scope *vm.ScopeContext, input []byte, depth int, err error) {
+	n.BasicTracer.CaptureState(pc, op, gas, cost, scope, input, depth, err)
 	var st []string
 	for _, elem := range scope.Stack.Data() {
 		st = append(st, elem.Hex())
@@ -0,0 +1,113 @@
+// Copyright 2024 Martin Holst Swende
+// This file is part of the goevmlab library.
+//
+// The library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the goevmlab library. If not, see <http://www.gnu.org/licenses/>.
+
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/theQRL/go-zond/common"
+	"github.com/theQRL/go-zond/core/vm"
+	"github.com/theQRL/go-zond/zond/tracers"
+)
+
+var (
+	// compile time type check
+	_ tracers.Tracer = (*RevertTracer)(nil)
+)
+
+// Frame is an active call frame: the executing contract, and the current op.
+type Frame struct {
+	Address common.Address `json:"address"`
+	Pc      uint64         `json:"pc"`
+	Op      vm.OpCode      `json:"op"`
+}
+
+// RevertTrace is the chain of call frames which were active when a frame
+// failed, outermost first.
+type RevertTrace struct {
+	Error  string  `json:"error"`
+	Frames []Frame `json:"frames"`
+}
+
+// String formats the trace like a stack trace, innermost frame first.
+func (t RevertTrace) String() string {
+	var b strings.Builder
+	b.WriteString(t.Error)
+	for i := len(t.Frames) - 1; i >= 0; i-- {
+		f := t.Frames[i]
+		fmt.Fprintf(&b, "\n\tat %v pc %d (%v)", f.Address, f.Pc, f.Op)
+	}
+	return b.String()
+}
+
+// RevertTracer records the chain of active call frames each time a frame
+// ends with a REVERT or an exceptional halt. A failure which propagates, i.e.
+// where the caller also fails, is recorded once per failing frame.
+type RevertTracer struct {
+	BasicTracer
+	Traces []RevertTrace
+
+	frames []Frame
+}
+
+func (t *RevertTracer) CaptureStart(env *vm.EVM, from, to common.Address, create bool, input []byte, gas uint64, value *big.Int) {
+	t.frames = append(t.frames[:0], Frame{Address: to})
+}
+
+func (t *RevertTracer) CaptureEnter(typ vm.OpCode, from, to common.Address, input []byte, gas uint64, value *big.Int) {
+	t.frames = append(t.frames, Frame{Address: to})
+}
+
+func (t *RevertTracer) CaptureState(pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, rData []byte, depth int, err error) {
+	t.BasicTracer.CaptureState(pc, op, gas, cost, scope, rData, depth, err)
+	if depth > 0 && depth <= len(t.frames) {
+		t.frames[depth-1].Pc = pc
+		t.frames[depth-1].Op = op
+	}
+}
+
+func (t *RevertTracer) CaptureFault(pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, depth int, err error) {
+}
+
+func (t *RevertTracer) CaptureExit(output []byte, gasUsed uint64, err error) {
+	t.exit(err)
+}
+
+func (t *RevertTracer) CaptureEnd(output []byte, gasUsed uint64, err error) {
+	t.exit(err)
+}
+
+// exit records the frames if the innermost frame failed, and pops it.
+func (t *RevertTracer) exit(err error) {
+	if len(t.frames) == 0 {
+		return
+	}
+	if err != nil {
+		t.Traces = append(t.Traces, RevertTrace{
+			Error:  err.Error(),
+			Frames: append([]Frame(nil), t.frames...),
+		})
+	}
+	t.frames = t.frames[:len(t.frames)-1]
+}
+
+func (t *RevertTracer) GetResult() (json.RawMessage, error) {
+	return json.Marshal(t.Traces)
+}
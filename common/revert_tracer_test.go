@@ -0,0 +1,75 @@
+// Copyright 2024 Martin Holst Swende
+// This file is part of the goevmlab library.
+//
+// The library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the goevmlab library. If not, see <http://www.gnu.org/licenses/>.
+
+package common
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/rgeraldes24/goevmlab/ops"
+	"github.com/rgeraldes24/goevmlab/program"
+	"github.com/theQRL/go-zond/common"
+	"github.com/theQRL/go-zond/core/rawdb"
+	"github.com/theQRL/go-zond/core/state"
+	"github.com/theQRL/go-zond/core/vm"
+	"github.com/theQRL/go-zond/core/vm/runtime"
+)
+
+func TestRevertTracer(t *testing.T) {
+	var (
+		a          = common.HexToAddress("0xaa")
+		b          = common.HexToAddress("0xbb")
+		statedb, _ = state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	)
+	// a calls b, which reverts, and then reverts itself
+	outer := program.NewProgram()
+	outer.Call(nil, b, 0, 0, 0, 0, 0)
+	outer.Op(ops.POP)
+	outer.Push(0)
+	outer.Push(0)
+	outer.Op(ops.REVERT)
+	inner := program.NewProgram()
+	inner.Push(0)
+	inner.Push(0)
+	inner.Op(ops.REVERT)
+	statedb.CreateAccount(a)
+	statedb.SetCode(a, outer.Bytecode())
+	statedb.CreateAccount(b)
+	statedb.SetCode(b, inner.Bytecode())
+
+	tracer := new(RevertTracer)
+	cfg := &runtime.Config{State: statedb, EVMConfig: vm.Config{Tracer: tracer}}
+	if _, _, err := runtime.Call(a, nil, cfg); err == nil {
+		t.Fatal("expected revert")
+	}
+	if len(tracer.Traces) != 2 {
+		t.Fatalf("expected 2 traces, got %v", tracer.Traces)
+	}
+	// The inner revert has both frames, the outer one only the outer frame
+	want := []Frame{{a, 13, vm.CALL}, {b, 4, vm.REVERT}}
+	if have := tracer.Traces[0].Frames; len(have) != 2 || have[0] != want[0] || have[1] != want[1] {
+		t.Errorf("got %+v expected %+v", have, want)
+	}
+	want = []Frame{{a, 19, vm.REVERT}}
+	if have := tracer.Traces[1].Frames; len(have) != 1 || have[0] != want[0] {
+		t.Errorf("got %+v expected %+v", have, want)
+	}
+	trace := tracer.Traces[0].String()
+	if !strings.Contains(trace, a.String()) || !strings.Contains(trace, b.String()) {
+		t.Errorf("trace missing addresses:\n%v", trace)
+	}
+}
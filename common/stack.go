@@ -0,0 +1,48 @@
+// Copyright 2024 Martin Holst Swende
+// This file is part of the goevmlab library.
+//
+// The library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the goevmlab library. If not, see <http://www.gnu.org/licenses/>.
+
+package common
+
+import (
+	"math/big"
+
+	"github.com/holiman/uint256"
+	"github.com/theQRL/go-zond/common"
+)
+
+// The helpers below interpret stack words, as found in
+// scope.Stack.Data() during CaptureState.
+
+// AsAddress interprets the word as an address, i.e. the low 20 bytes.
+func AsAddress(word *uint256.Int) common.Address {
+	return common.Address(word.Bytes20())
+}
+
+// AsSigned interprets the word as a two's complement signed integer.
+func AsSigned(word *uint256.Int) *big.Int {
+	if word.Sign() >= 0 {
+		return word.ToBig()
+	}
+	// Negative: -(^word + 1)
+	abs := new(uint256.Int).Neg(word)
+	return new(big.Int).Neg(abs.ToBig())
+}
+
+// AsUint64 returns the word as an uint64, and whether it fits without
+// truncation.
+func AsUint64(word *uint256.Int) (uint64, bool) {
+	return word.Uint64(), word.IsUint64()
+}
@@ -0,0 +1,62 @@
+// Copyright 2024 Martin Holst Swende
+// This file is part of the goevmlab library.
+//
+// The library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the goevmlab library. If not, see <http://www.gnu.org/licenses/>.
+
+package common
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/holiman/uint256"
+	"github.com/theQRL/go-zond/common"
+)
+
+func TestAsAddress(t *testing.T) {
+	word := uint256.MustFromHex("0xffffffffffffffffffffffff00000000000000000000000000000000deadbeef")
+	if have, want := AsAddress(word), common.HexToAddress("0xdeadbeef"); have != want {
+		t.Errorf("got %v expected %v", have, want)
+	}
+}
+
+func TestAsSigned(t *testing.T) {
+	for i, tt := range []struct {
+		word string
+		want int64
+	}{
+		{"0x0", 0},
+		{"0x7", 7},
+		{"0xffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff", -1},
+		{"0xfffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffe", -2},
+	} {
+		if have := AsSigned(uint256.MustFromHex(tt.word)); have.Cmp(big.NewInt(tt.want)) != 0 {
+			t.Errorf("test %d: got %v expected %v", i, have, tt.want)
+		}
+	}
+	// The smallest signed value
+	min := new(big.Int).Neg(new(big.Int).Lsh(big.NewInt(1), 255))
+	if have := AsSigned(uint256.MustFromHex("0x8000000000000000000000000000000000000000000000000000000000000000")); have.Cmp(min) != 0 {
+		t.Errorf("got %v expected %v", have, min)
+	}
+}
+
+func TestAsUint64(t *testing.T) {
+	if have, ok := AsUint64(uint256.NewInt(1337)); !ok || have != 1337 {
+		t.Errorf("got %v (%v) expected 1337", have, ok)
+	}
+	if _, ok := AsUint64(uint256.MustFromHex("0x10000000000000000")); ok {
+		t.Errorf("expected overflow")
+	}
+}
@@ -0,0 +1,48 @@
+// Copyright 2024 Martin Holst Swende
+// This file is part of the goevmlab library.
+//
+// The library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the goevmlab library. If not, see <http://www.gnu.org/licenses/>.
+
+package common
+
+import (
+	"os"
+
+	"github.com/theQRL/go-zond/core/vm"
+	"github.com/theQRL/go-zond/log"
+	"github.com/theQRL/go-zond/zond/tracers/logger"
+)
+
+// TracerEnvVar is the environment variable read by TracerFromEnv.
+const TracerEnvVar = "GOEVMLAB_TRACER"
+
+// TracerFromEnv returns a tracer for in-process executions, as selected by
+// the GOEVMLAB_TRACER environment variable:
+//
+//   - "print": a PrintingTracer
+//   - "json": a json logger, writing to stderr
+//   - "none" or unset: nil, i.e. no tracing
+func TracerFromEnv() vm.EVMLogger {
+	switch v := os.Getenv(TracerEnvVar); v {
+	case "print":
+		return &PrintingTracer{}
+	case "json":
+		return logger.NewJSONLogger(&logger.Config{}, os.Stderr)
+	case "none", "":
+		return nil
+	default:
+		log.Warn("Unknown tracer, disabling tracing", "env", TracerEnvVar, "value", v)
+		return nil
+	}
+}
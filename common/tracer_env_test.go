@@ -0,0 +1,40 @@
+// Copyright 2024 Martin Holst Swende
+// This file is part of the goevmlab library.
+//
+// The library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the goevmlab library. If not, see <http://www.gnu.org/licenses/>.
+
+package common
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestTracerFromEnv(t *testing.T) {
+	for i, tt := range []struct {
+		value string
+		want  string
+	}{
+		{"print", "*common.PrintingTracer"},
+		{"json", "*logger.JSONLogger"},
+		{"none", "<nil>"},
+		{"", "<nil>"},
+		{"bogus", "<nil>"},
+	} {
+		t.Setenv(TracerEnvVar, tt.value)
+		if have := fmt.Sprintf("%T", TracerFromEnv()); have != tt.want {
+			t.Errorf("test %d: got %v expected %v", i, have, tt.want)
+		}
+	}
+}
@@ -93,6 +93,10 @@ var (
 		Name:  "revme",
 		Usage: "Location of reth 'revme' binary",
 	}
+	SocketFlag = &cli.StringSliceFlag{
+		Name:  "socket",
+		Usage: "Location of the unix socket of a vm server, speaking the batch protocol",
+	}
 	ThreadFlag = &cli.IntFlag{
 		Name:  "parallel",
 		Usage: "Number of parallel executions to use.",
@@ -123,6 +127,10 @@ var (
 			"This mode is faster, and can be used even if the clients-under-test has known errors in the trace-output, \n" +
 			"but has a very high chance of missing cases which could be exploitable.",
 	}
+	WorkDirFlag = &cli.StringFlag{
+		Name:  "workdir",
+		Usage: "If set, each vm worker runs its processes in a distinct directory below this location",
+	}
 	VmFlags = []cli.Flag{
 		GethFlag,
 		GethBatchFlag,
@@ -135,6 +143,7 @@ var (
 		NimbusFlag,
 		EvmoneFlag,
 		RethFlag,
+		SocketFlag,
 	}
 	traceLengthSA = utils.NewSlidingAverage()
 )
@@ -151,6 +160,7 @@ func initVMs(c *cli.Context) []evms.Evm {
 		erigonBatchBins = c.StringSlice(ErigonBatchFlag.Name)
 		nimBins         = c.StringSlice(NimbusFlag.Name)
 		evmoneBins      = c.StringSlice(EvmoneFlag.Name)
+		sockets         = c.StringSlice(SocketFlag.Name)
 		// revmBins        = c.StringSlice(RethFlag.Name)
 
 		vms []evms.Evm
@@ -185,6 +195,9 @@ func initVMs(c *cli.Context) []evms.Evm {
 	for i, bin := range evmoneBins {
 		vms = append(vms, evms.NewEvmoneVM(bin, fmt.Sprintf("%d", i)))
 	}
+	for i, socket := range sockets {
+		vms = append(vms, evms.NewSocketVM(socket, fmt.Sprintf("socket-%d", i)))
+	}
 	// for i, bin := range revmBins {
 	// 	vms = append(vms, evms.NewRethVM(bin, fmt.Sprintf("%d", i)))
 	// }
@@ -273,7 +286,7 @@ func RunSingleTest(path string, c *cli.Context) (bool, error) {
 				log.Error("Error running test", "err", err)
 				return
 			}
-			log.Debug("Test done", "evm", evm.Name(), "time", res.ExecTime)
+			log.Debug("Test done", "evm", evm.Name(), "time", res.ExecTime, "error", res.Error)
 		}(vm, i)
 	}
 	wg.Wait()
@@ -351,7 +364,14 @@ func testFnFromGenerator(fn GeneratorFn, name, location string) TestProviderFn {
 		gstMaker := fn()
 		testName := fmt.Sprintf("%08d-%v-%d", index, name, threadId)
 		test := gstMaker.ToGeneralStateTest(testName)
-		return storeTest(location, test, testName)
+		path, err := storeTest(location, test, testName)
+		if err != nil {
+			return path, err
+		}
+		if info := gstMaker.TestInfo(); info != nil {
+			err = fuzzing.WriteTestInfo(path, info)
+		}
+		return path, err
 	}
 }
 
@@ -381,8 +401,14 @@ func ExecuteFuzzer(c *cli.Context, allClients bool, providerFn TestProviderFn, c
 		testCh:              make(chan string, 4), // channel where we'll deliver tests
 		consensusCh:         make(chan string, 4), // channel for signalling consensus errors
 		vms:                 vms,
+		workDir:             c.String(WorkDirFlag.Name),
 		deleteFilesWhenDone: cleanupFiles,
 	}
+	if meta.workDir != "" {
+		if _, err := assignWorkDirs(vms, meta.workDir); err != nil {
+			return err
+		}
+	}
 	// Routines to deliver tests
 	meta.startTestFactories((numThreads+1)/2, providerFn)
 	meta.wg.Add(1)
@@ -506,6 +532,7 @@ type testMeta struct {
 	wg          sync.WaitGroup
 	vms         []evms.Evm
 	numTests    atomic.Uint64
+	workDir     string // if set, the root of the vm working directories
 
 	deleteFilesWhenDone bool
 }
@@ -600,7 +627,9 @@ func (meta *testMeta) vmLoop(evm evms.Evm, taskCh, resultCh chan *task) {
 			continue
 		}
 		if res.Slow {
-			log.Warn("Slow test found", "evm", evm.Name(), "time", res.ExecTime, "cmd", res.Cmd, "file", t.file)
+			info := fuzzing.ReadTestInfo(t.file)
+			log.Warn("Slow test found", "evm", evm.Name(), "time", res.ExecTime, "cmd", res.Cmd, "file", t.file,
+				"codesize", info.CodeSize, "opcount", info.OpCount)
 		}
 		t.slow = res.Slow
 		t.result = hasher.h.Sum(nil)
@@ -613,6 +642,21 @@ func (meta *testMeta) vmLoop(evm evms.Evm, taskCh, resultCh chan *task) {
 	log.Debug("vmloop exiting")
 }
 
+// assignWorkDirs creates a distinct working directory below root for each of the
+// vms, and configures the vm to use it. It returns the directories.
+func assignWorkDirs(vms []evms.Evm, root string) ([]string, error) {
+	var dirs []string
+	for i, vm := range vms {
+		dir := filepath.Join(root, fmt.Sprintf("%d-%v", i, vm.Name()))
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, err
+		}
+		evms.Configure(vm, evms.WithWorkDir(dir))
+		dirs = append(dirs, dir)
+	}
+	return dirs, nil
+}
+
 type cleanTask struct {
 	slow   string // path to a file considered 'slow'
 	remove string // path to a file to be removed
@@ -631,6 +675,10 @@ func (meta *testMeta) cleanupLoop(cleanCh chan *cleanTask) {
 			if err := os.Remove(path); err != nil {
 				log.Error("Error deleting file", "file", path, "err", err)
 			}
+			// The info is only present for generated tests
+			if err := os.Remove(fuzzing.TestInfoPath(path)); err != nil && !os.IsNotExist(err) {
+				log.Error("Error deleting file", "file", path, "err", err)
+			}
 		}
 	}
 	log.Debug("CleanupLoop exiting")
@@ -654,6 +702,9 @@ func (meta *testMeta) handleConsensusFlaw(testfile string) {
 		}
 		fmt.Fprintf(os.Stdout, "- %v: %v\n", evm.Name(), filename)
 		fmt.Fprintf(os.Stdout, "  - command: %v\n", res.Cmd)
+		if len(res.Error) > 0 {
+			fmt.Fprintf(os.Stdout, "  - error: %v\n", res.Error)
+		}
 		_ = out.Sync()
 		_, _ = out.Seek(0, 0)
 		readers = append(readers, out)
@@ -737,6 +788,13 @@ func (meta *testMeta) fuzzingLoop(skipTrace bool, clientCount int) {
 	}
 	for testfile := range meta.testCh {
 		testIndex++
+		if meta.workDir != "" {
+			// The vms do not run in our directory, so relative paths won't do.
+			// The absolute path is also the one used to re-run a consensus flaw.
+			if abs, err := filepath.Abs(testfile); err == nil {
+				testfile = abs
+			}
+		}
 		// First, make sure we have N clients to execute the test on
 		if clientsNeeded := clientCount - len(ready); clientsNeeded > 0 {
 			readResults(clientsNeeded)
@@ -0,0 +1,84 @@
+// Copyright 2024 Martin Holst Swende
+// This file is part of the goevmlab library.
+//
+// The library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the goevmlab library. If not, see <http://www.gnu.org/licenses/>.
+
+package common
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/rgeraldes24/goevmlab/evms"
+)
+
+func TestAssignWorkDirs(t *testing.T) {
+	vms := []evms.Evm{
+		evms.NewGethEVM("/bin/evm", "geth"),
+		evms.NewGethEVM("/bin/evm", "geth"),
+	}
+	dirs, err := assignWorkDirs(vms, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dirs) != 2 {
+		t.Fatalf("got %d dirs expected 2", len(dirs))
+	}
+	if dirs[0] == dirs[1] {
+		t.Errorf("workers share the directory %v", dirs[0])
+	}
+	for _, dir := range dirs {
+		if fi, err := os.Stat(dir); err != nil || !fi.IsDir() {
+			t.Errorf("directory %v not created: %v", dir, err)
+		}
+	}
+}
+
+func TestFuzzingLoopWorkDir(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		paths []string
+		trace = []byte(`{"stateRoot":"0xa2b3391f7a85bf1ad08dc541a1b99da3c591c156351391f26ec88c557ff12134"}` + "\n")
+		meta  = &testMeta{
+			testCh:      make(chan string, 1),
+			consensusCh: make(chan string, 1),
+			workDir:     t.TempDir(),
+		}
+	)
+	for i := 0; i < 2; i++ {
+		vm := evms.NewMockVM("mock", trace)
+		vm.OutputFor = func(path string) []byte {
+			mu.Lock()
+			defer mu.Unlock()
+			paths = append(paths, path)
+			return trace
+		}
+		meta.vms = append(meta.vms, vm)
+	}
+	meta.testCh <- "relative.json"
+	close(meta.testCh)
+	meta.wg.Add(1)
+	meta.fuzzingLoop(false, 2)
+	meta.wg.Wait()
+	if len(paths) != 2 {
+		t.Fatalf("got %d runs expected 2", len(paths))
+	}
+	for _, path := range paths {
+		if !filepath.IsAbs(path) {
+			t.Errorf("got relative path %v", path)
+		}
+	}
+}
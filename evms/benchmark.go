@@ -0,0 +1,57 @@
+// Copyright 2024 Martin Holst Swende
+// This file is part of the goevmlab library.
+//
+// The library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the goevmlab library. If not, see <http://www.gnu.org/licenses/>.
+
+package evms
+
+import (
+	"io"
+	"sort"
+	"time"
+
+	"github.com/theQRL/go-zond/log"
+)
+
+// Benchmark executes the test runs times on each of the vms, in speed-test
+// mode (without tracing), and returns the median execution time per vm. Failed
+// runs are not counted; a vm without any successful run is left out.
+func Benchmark(vms []Evm, testPath string, runs int) map[string]time.Duration {
+	medians := make(map[string]time.Duration)
+	for _, vm := range vms {
+		var times []time.Duration
+		for i := 0; i < runs; i++ {
+			res, err := vm.RunStateTest(testPath, io.Discard, true)
+			if err != nil {
+				log.Warn("Benchmark run failed", "evm", vm.Name(), "err", err)
+				continue
+			}
+			times = append(times, res.ExecTime)
+		}
+		if len(times) > 0 {
+			medians[vm.Name()] = median(times)
+		}
+	}
+	return medians
+}
+
+// median returns the median of the (non-empty) list, which is sorted in place.
+func median(times []time.Duration) time.Duration {
+	sort.Slice(times, func(i, j int) bool { return times[i] < times[j] })
+	mid := len(times) / 2
+	if len(times)%2 == 0 {
+		return (times[mid-1] + times[mid]) / 2
+	}
+	return times[mid]
+}
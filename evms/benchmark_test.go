@@ -0,0 +1,46 @@
+// Copyright 2024 Martin Holst Swende
+// This file is part of the goevmlab library.
+//
+// The library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the goevmlab library. If not, see <http://www.gnu.org/licenses/>.
+
+package evms
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBenchmark(t *testing.T) {
+	var (
+		a = NewMockVM("a", mockTrace(5, 5))
+		b = NewMockVM("b", mockTrace(5, 5))
+	)
+	a.ExecTimes = []time.Duration{5 * time.Millisecond, time.Millisecond, 100 * time.Millisecond}
+	b.ExecTimes = []time.Duration{4 * time.Millisecond, 2 * time.Millisecond, 8 * time.Millisecond, time.Millisecond}
+
+	// b is run four times, so the median is the mean of the middle two
+	res := Benchmark([]Evm{a, b}, "test.json", 4)
+	if have, want := res["b"], 3*time.Millisecond; have != want {
+		t.Errorf("b: got %v expected %v", have, want)
+	}
+	// a cycles its times: 5, 1, 100, 5
+	if have, want := res["a"], 5*time.Millisecond; have != want {
+		t.Errorf("a: got %v expected %v", have, want)
+	}
+	c := NewMockVM("c", mockTrace(5, 5))
+	c.ExecTimes = []time.Duration{9 * time.Millisecond, time.Millisecond, 3 * time.Millisecond}
+	if have, want := Benchmark([]Evm{c}, "test.json", 3)["c"], 3*time.Millisecond; have != want {
+		t.Errorf("c: got %v expected %v", have, want)
+	}
+}
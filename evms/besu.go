@@ -19,6 +19,7 @@ package evms
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -36,6 +37,7 @@ import (
 type BesuVM struct {
 	path string
 	name string // in case multiple instances are used
+	process
 	// Some metrics
 	stats *VmStat
 }
@@ -56,43 +58,65 @@ func (evm *BesuVM) Name() string {
 	return evm.name
 }
 
+func (evm *BesuVM) command(ctx context.Context, path string, speedTest bool) *exec.Cmd {
+	if speedTest {
+		return evm.execCommandContext(ctx, evm.path, "--nomemory", "--notime", "state-test", path)
+	}
+	return evm.execCommandContext(ctx, evm.path, "--nomemory", "--notime", "--json", "state-test", path) // exclude memory
+}
+
+// CommandFor returns the command RunStateTest would use for the test.
+func (evm *BesuVM) CommandFor(path string, speedTest bool) string {
+	return evm.command(context.Background(), path, speedTest).String()
+}
+
 // RunStateTest implements the Evm interface
 func (evm *BesuVM) RunStateTest(path string, out io.Writer, speedTest bool) (*tracingResult, error) {
+	return evm.RunStateTestContext(context.Background(), path, out, speedTest)
+}
+
+// RunStateTestContext implements the CancellableEvm interface. The process
+// is killed if the context is cancelled.
+func (evm *BesuVM) RunStateTestContext(ctx context.Context, path string, out io.Writer, speedTest bool) (*tracingResult, error) {
+	out = evm.filterFields(out)
 	var (
 		t0     = time.Now()
 		stdout io.ReadCloser
+		tail   *tailBuffer
 		err    error
-		cmd    *exec.Cmd
+		cmd    = evm.command(ctx, path, speedTest)
 	)
-	if speedTest {
-		cmd = exec.Command(evm.path, "--nomemory", "--notime", "state-test", path)
-	} else {
-		cmd = exec.Command(evm.path, "--nomemory", "--notime", "--json", "state-test", path) // exclude memory
-	}
-	if stdout, err = cmd.StdoutPipe(); err != nil {
+	if stdout, err = evm.tracePipe(cmd, StdoutStream); err != nil {
 		return &tracingResult{Cmd: cmd.String()}, err
 	}
+	stdout, tail = captureStderr(cmd, stdout)
 	if err = cmd.Start(); err != nil {
 		return &tracingResult{Cmd: cmd.String()}, err
 	}
 	// copy everything to the given writer
-	evm.Copy(out, stdout)
-	err = cmd.Wait()
+	_, summary := evm.copyUntilEnd(out, stdout)
+	err = newExecError(cmd, tail, cmd.Wait())
 	// release resources
-	duration, slow := evm.stats.TraceDone(t0)
+	duration, slow := evm.stats.TraceDone(t0, cmd.String())
 
 	return &tracingResult{
 			Slow:     slow,
 			ExecTime: duration,
-			Cmd:      cmd.String()},
+			Cmd:      cmd.String(),
+			Output:   summary.Output,
+			Error:    summary.Error,
+			LogsHash: summary.LogsHash},
 		err
 }
 
+// reportsOutput implements the outputReporter interface.
+func (evm *BesuVM) reportsOutput() {}
+
 func (vm *BesuVM) Close() {}
 
 func (vm *BesuVM) GetStateRoot(path string) (root, command string, err error) {
 	// Run without tracing
-	cmd := exec.Command(vm.path, "--nomemory", "--notime", "state-test", path)
+	cmd := vm.execCommand(vm.path, "--nomemory", "--notime", "state-test", path)
 
 	data, err := cmd.Output()
 	if err != nil {
@@ -127,11 +151,14 @@ type besuStateRoot struct {
 	StateRoot string `json:"postHash"`
 }
 
-func (evm *BesuVM) copyUntilEnd(out io.Writer, input io.Reader) stateRoot {
+// copyUntilEnd returns the stateroot, and the execution summary reported by
+// the client.
+func (evm *BesuVM) copyUntilEnd(out io.Writer, input io.Reader) (stateRoot, execSummary) {
 	buf := bufferPool.Get().([]byte)
 	//lint:ignore SA6002: argument should be pointer-like to avoid allocations.
 	defer bufferPool.Put(buf)
 	var stateRoot stateRoot
+	var summary execSummary
 	scanner := bufio.NewScanner(input)
 	scanner.Buffer(buf, 32*1024*1024)
 	for scanner.Scan() {
@@ -158,6 +185,7 @@ func (evm *BesuVM) copyUntilEnd(out io.Writer, input io.Reader) stateRoot {
 					stateRoot.StateRoot = tempRoot.StateRoot
 				}
 			}
+			summary.update(data)
 			// If we have a stateroot, we're done
 			break
 		}
@@ -166,19 +194,24 @@ func (evm *BesuVM) copyUntilEnd(out io.Writer, input io.Reader) stateRoot {
 		if elem.Op == 0x0 {
 			continue
 		}
-		outp := FastMarshal(&elem)
-		if _, err := out.Write(append(outp, '\n')); err != nil {
+		evm.stats.CountOp(byte(elem.Op))
+		evm.trimStep(&elem)
+		if err := writeStep(out, &elem); err != nil {
 			fmt.Fprintf(os.Stderr, "Error writing to out: %v\n", err)
-			return stateRoot
+			return stateRoot, summary
 		}
 	}
-	root, _ := json.Marshal(stateRoot)
-	if _, err := out.Write(append(root, '\n')); err != nil {
+	if err := writeRoot(out, stateRoot); err != nil {
 		fmt.Fprintf(os.Stderr, "Error writing to out: %v\n", err)
 	}
-	return stateRoot
+	return stateRoot, summary
 }
 
 func (evm *BesuVM) Stats() []any {
 	return evm.stats.Stats()
 }
+
+// VmStats returns the metrics of the vm, shared by its instances.
+func (evm *BesuVM) VmStats() *VmStat {
+	return evm.stats
+}
@@ -17,6 +17,7 @@
 package evms
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"os/exec"
@@ -47,15 +48,40 @@ func NewBesuBatchVM(path, name string) *BesuBatchVM {
 func (evm *BesuBatchVM) Instance(threadId int) Evm {
 	return &BesuBatchVM{
 		BesuVM: BesuVM{
-			path:  evm.path,
-			name:  fmt.Sprintf("%v-%d", evm.name, threadId),
-			stats: evm.stats,
+			path:    evm.path,
+			name:    fmt.Sprintf("%v-%d", evm.name, threadId),
+			stats:   evm.stats,
+			process: evm.process,
 		},
 	}
 }
 
+func (evm *BesuBatchVM) batchCommand(speedTest bool) *exec.Cmd {
+	if speedTest {
+		return evm.execCommand(evm.path, "--nomemory", "--notime", "state-test")
+	}
+	return evm.execCommand(evm.path, "--nomemory", "--notime", "--json", "state-test")
+}
+
+// CommandFor returns the command of the 'master' process which RunStateTest
+// uses. The test path is not part of it, but passed via stdin.
+func (evm *BesuBatchVM) CommandFor(path string, speedTest bool) string {
+	if evm.cmd != nil {
+		return evm.cmd.String()
+	}
+	return evm.batchCommand(speedTest).String()
+}
+
 // RunStateTest implements the Evm interface
 func (evm *BesuBatchVM) RunStateTest(path string, out io.Writer, speedTest bool) (*tracingResult, error) {
+	return evm.RunStateTestContext(context.Background(), path, out, speedTest)
+}
+
+// RunStateTestContext implements the CancellableEvm interface. If the context
+// is cancelled, the 'master' process is killed, and the next test starts a
+// new one.
+func (evm *BesuBatchVM) RunStateTestContext(ctx context.Context, path string, out io.Writer, speedTest bool) (*tracingResult, error) {
+	out = evm.filterFields(out)
 	var (
 		t0     = time.Now()
 		err    error
@@ -64,12 +90,8 @@ func (evm *BesuBatchVM) RunStateTest(path string, out io.Writer, speedTest bool)
 		stdin  io.WriteCloser
 	)
 	if evm.cmd == nil {
-		if speedTest {
-			cmd = exec.Command(evm.path, "--nomemory", "--notime", "state-test")
-		} else {
-			cmd = exec.Command(evm.path, "--nomemory", "--notime", "--json", "state-test")
-		}
-		if stdout, err = cmd.StdoutPipe(); err != nil {
+		cmd = evm.batchCommand(speedTest)
+		if stdout, err = evm.tracePipe(cmd, StdoutStream); err != nil {
 			return &tracingResult{Cmd: cmd.String()}, err
 		}
 		if stdin, err = cmd.StdinPipe(); err != nil {
@@ -85,13 +107,24 @@ func (evm *BesuBatchVM) RunStateTest(path string, out io.Writer, speedTest bool)
 	evm.mu.Lock()
 	defer evm.mu.Unlock()
 	_, _ = evm.stdin.Write([]byte(fmt.Sprintf("%v\n", path)))
+	master := evm.cmd
+	stop := context.AfterFunc(ctx, func() { _ = master.Process.Kill() })
 	// copy everything for the _current_ statetest to the given writer
-	evm.copyUntilEnd(out, evm.stdout)
-	duration, slow := evm.stats.TraceDone(t0)
+	_, summary := evm.copyUntilEnd(out, evm.stdout)
+	if !stop() {
+		evm.stdin.Close()
+		_ = master.Wait()
+		evm.cmd = nil
+		return &tracingResult{Cmd: master.String()}, ctx.Err()
+	}
+	duration, slow := evm.stats.TraceDone(t0, evm.cmd.String())
 	return &tracingResult{
 		Slow:     slow,
 		ExecTime: duration,
 		Cmd:      evm.cmd.String(),
+		Output:   summary.Output,
+		Error:    summary.Error,
+		LogsHash: summary.LogsHash,
 	}, nil
 }
 
@@ -106,7 +139,7 @@ func (vm *BesuBatchVM) Close() {
 
 func (evm *BesuBatchVM) GetStateRoot(path string) (root, command string, err error) {
 	if evm.cmd == nil {
-		evm.cmd = exec.Command(evm.path, "--nomemory", "--notime", "state-test")
+		evm.cmd = evm.execCommand(evm.path, "--nomemory", "--notime", "state-test")
 		// The stateroot is delivered on stdout
 		if evm.stdout, err = evm.cmd.StdoutPipe(); err != nil {
 			return "", evm.cmd.String(), err
@@ -121,6 +154,6 @@ func (evm *BesuBatchVM) GetStateRoot(path string) (root, command string, err err
 	evm.mu.Lock()
 	defer evm.mu.Unlock()
 	_, _ = evm.stdin.Write([]byte(fmt.Sprintf("%v\n", path)))
-	sRoot := evm.copyUntilEnd(io.Discard, evm.stdout)
+	sRoot, _ := evm.copyUntilEnd(io.Discard, evm.stdout)
 	return sRoot.StateRoot, evm.cmd.String(), nil
 }
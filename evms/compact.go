@@ -0,0 +1,249 @@
+// Copyright 2024 Martin Holst Swende
+// This file is part of the goevmlab library.
+//
+// The library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the goevmlab library. If not, see <http://www.gnu.org/licenses/>.
+
+package evms
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/holiman/uint256"
+	"github.com/theQRL/go-zond/core/vm"
+	"github.com/theQRL/go-zond/zond/tracers/logger"
+)
+
+// The compact format is a sequence of records, each starting with a tag byte.
+//
+//   - tagStep: a normalized step (as produced by CustomMarshal), stored as
+//     depth, pc, gas-delta, op, the stack-delta against the previous step and
+//     the error string.
+//   - tagLine: any other newline-terminated line, stored verbatim.
+//   - tagTail: trailing data which was not newline-terminated, stored verbatim.
+const (
+	tagStep byte = iota
+	tagLine
+	tagTail
+)
+
+// compactStep is the subset of a normalized trace line which is retained
+// in the compact encoding.
+type compactStep struct {
+	Depth int      `json:"depth"`
+	Pc    uint64   `json:"pc"`
+	Gas   uint64   `json:"gas"`
+	Op    uint8    `json:"op"`
+	Stack []string `json:"stack"`
+	Error string   `json:"error"`
+}
+
+// toStructLog converts the step into a logger.StructLog, which can be fed
+// to CustomMarshal.
+func (s *compactStep) toStructLog(stack []uint256.Int) *logger.StructLog {
+	log := &logger.StructLog{
+		Depth: s.Depth,
+		Pc:    s.Pc,
+		Gas:   s.Gas,
+		Op:    vm.OpCode(s.Op),
+		Stack: stack,
+	}
+	if len(s.Error) > 0 {
+		log.Err = errors.New(s.Error)
+	}
+	return log
+}
+
+// parseStep tries to interpret the line as a normalized step. It returns
+// nil unless the step can be reconstructed byte-for-byte.
+func parseStep(line []byte) (*compactStep, []uint256.Int) {
+	var step compactStep
+	if err := json.Unmarshal(line, &step); err != nil {
+		return nil, nil
+	}
+	stack := make([]uint256.Int, len(step.Stack))
+	for i, item := range step.Stack {
+		if err := stack[i].SetFromHex(item); err != nil {
+			return nil, nil
+		}
+	}
+	if !bytes.Equal(CustomMarshal(step.toStructLog(stack)), line) {
+		return nil, nil
+	}
+	return &step, stack
+}
+
+// CompactEncode reads a normalized trace from r, and writes a binary,
+// delta-encoded representation of it to w. Lines which are not normalized
+// steps (e.g. the stateroot-line) are stored verbatim, so that CompactDecode
+// reproduces the input exactly.
+func CompactEncode(r io.Reader, w io.Writer) error {
+	var (
+		in       = bufio.NewReader(r)
+		out      = bufio.NewWriter(w)
+		buf      = make([]byte, 0, 256)
+		prevGas  uint64
+		prevStck []uint256.Int
+	)
+	for {
+		line, err := in.ReadBytes('\n')
+		if len(line) > 0 {
+			buf = buf[:0]
+			if line[len(line)-1] != '\n' {
+				buf = append(buf, tagTail)
+				buf = appendBytes(buf, line)
+			} else if step, stack := parseStep(line[:len(line)-1]); step == nil {
+				buf = append(buf, tagLine)
+				buf = appendBytes(buf, line[:len(line)-1])
+			} else {
+				buf = append(buf, tagStep)
+				buf = binary.AppendUvarint(buf, uint64(step.Depth))
+				buf = binary.AppendUvarint(buf, step.Pc)
+				buf = binary.AppendVarint(buf, int64(step.Gas-prevGas))
+				buf = append(buf, step.Op)
+				// Stack delta: the number of items dropped from the previous
+				// stack, followed by the items added on top of the remainder.
+				common := 0
+				for common < len(prevStck) && common < len(stack) && prevStck[common] == stack[common] {
+					common++
+				}
+				buf = binary.AppendUvarint(buf, uint64(len(prevStck)-common))
+				buf = binary.AppendUvarint(buf, uint64(len(stack)-common))
+				for i := common; i < len(stack); i++ {
+					buf = appendBytes(buf, stack[i].Bytes())
+				}
+				buf = appendBytes(buf, []byte(step.Error))
+				prevGas, prevStck = step.Gas, stack
+			}
+			if _, werr := out.Write(buf); werr != nil {
+				return werr
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return out.Flush()
+}
+
+// CompactDecode reads a trace in the format produced by CompactEncode from r,
+// and writes the normalized trace to w.
+func CompactDecode(r io.Reader, w io.Writer) error {
+	var (
+		in       = bufio.NewReader(r)
+		out      = bufio.NewWriter(w)
+		prevGas  uint64
+		prevStck []uint256.Int
+	)
+	for {
+		tag, err := in.ReadByte()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		switch tag {
+		case tagLine, tagTail:
+			data, err := readBytes(in)
+			if err != nil {
+				return err
+			}
+			if tag == tagLine {
+				data = append(data, '\n')
+			}
+			if _, err := out.Write(data); err != nil {
+				return err
+			}
+		case tagStep:
+			var step compactStep
+			depth, err := binary.ReadUvarint(in)
+			if err != nil {
+				return err
+			}
+			if step.Pc, err = binary.ReadUvarint(in); err != nil {
+				return err
+			}
+			gasDelta, err := binary.ReadVarint(in)
+			if err != nil {
+				return err
+			}
+			if step.Op, err = in.ReadByte(); err != nil {
+				return err
+			}
+			dropped, err := binary.ReadUvarint(in)
+			if err != nil {
+				return err
+			}
+			if dropped > uint64(len(prevStck)) {
+				return fmt.Errorf("invalid stack delta: dropped %d of %d items", dropped, len(prevStck))
+			}
+			added, err := binary.ReadUvarint(in)
+			if err != nil {
+				return err
+			}
+			keep := len(prevStck) - int(dropped)
+			stack := make([]uint256.Int, keep, keep+int(added))
+			copy(stack, prevStck)
+			for i := uint64(0); i < added; i++ {
+				data, err := readBytes(in)
+				if err != nil {
+					return err
+				}
+				stack = append(stack, *new(uint256.Int).SetBytes(data))
+			}
+			errData, err := readBytes(in)
+			if err != nil {
+				return err
+			}
+			step.Depth = int(depth)
+			step.Gas = prevGas + uint64(gasDelta)
+			step.Error = string(errData)
+			if _, err := out.Write(append(CustomMarshal(step.toStructLog(stack)), '\n')); err != nil {
+				return err
+			}
+			prevGas, prevStck = step.Gas, stack
+		default:
+			return fmt.Errorf("invalid record tag %d", tag)
+		}
+	}
+	return out.Flush()
+}
+
+// appendBytes appends the length-prefixed data to buf.
+func appendBytes(buf, data []byte) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(data)))
+	return append(buf, data...)
+}
+
+// readBytes reads length-prefixed data from r.
+func readBytes(r *bufio.Reader) ([]byte, error) {
+	size, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
@@ -0,0 +1,92 @@
+// Copyright 2024 Martin Holst Swende
+// This file is part of the goevmlab library.
+//
+// The library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the goevmlab library. If not, see <http://www.gnu.org/licenses/>.
+
+package evms
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCompactRoundtrip(t *testing.T) {
+	finfos, err := os.ReadDir(filepath.Join("testdata", "cases"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, finfo := range finfos {
+		fname := filepath.Join("testdata", "traces", fmt.Sprintf("%v.geth.stderr.txt", finfo.Name()))
+		raw, err := os.Open(fname)
+		if err != nil {
+			t.Fatal(err)
+		}
+		normalized := new(bytes.Buffer)
+		NewGethEVM("", "").Copy(normalized, raw)
+		raw.Close()
+		testCompactRoundtrip(t, fname, normalized.Bytes())
+	}
+	// Non-normalized and non-terminated lines must survive as well
+	testCompactRoundtrip(t, "misc", []byte("foo\n\n{\"depth\":1,\"pc\":0}\nbar"))
+}
+
+func testCompactRoundtrip(t *testing.T, name string, trace []byte) {
+	t.Helper()
+	compact := new(bytes.Buffer)
+	if err := CompactEncode(bytes.NewReader(trace), compact); err != nil {
+		t.Fatalf("%v: encode failed: %v", name, err)
+	}
+	if len(trace) > 1000 && compact.Len() >= len(trace) {
+		t.Errorf("%v: no compaction, %d -> %d bytes", name, len(trace), compact.Len())
+	}
+	decoded := new(bytes.Buffer)
+	if err := CompactDecode(compact, decoded); err != nil {
+		t.Fatalf("%v: decode failed: %v", name, err)
+	}
+	if !bytes.Equal(decoded.Bytes(), trace) {
+		t.Errorf("%v: roundtrip mismatch\nhave:\n%s\nwant:\n%s", name, decoded.Bytes(), trace)
+	}
+}
+
+// shortWriter fails once n bytes have been written.
+type shortWriter struct{ n int }
+
+func (w *shortWriter) Write(p []byte) (int, error) {
+	if len(p) > w.n {
+		written := w.n
+		w.n = 0
+		return written, io.ErrShortWrite
+	}
+	w.n -= len(p)
+	return len(p), nil
+}
+
+func TestCompactDecodeWriteError(t *testing.T) {
+	var trace bytes.Buffer
+	for i := 0; i < 1000; i++ {
+		fmt.Fprintf(&trace, "{\"depth\":1,\"pc\":%d}\n", i)
+	}
+	compact := new(bytes.Buffer)
+	if err := CompactEncode(&trace, compact); err != nil {
+		t.Fatal(err)
+	}
+	if err := CompactDecode(compact, &shortWriter{n: 100}); !errors.Is(err, io.ErrShortWrite) {
+		t.Errorf("got %v expected %v", err, io.ErrShortWrite)
+	}
+}
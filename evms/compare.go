@@ -0,0 +1,201 @@
+// Copyright 2024 Martin Holst Swende
+// This file is part of the goevmlab library.
+//
+// The library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the goevmlab library. If not, see <http://www.gnu.org/licenses/>.
+
+package evms
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/rgeraldes24/goevmlab/fuzzing"
+	"github.com/theQRL/go-zond/log"
+)
+
+// VMResult is the outcome of executing a test on one VM.
+type VMResult struct {
+	Name     string
+	Root     string        // The stateroot, if reported
+	Lines    int           // Number of lines of (normalized) output
+	ExecTime time.Duration // Time taken to execute the test
+	Cmd      string        // The command used to execute the test
+	Err      error         // Error running the VM, if any
+	Outlier  bool          // Whether the output differs from the majority
+}
+
+// PairDivergence is the first difference between the outputs of two VMs.
+type PairDivergence struct {
+	A, B string // The names of the VMs
+	Divergence
+}
+
+// CompareResult is the outcome of executing a test on several VMs.
+type CompareResult struct {
+	Consensus   bool // Whether all VMs produced the same output
+	Results     []VMResult
+	Divergences []PairDivergence // For each pair of VMs which differed
+}
+
+// Compare executes the test on all the VMs concurrently, and compares the
+// outputs pairwise. The VMs whose outputs differ from the majority are
+// flagged as outliers. If there is no majority, i.e. the largest group of
+// agreeing VMs is not larger than all others, all VMs outside the group of
+// the first VM are flagged.
+func Compare(testPath string, vms []Evm) (*CompareResult, error) {
+	if len(vms) == 0 {
+		return nil, errors.New("no vms")
+	}
+	var (
+		outputs = make([]*bytes.Buffer, len(vms))
+		results = make([]VMResult, len(vms))
+		wg      sync.WaitGroup
+	)
+	for i, vm := range vms {
+		outputs[i] = new(bytes.Buffer)
+		results[i].Name = vm.Name()
+		wg.Add(1)
+		go func(i int, vm Evm) {
+			defer wg.Done()
+			res, err := vm.RunStateTest(testPath, outputs[i], false)
+			if res != nil {
+				results[i].ExecTime = res.ExecTime
+				results[i].Cmd = res.Cmd
+			}
+			results[i].Err = err
+		}(i, vm)
+	}
+	wg.Wait()
+
+	// Group the VMs by output
+	var (
+		groups  = make(map[string][]int)
+		largest []int
+	)
+	for i, out := range outputs {
+		lines := bytes.Split(bytes.TrimSpace(out.Bytes()), []byte("\n"))
+		results[i].Lines = len(lines)
+		var root stateRoot
+		if err := json.Unmarshal(lines[len(lines)-1], &root); err == nil {
+			results[i].Root = root.StateRoot
+		}
+		key := out.String()
+		groups[key] = append(groups[key], i)
+		if group := groups[key]; len(group) > len(largest) {
+			largest = group
+		}
+	}
+	if 2*len(largest) <= len(vms) {
+		// No majority, compare against the first vm
+		largest = groups[outputs[0].String()]
+	}
+	result := &CompareResult{
+		Consensus: len(groups) == 1,
+		Results:   results,
+	}
+	for i := range results {
+		results[i].Outlier = !contains(largest, i)
+	}
+	// Pairwise diffs
+	for i := 0; i < len(vms); i++ {
+		for j := i + 1; j < len(vms); j++ {
+			scanA := bufio.NewScanner(bytes.NewReader(outputs[i].Bytes()))
+			scanB := bufio.NewScanner(bytes.NewReader(outputs[j].Bytes()))
+			scanA.Buffer(nil, 32*1024*1024)
+			scanB.Buffer(nil, 32*1024*1024)
+			if div := firstDivergence(scanA, scanB); div != nil {
+				result.Divergences = append(result.Divergences, PairDivergence{
+					A:          vms[i].Name(),
+					B:          vms[j].Name(),
+					Divergence: *div,
+				})
+			}
+		}
+	}
+	return result, nil
+}
+
+// outputReporter is implemented by the vms which report the return data of
+// the execution.
+type outputReporter interface {
+	reportsOutput()
+}
+
+// CompareOutput executes the test on the vms, and returns whether they agree on
+// the return data, along with the output reported by each vm. The vms which do
+// not report the return data are not executed, and are left out of the map,
+// with a log line. An error is returned if a vm fails to run the test.
+func CompareOutput(vms []Evm, testPath string) (agree bool, outputs map[string]string, err error) {
+	var first string
+	agree = true
+	outputs = make(map[string]string)
+	for _, vm := range vms {
+		if _, ok := vm.(outputReporter); !ok {
+			log.Info("Skipping vm which does not report the output", "vm", vm.Name())
+			continue
+		}
+		res, err := vm.RunStateTest(testPath, io.Discard, false)
+		if err != nil {
+			return false, nil, fmt.Errorf("%v: %w", vm.Name(), err)
+		}
+		if len(outputs) == 0 {
+			first = res.Output
+		} else if res.Output != first {
+			agree = false
+		}
+		outputs[vm.Name()] = res.Output
+	}
+	return agree, outputs, nil
+}
+
+// Verify runs the test, e.g. a minimized case, on all the VMs, and reports
+// whether it still reproduces, i.e. whether the VMs disagree on the resulting
+// stateroot. A VM which fails to run the test reports the error as its root.
+// An error is returned if the test cannot be written to disk.
+// Verify lives in evms rather than in fuzzing, since fuzzing cannot import
+// the vms.
+func Verify(test *fuzzing.GeneralStateTest, vms []Evm) (reproduces bool, roots map[string]string, err error) {
+	path, cleanup, err := fuzzing.WriteTempTest(test)
+	if err != nil {
+		return false, nil, err
+	}
+	defer cleanup()
+	roots = make(map[string]string)
+	for i, vm := range vms {
+		root, _, err := vm.GetStateRoot(path)
+		if err != nil {
+			root = fmt.Sprintf("error: %v", err)
+		}
+		roots[vm.Name()] = root
+		if i > 0 && root != roots[vms[0].Name()] {
+			reproduces = true
+		}
+	}
+	return reproduces, roots, nil
+}
+
+func contains(list []int, x int) bool {
+	for _, y := range list {
+		if x == y {
+			return true
+		}
+	}
+	return false
+}
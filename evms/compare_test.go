@@ -0,0 +1,182 @@
+// Copyright 2024 Martin Holst Swende
+// This file is part of the goevmlab library.
+//
+// The library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the goevmlab library. If not, see <http://www.gnu.org/licenses/>.
+
+package evms
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rgeraldes24/goevmlab/fuzzing"
+)
+
+func TestCompare(t *testing.T) {
+	const steps, divergeAt = 20, 7
+	vms := []Evm{
+		NewMockVM("a", mockTrace(steps, steps)),
+		NewMockVM("b", mockTrace(steps, divergeAt)),
+		NewMockVM("c", mockTrace(steps, steps)),
+	}
+	res, err := Compare("test.json", vms)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Consensus {
+		t.Fatal("expected consensus failure")
+	}
+	for _, r := range res.Results {
+		if r.Err != nil {
+			t.Errorf("%v: unexpected error: %v", r.Name, r.Err)
+		}
+		if have, want := r.Outlier, r.Name == "b"; have != want {
+			t.Errorf("%v: got outlier %v expected %v", r.Name, have, want)
+		}
+		if r.Lines != steps+1 {
+			t.Errorf("%v: got %d lines expected %d", r.Name, r.Lines, steps+1)
+		}
+		if r.Root == "" {
+			t.Errorf("%v: missing stateroot", r.Name)
+		}
+	}
+	if len(res.Divergences) != 2 {
+		t.Fatalf("got %d divergences expected 2", len(res.Divergences))
+	}
+	for _, d := range res.Divergences {
+		if d.A != "b" && d.B != "b" {
+			t.Errorf("unexpected divergence between %v and %v", d.A, d.B)
+		}
+		if d.Step != divergeAt {
+			t.Errorf("got divergence at step %d expected %d", d.Step, divergeAt)
+		}
+	}
+}
+
+func TestCompareConsensus(t *testing.T) {
+	vms := []Evm{
+		NewMockVM("a", mockTrace(10, 10)),
+		NewMockVM("b", mockTrace(10, 10)),
+	}
+	res, err := Compare("test.json", vms)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Consensus || len(res.Divergences) != 0 {
+		t.Fatalf("expected consensus, got %d divergences", len(res.Divergences))
+	}
+}
+
+func TestCompareOutput(t *testing.T) {
+	a := NewMockVM("a", mockTrace(5, 5))
+	a.Output = "0xdead"
+	b := NewMockVM("b", mockTrace(5, 5))
+	b.Output = "0xdead"
+	c := NewMockVM("c", mockTrace(5, 5))
+	c.Output = "0xbeef"
+	// evmone does not report the output, so it is never run
+	e := NewEvmoneVM(filepath.Join(t.TempDir(), "missing"), "e")
+
+	agree, outputs, err := CompareOutput([]Evm{a, e, b}, "test.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !agree {
+		t.Errorf("expected agreement, got %v", outputs)
+	}
+	if _, ok := outputs[e.Name()]; ok {
+		t.Errorf("expected no output for unsupported vm")
+	}
+	agree, outputs, err = CompareOutput([]Evm{a, b, c}, "test.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if agree {
+		t.Errorf("expected disagreement, got %v", outputs)
+	}
+	if have, want := outputs["c"], "0xbeef"; have != want {
+		t.Errorf("got %v expected %v", have, want)
+	}
+	if have, want := outputs["a"], "0xdead"; have != want {
+		t.Errorf("got %v expected %v", have, want)
+	}
+	// A vm which fails to run is an error, not an empty output
+	g := NewGethEVM(filepath.Join(t.TempDir(), "missing"), "g")
+	if _, _, err := CompareOutput([]Evm{a, g}, "test.json"); err == nil {
+		t.Errorf("expected error")
+	}
+}
+
+func TestSummaryOutput(t *testing.T) {
+	trace := filepath.Join("testdata", "traces", "00000006-naivefuzz-0.json")
+	for _, tt := range []struct {
+		file string
+		copy func(io.Writer, io.Reader) (stateRoot, execSummary)
+	}{
+		{"besu.stdout.txt", NewBesuVM("", "").copyUntilEnd},
+		{"nimbus.stderr.txt", NewNimbusEVM("", "").copyUntilEnd},
+		{"nethermind.stderr.txt", NewNethermindVM("", "").copyUntilEnd},
+		{"geth.stderr.txt", NewGethEVM("", "").copyUntilEnd},
+	} {
+		f, err := os.Open(trace + "." + tt.file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, summary := tt.copy(io.Discard, f)
+		f.Close()
+		// The output is empty, but reported as "" or "0x" by the clients
+		if summary.Output != "" {
+			t.Errorf("%v: got %q expected empty output", tt.file, summary.Output)
+		}
+	}
+	var summary execSummary
+	summary.update([]byte(`{"output":"DEAD","gasUsed":"0x1"}`))
+	if have, want := summary.Output, "0xdead"; have != want {
+		t.Errorf("got %v expected %v", have, want)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	var (
+		rootA = "0xa2b3391f7a85bf1ad08dc541a1b99da3c591c156351391f26ec88c557ff12134"
+		rootB = "0x00b3391f7a85bf1ad08dc541a1b99da3c591c156351391f26ec88c557ff12134"
+		a     = NewMockVM("a", mockTrace(5, 5))
+		b     = NewMockVM("b", mockTrace(5, 5))
+		c     = NewMockVM("c", bytes.Replace(mockTrace(5, 5), []byte(rootA), []byte(rootB), 1))
+		test  = fuzzing.BasicStateTest("Shanghai").ToGeneralStateTest("minimized")
+	)
+	reproduces, roots, err := Verify(test, []Evm{a, b, c})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reproduces {
+		t.Errorf("expected the case to reproduce, got %v", roots)
+	}
+	for name, want := range map[string]string{"a": rootA, "b": rootA, "c": rootB} {
+		if have := roots[name]; have != want {
+			t.Errorf("vm %v: got %v expected %v", name, have, want)
+		}
+	}
+	if reproduces, roots, err = Verify(test, []Evm{a, b}); err != nil || reproduces {
+		t.Errorf("expected no reproduction, got %v (err %v)", roots, err)
+	}
+	// Failing to write the test is an error, not a non-reproduction
+	t.Setenv("TMPDIR", filepath.Join(t.TempDir(), "missing"))
+	if _, _, err = Verify(test, []Evm{a, b}); err == nil {
+		t.Errorf("expected error")
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright 2024 Martin Holst Swende
+// This file is part of the goevmlab library.
+//
+// The library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the goevmlab library. If not, see <http://www.gnu.org/licenses/>.
+
+package evms
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/rgeraldes24/goevmlab/fuzzing"
+)
+
+// corpusGas is the gas limit with which the corpus entries are executed.
+const corpusGas = 8_000_000
+
+// RunCorpus executes each entry of the corpus as a program (see RunProgram)
+// on all the vms, and compares the outputs. The ids of the completed entries
+// are appended to the progress file, and entries already listed there are
+// skipped, so an interrupted run can be resumed by calling RunCorpus again
+// with the same progress file. The ids of the entries on which the vms
+// diverged are appended to the progress file with the suffix ".diverged",
+// and returned, including those found before the run was resumed. After each
+// completed entry, progress (if non-nil) is called with the number of done
+// entries.
+//
+// It lives in evms rather than next to fuzzing.RunCorpus, which does the
+// bookkeeping, since fuzzing cannot import the vms.
+func RunCorpus(corpus *fuzzing.Corpus, vms []Evm, progressFile string, progress func(done, total int)) ([]string, error) {
+	var (
+		divergedFile = progressFile + ".diverged"
+		diverged     []string
+		seen         = make(map[string]bool)
+	)
+	if data, err := os.ReadFile(divergedFile); err == nil {
+		for _, id := range strings.Split(string(data), "\n") {
+			if id != "" && !seen[id] {
+				seen[id] = true
+				diverged = append(diverged, id)
+			}
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	f, err := os.OpenFile(divergedFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	run := func(id string, code []byte) error {
+		path, cleanup, err := writeProgram(code, corpusGas)
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+		res, err := Compare(path, vms)
+		if err != nil {
+			return err
+		}
+		if res.Consensus || seen[id] {
+			return nil
+		}
+		seen[id] = true
+		diverged = append(diverged, id)
+		_, err = fmt.Fprintln(f, id)
+		return err
+	}
+	err = fuzzing.RunCorpus(corpus, run, progressFile, progress)
+	return diverged, err
+}
@@ -0,0 +1,73 @@
+// Copyright 2024 Martin Holst Swende
+// This file is part of the goevmlab library.
+//
+// The library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the goevmlab library. If not, see <http://www.gnu.org/licenses/>.
+
+package evms
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rgeraldes24/goevmlab/fuzzing"
+	"github.com/rgeraldes24/goevmlab/ops"
+)
+
+func TestRunCorpus(t *testing.T) {
+	var (
+		corpus       = fuzzing.NewCorpus()
+		progressFile = filepath.Join(t.TempDir(), "progress.txt")
+		runs         int
+		a            = NewMockVM("a", mockTrace(5, 5))
+		b            = NewMockVM("b", nil)
+	)
+	// b diverges on the code PUSH1 2
+	b.OutputFor = func(path string) []byte {
+		runs++
+		if data, _ := os.ReadFile(path); bytes.Contains(data, []byte(`"0x6002"`)) {
+			return mockTrace(5, 2)
+		}
+		return mockTrace(5, 5)
+	}
+	add := func(from, to int) {
+		for i := from; i < to; i++ {
+			corpus.Add(fmt.Sprintf("entry-%d", i), []byte{byte(ops.PUSH1), byte(i)})
+		}
+	}
+	add(0, 3)
+	diverged, err := RunCorpus(corpus, []Evm{a, b}, progressFile, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"entry-2"}; fmt.Sprint(diverged) != fmt.Sprint(want) {
+		t.Errorf("got %v expected %v", diverged, want)
+	}
+	// Resuming with more entries only runs the new ones, and reports the
+	// earlier divergence too
+	add(3, 5)
+	runs = 0
+	diverged, err = RunCorpus(corpus, []Evm{a, b}, progressFile, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if runs != 2 {
+		t.Errorf("got %d runs expected 2", runs)
+	}
+	if want := []string{"entry-2"}; fmt.Sprint(diverged) != fmt.Sprint(want) {
+		t.Errorf("got %v expected %v", diverged, want)
+	}
+}
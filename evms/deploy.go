@@ -0,0 +1,98 @@
+// Copyright 2024 Martin Holst Swende
+// This file is part of the goevmlab library.
+//
+// The library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the goevmlab library. If not, see <http://www.gnu.org/licenses/>.
+
+package evms
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+
+	"github.com/holiman/uint256"
+	"github.com/theQRL/go-zond/common"
+	"github.com/theQRL/go-zond/core/vm"
+)
+
+// DeployResult is the outcome of a CREATE or CREATE2.
+type DeployResult struct {
+	Pc      uint64
+	Depth   int
+	Op      vm.OpCode
+	Address common.Address // The created address, zero on failure
+	GasUsed uint64         // Gas consumed by the op, including the initcode execution
+}
+
+// Success returns whether the deployment succeeded.
+func (d *DeployResult) Success() bool {
+	return d.Address != (common.Address{})
+}
+
+// DeployOutcomes reads a normalized trace, and returns the result of each
+// CREATE and CREATE2, in the order they were executed. The outcome is read
+// from the next step in the same frame: the top of the stack holds the
+// address, and the gas consumed is the difference in remaining gas.
+// Creates after which the frame does not continue (e.g. because the CREATE
+// itself failed) are not included.
+func DeployOutcomes(r io.Reader) []DeployResult {
+	var (
+		results  []DeployResult
+		resolved []bool
+		pending  []int // indexes into results, one per active frame with a pending create
+		gas      []uint64
+		scanner  = bufio.NewScanner(r)
+	)
+	scanner.Buffer(make([]byte, 1024*1024), 32*1024*1024)
+	for scanner.Scan() {
+		var step compactStep
+		if err := json.Unmarshal(scanner.Bytes(), &step); err != nil || step.Depth == 0 {
+			continue
+		}
+		// Resolve (or drop) creates from this depth and deeper
+		for len(pending) > 0 {
+			last := len(pending) - 1
+			res := &results[pending[last]]
+			if res.Depth < step.Depth {
+				break
+			}
+			if res.Depth == step.Depth && len(step.Stack) > 0 {
+				var addr uint256.Int
+				if err := addr.SetFromHex(step.Stack[len(step.Stack)-1]); err == nil {
+					res.Address = common.Address(addr.Bytes20())
+				}
+				res.GasUsed = gas[last] - step.Gas
+				resolved[pending[last]] = true
+			}
+			pending, gas = pending[:last], gas[:last]
+		}
+		if op := vm.OpCode(step.Op); (op == vm.CREATE || op == vm.CREATE2) && step.Error == "" {
+			results = append(results, DeployResult{
+				Pc:    step.Pc,
+				Depth: step.Depth,
+				Op:    op,
+			})
+			resolved = append(resolved, false)
+			pending = append(pending, len(results)-1)
+			gas = append(gas, step.Gas)
+		}
+	}
+	var outcomes []DeployResult
+	for i, res := range results {
+		if resolved[i] {
+			outcomes = append(outcomes, res)
+		}
+	}
+	return outcomes
+}
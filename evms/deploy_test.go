@@ -0,0 +1,59 @@
+// Copyright 2024 Martin Holst Swende
+// This file is part of the goevmlab library.
+//
+// The library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the goevmlab library. If not, see <http://www.gnu.org/licenses/>.
+
+package evms
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/theQRL/go-zond/common"
+	"github.com/theQRL/go-zond/core/vm"
+)
+
+func TestDeployOutcomes(t *testing.T) {
+	trace := strings.Join([]string{
+		// A successful create
+		`{"depth":1,"pc":10,"gas":100000,"op":240,"opName":"CREATE","stack":["0x0","0x0","0x5"]}`,
+		`{"depth":2,"pc":0,"gas":60000,"op":96,"opName":"PUSH1","stack":[]}`,
+		`{"depth":2,"pc":2,"gas":59997,"op":243,"opName":"RETURN","stack":["0x0","0x0"]}`,
+		`{"depth":1,"pc":11,"gas":90000,"op":80,"opName":"POP","stack":["0xd8ad4f4a7a8cc9bd7b36f8a2fdd6c0bed1e7d46d"]}`,
+		// A create where the initcode fails
+		`{"depth":1,"pc":20,"gas":80000,"op":245,"opName":"CREATE2","stack":["0x0","0x0","0x5","0x1"]}`,
+		`{"depth":2,"pc":0,"gas":50000,"op":254,"opName":"INVALID","stack":[],"error":"invalid opcode: INVALID"}`,
+		`{"depth":1,"pc":21,"gas":30000,"op":80,"opName":"POP","stack":["0x0"]}`,
+		// A create which fails itself, aborting the frame
+		`{"depth":1,"pc":30,"gas":10,"op":240,"opName":"CREATE","stack":["0x0","0x0","0x5"],"error":"out of gas"}`,
+		`{"stateRoot":"0xa2b3391f7a85bf1ad08dc541a1b99da3c591c156351391f26ec88c557ff12134"}`,
+	}, "\n")
+	have := DeployOutcomes(strings.NewReader(trace))
+	want := []DeployResult{
+		{Pc: 10, Depth: 1, Op: vm.CREATE, GasUsed: 10000,
+			Address: common.HexToAddress("0xd8ad4f4a7a8cc9bd7b36f8a2fdd6c0bed1e7d46d")},
+		{Pc: 20, Depth: 1, Op: vm.CREATE2, GasUsed: 50000},
+	}
+	if len(have) != len(want) {
+		t.Fatalf("got %d results expected %d: %v", len(have), len(want), have)
+	}
+	for i := range want {
+		if have[i] != want[i] {
+			t.Errorf("result %d: got %+v expected %+v", i, have[i], want[i])
+		}
+	}
+	if !have[0].Success() || have[1].Success() {
+		t.Errorf("wrong success status")
+	}
+}
@@ -0,0 +1,159 @@
+// Copyright 2024 Martin Holst Swende
+// This file is part of the goevmlab library.
+//
+// The library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the goevmlab library. If not, see <http://www.gnu.org/licenses/>.
+
+package evms
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/theQRL/go-zond/common/math"
+)
+
+// dumpAccount is an account in a geth-style state dump.
+type dumpAccount struct {
+	Balance string            `json:"balance"`
+	Nonce   uint64            `json:"nonce"`
+	Code    string            `json:"code"`
+	Storage map[string]string `json:"storage"`
+}
+
+// stateDump is a geth-style state dump, as produced by `evm --dump statetest`.
+type stateDump struct {
+	Root     string                 `json:"root"`
+	Accounts map[string]dumpAccount `json:"accounts"`
+}
+
+// StateDiff is a difference between two state dumps.
+type StateDiff struct {
+	Address string // The account address
+	Field   string // "account", "balance", "nonce", "code" or "storage"
+	Slot    string // The storage slot, if Field is "storage"
+	A, B    string // The values in the respective dumps (empty if missing)
+}
+
+func (d StateDiff) String() string {
+	if d.Field == "storage" {
+		return fmt.Sprintf("%v: slot %v: %q != %q", d.Address, d.Slot, d.A, d.B)
+	}
+	return fmt.Sprintf("%v: %v: %q != %q", d.Address, d.Field, d.A, d.B)
+}
+
+// parseStateDump parses a state dump, either given as the dump itself, or as
+// the result-list output by the `statetest` command (in which case the dump
+// from the first result is used).
+func parseStateDump(data []byte) (*stateDump, error) {
+	var results []struct {
+		State *stateDump `json:"state"`
+	}
+	if err := json.Unmarshal(data, &results); err == nil {
+		if len(results) == 0 || results[0].State == nil {
+			return nil, errors.New("no state dump in results")
+		}
+		return results[0].State, nil
+	}
+	var dump stateDump
+	if err := json.Unmarshal(data, &dump); err != nil {
+		return nil, err
+	}
+	return &dump, nil
+}
+
+// CompareStateDump compares two state dumps, and returns the differing
+// accounts and storage slots, ordered by address. Addresses and slots are
+// compared case-insensitively, balances by value.
+func CompareStateDump(a, b []byte) ([]StateDiff, error) {
+	dumpA, err := parseStateDump(a)
+	if err != nil {
+		return nil, fmt.Errorf("dump a: %w", err)
+	}
+	dumpB, err := parseStateDump(b)
+	if err != nil {
+		return nil, fmt.Errorf("dump b: %w", err)
+	}
+	var (
+		accsA = normalizeKeys(dumpA.Accounts)
+		accsB = normalizeKeys(dumpB.Accounts)
+		diffs []StateDiff
+	)
+	for _, addr := range unionKeys(accsA, accsB) {
+		accA, okA := accsA[addr]
+		accB, okB := accsB[addr]
+		if !okA || !okB {
+			d := StateDiff{Address: addr, Field: "account"}
+			if okA {
+				d.A = "exists"
+			} else {
+				d.B = "exists"
+			}
+			diffs = append(diffs, d)
+			continue
+		}
+		if !equalBalance(accA.Balance, accB.Balance) {
+			diffs = append(diffs, StateDiff{addr, "balance", "", accA.Balance, accB.Balance})
+		}
+		if accA.Nonce != accB.Nonce {
+			diffs = append(diffs, StateDiff{addr, "nonce", "",
+				fmt.Sprint(accA.Nonce), fmt.Sprint(accB.Nonce)})
+		}
+		if !strings.EqualFold(accA.Code, accB.Code) {
+			diffs = append(diffs, StateDiff{addr, "code", "", accA.Code, accB.Code})
+		}
+		slotsA, slotsB := normalizeKeys(accA.Storage), normalizeKeys(accB.Storage)
+		for _, slot := range unionKeys(slotsA, slotsB) {
+			if valA, valB := slotsA[slot], slotsB[slot]; !strings.EqualFold(valA, valB) {
+				diffs = append(diffs, StateDiff{addr, "storage", slot, valA, valB})
+			}
+		}
+	}
+	return diffs, nil
+}
+
+// equalBalance compares two balances by value, since clients report them in
+// decimal or in hex. Balances which cannot be parsed are compared verbatim.
+func equalBalance(a, b string) bool {
+	valA, okA := math.ParseBig256(a)
+	valB, okB := math.ParseBig256(b)
+	if !okA || !okB {
+		return a == b
+	}
+	return valA.Cmp(valB) == 0
+}
+
+func normalizeKeys[V any](m map[string]V) map[string]V {
+	res := make(map[string]V, len(m))
+	for k, v := range m {
+		res[strings.ToLower(k)] = v
+	}
+	return res
+}
+
+func unionKeys[V any](a, b map[string]V) []string {
+	var keys []string
+	for k := range a {
+		keys = append(keys, k)
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
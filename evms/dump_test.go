@@ -0,0 +1,119 @@
+// Copyright 2024 Martin Holst Swende
+// This file is part of the goevmlab library.
+//
+// The library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the goevmlab library. If not, see <http://www.gnu.org/licenses/>.
+
+package evms
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const dumpA = `{
+  "root": "0xa2b3391f7a85bf1ad08dc541a1b99da3c591c156351391f26ec88c557ff12134",
+  "accounts": {
+    "0x00000000000000000000000000000000000000c0": {
+      "balance": "0",
+      "nonce": 0,
+      "code": "0x600160005500",
+      "storage": {
+        "0x0000000000000000000000000000000000000000000000000000000000000000": "0x01",
+        "0x0000000000000000000000000000000000000000000000000000000000000001": "0x02"
+      }
+    },
+    "0xa94f5374fce5edbc8e2a8697c15331677e6ebf0b": {
+      "balance": "999999",
+      "nonce": 1
+    }
+  }
+}`
+
+const dumpB = `[{"name":"test","pass":true,"stateRoot":"0x00","fork":"Shanghai","state":{
+  "root": "0x0b5a3e7e2de0e5d9e2d9d1c2b7c6b7d5e0c4f2c6ed2b0fd0c4e9ab4e8e6d1a5b",
+  "accounts": {
+    "0x00000000000000000000000000000000000000C0": {
+      "balance": "0",
+      "nonce": 0,
+      "code": "0x600160005500",
+      "storage": {
+        "0x0000000000000000000000000000000000000000000000000000000000000000": "0x01",
+        "0x0000000000000000000000000000000000000000000000000000000000000001": "0x03"
+      }
+    },
+    "0xa94f5374fce5edbc8e2a8697c15331677e6ebf0b": {
+      "balance": "999999",
+      "nonce": 1
+    }
+  }
+}}]`
+
+func TestCompareStateDump(t *testing.T) {
+	diffs, err := CompareStateDump([]byte(dumpA), []byte(dumpB))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diffs) != 1 {
+		t.Fatalf("expected 1 diff, got %d: %v", len(diffs), diffs)
+	}
+	want := StateDiff{
+		Address: "0x00000000000000000000000000000000000000c0",
+		Field:   "storage",
+		Slot:    "0x0000000000000000000000000000000000000000000000000000000000000001",
+		A:       "0x02",
+		B:       "0x03",
+	}
+	if diffs[0] != want {
+		t.Fatalf("wrong diff, have %v want %v", diffs[0], want)
+	}
+	// Balances are compared by value, regardless of the encoding
+	hexDump := strings.Replace(dumpA, `"balance": "999999"`, `"balance": "0xF423F"`, 1)
+	if diffs, err := CompareStateDump([]byte(dumpA), []byte(hexDump)); err != nil || len(diffs) != 0 {
+		t.Fatalf("expected no diffs, got %v (err %v)", diffs, err)
+	}
+	hexDump = strings.Replace(dumpA, `"balance": "999999"`, `"balance": "0xf4240"`, 1)
+	if diffs, err := CompareStateDump([]byte(dumpA), []byte(hexDump)); err != nil || len(diffs) != 1 || diffs[0].Field != "balance" {
+		t.Fatalf("expected a balance diff, got %v (err %v)", diffs, err)
+	}
+	// Identical dumps should not differ
+	if diffs, err := CompareStateDump([]byte(dumpA), []byte(dumpA)); err != nil || len(diffs) != 0 {
+		t.Fatalf("expected no diffs, got %v (err %v)", diffs, err)
+	}
+}
+
+func TestErigonDumpState(t *testing.T) {
+	// A fake erigon binary, which just spits out a canned result
+	dir := t.TempDir()
+	result := filepath.Join(dir, "result.json")
+	if err := os.WriteFile(result, []byte(dumpB), 0644); err != nil {
+		t.Fatal(err)
+	}
+	bin := filepath.Join(dir, "evm")
+	if err := os.WriteFile(bin, []byte("#!/bin/sh\ncat "+result+"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	dump, _, err := NewErigonVM(bin, "erigon").DumpState("test.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	diffs, err := CompareStateDump(dump, []byte(dumpB))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diffs) != 0 {
+		t.Fatalf("expected no diffs, got %v", diffs)
+	}
+}
@@ -19,6 +19,7 @@ package evms
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -34,8 +35,11 @@ import (
 type ErigonVM struct {
 	path string
 	name string // in case multiple instances are used
+	process
 	// Some metrics
 	stats *VmStat
+
+	checkStack bool // whether to sanity-check the stack sizes in the trace
 }
 
 func NewErigonVM(path, name string) *ErigonVM {
@@ -46,21 +50,47 @@ func NewErigonVM(path, name string) *ErigonVM {
 	}
 }
 
+// Instance returns a copy of the vm, with its own stats, so that parallel
+// runs do not share any state.
 func (evm *ErigonVM) Instance(int) Evm {
-	return evm
+	return &ErigonVM{
+		path:    evm.path,
+		name:    evm.name,
+		process: evm.process,
+		stats:   new(VmStat),
+
+		checkStack: evm.checkStack,
+	}
 }
 
 func (evm *ErigonVM) Name() string {
 	return evm.name
 }
 
+// EnableStackCheck makes the VM verify that the stack size reported by
+// the client changes between steps as the previous opcode dictates. Anomalies
+// are logged and counted in the stats.
+// OBS: this requires the stack to be part of the output, so it has no effect
+// on speed-tests.
+func (evm *ErigonVM) EnableStackCheck() {
+	evm.checkStack = true
+}
+
+// traceFlags returns the flags selecting the trace output.
+func (evm *ErigonVM) traceFlags(speedTest bool) []string {
+	if speedTest {
+		return []string{"--nomemory", "--noreturndata", "--nostack"}
+	}
+	return evm.process.traceFlags()
+}
+
 // GetStateRoot runs the test and returns the stateroot
 // This currently only works for non-filled statetests. TODO: make it work even if the
 // test is filled. Either by getting the whole trace, or adding stateroot to exec std output
 // even in success-case
 func (evm *ErigonVM) GetStateRoot(path string) (root, command string, err error) {
 	// In this mode, we can run it without tracing
-	cmd := exec.Command(evm.path, "statetest", path)
+	cmd := evm.execCommand(evm.path, "statetest", path)
 	data, err := cmd.CombinedOutput()
 	if err != nil {
 		return "", cmd.String(), err
@@ -73,6 +103,22 @@ func (evm *ErigonVM) GetStateRoot(path string) (root, command string, err error)
 	return root, cmd.String(), err
 }
 
+// DumpState runs the test with the full post-state dump enabled, and returns
+// the dump, which can be compared using CompareStateDump.
+func (evm *ErigonVM) DumpState(path string) (dump []byte, command string, err error) {
+	cmd := evm.execCommand(evm.path, "--dump", "statetest", path)
+	data, err := cmd.Output()
+	if err != nil {
+		return nil, cmd.String(), err
+	}
+	state, err := parseStateDump(data)
+	if err != nil {
+		return nil, cmd.String(), fmt.Errorf("%v: %w", evm.Name(), err)
+	}
+	dump, err = json.Marshal(state)
+	return dump, cmd.String(), err
+}
+
 // ParseStateRoot reads the stateroot from the combined output.
 func (evm *ErigonVM) ParseStateRoot(data []byte) (string, error) {
 	start := bytes.Index(data, []byte(`"stateRoot": "`))
@@ -83,35 +129,57 @@ func (evm *ErigonVM) ParseStateRoot(data []byte) (string, error) {
 	return string(data[start+14 : end]), nil
 }
 
+func (evm *ErigonVM) command(ctx context.Context, path string, speedTest bool) *exec.Cmd {
+	args := append(evm.traceFlags(speedTest), "statetest", path)
+	return evm.execCommandContext(ctx, evm.path, args...)
+}
+
+// CommandFor returns the command RunStateTest would use for the test.
+func (evm *ErigonVM) CommandFor(path string, speedTest bool) string {
+	return evm.command(context.Background(), path, speedTest).String()
+}
+
 // RunStateTest implements the Evm interface
 func (evm *ErigonVM) RunStateTest(path string, out io.Writer, speedTest bool) (*tracingResult, error) {
+	return evm.RunStateTestContext(context.Background(), path, out, speedTest)
+}
+
+// RunStateTestContext implements the CancellableEvm interface. The process
+// is killed if the context is cancelled.
+func (evm *ErigonVM) RunStateTestContext(ctx context.Context, path string, out io.Writer, speedTest bool) (*tracingResult, error) {
+	out = evm.filterFields(out)
 	var (
 		t0     = time.Now()
 		stderr io.ReadCloser
+		tail   *tailBuffer
 		err    error
-		cmd    = exec.Command(evm.path, "--json", "--noreturndata", "--nomemory", "statetest", path)
+		cmd    = evm.command(ctx, path, speedTest)
 	)
-	if speedTest {
-		cmd = exec.Command(evm.path, "--nomemory", "--noreturndata", "--nostack", "statetest", path)
-	}
-	if stderr, err = cmd.StderrPipe(); err != nil {
+	if stderr, err = evm.tracePipe(cmd, StderrStream); err != nil {
 		return &tracingResult{Cmd: cmd.String()}, err
 	}
+	stderr, tail = captureStderr(cmd, stderr)
 	if err = cmd.Start(); err != nil {
 		return &tracingResult{Cmd: cmd.String()}, err
 	}
 	// copy everything to the given writer
-	evm.Copy(out, stderr)
-	err = cmd.Wait()
+	_, summary := evm.copyUntilEnd(out, stderr)
+	err = newExecError(cmd, tail, cmd.Wait())
 	// release resources
-	duration, slow := evm.stats.TraceDone(t0)
+	duration, slow := evm.stats.TraceDone(t0, cmd.String())
 	return &tracingResult{
 			Slow:     slow,
 			ExecTime: duration,
-			Cmd:      cmd.String()},
+			Cmd:      cmd.String(),
+			Output:   summary.Output,
+			Error:    summary.Error,
+			LogsHash: summary.LogsHash},
 		err
 }
 
+// reportsOutput implements the outputReporter interface.
+func (evm *ErigonVM) reportsOutput() {}
+
 func (vm *ErigonVM) Close() {
 }
 
@@ -122,9 +190,13 @@ func (evm *ErigonVM) Copy(out io.Writer, input io.Reader) {
 }
 
 // copyUntilEnd reads from the reader, does some geth-specific filtering and
-// outputs items onto the channel
-func (evm *ErigonVM) copyUntilEnd(out io.Writer, input io.Reader) stateRoot {
+// outputs items onto the channel. It returns the stateroot, and the execution
+// summary reported by the client.
+func (evm *ErigonVM) copyUntilEnd(out io.Writer, input io.Reader) (stateRoot, execSummary) {
 	var stateRoot stateRoot
+	var summary execSummary
+	var prev *logger.StructLog
+	var fields = evm.traceFields()
 	scanner := bufio.NewScanner(input)
 	// Start with 1MB buffer, allow up to 32 MB
 	scanner.Buffer(make([]byte, 1024*1024), 32*1024*1024)
@@ -147,6 +219,7 @@ func (evm *ErigonVM) copyUntilEnd(out io.Writer, input io.Reader) stateRoot {
 			if stateRoot.StateRoot == "" {
 				_ = json.Unmarshal(data, &stateRoot)
 			}
+			summary.update(data)
 			// If we have a stateroot, we're done
 			if len(stateRoot.StateRoot) > 0 {
 				break
@@ -158,19 +231,31 @@ func (evm *ErigonVM) copyUntilEnd(out io.Writer, input io.Reader) stateRoot {
 		if elem.Op == 0x0 {
 			continue
 		}
-		outp := FastMarshal(&elem)
-		if _, err := out.Write(append(outp, '\n')); err != nil {
+		evm.trimStep(&elem)
+		if evm.checkStack && fields.Stack {
+			if err := checkStackDelta(prev, &elem); err != nil {
+				log.Warn("Stack anomaly in trace", "vm", evm.Name(), "err", err)
+				evm.stats.stackAnomalies.Add(1)
+			}
+			prev = &elem
+		}
+		evm.stats.CountOp(byte(elem.Op))
+		if err := writeStep(out, &elem); err != nil {
 			fmt.Fprintf(os.Stderr, "Error writing to out: %v\n", err)
-			return stateRoot
+			return stateRoot, summary
 		}
 	}
-	root, _ := json.Marshal(stateRoot)
-	if _, err := out.Write(append(root, '\n')); err != nil {
+	if err := writeRoot(out, stateRoot); err != nil {
 		fmt.Fprintf(os.Stderr, "Error writing to out: %v\n", err)
 	}
-	return stateRoot
+	return stateRoot, summary
 }
 
 func (evm *ErigonVM) Stats() []any {
 	return evm.stats.Stats()
 }
+
+// VmStats returns the metrics of the vm. Each instance has metrics of its own.
+func (evm *ErigonVM) VmStats() *VmStat {
+	return evm.stats
+}
@@ -0,0 +1,154 @@
+// Copyright 2024 Martin Holst Swende
+// This file is part of the goevmlab library.
+//
+// The library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the goevmlab library. If not, see <http://www.gnu.org/licenses/>.
+
+package evms
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestErigonStackCheck(t *testing.T) {
+	// Genuine traces should not trigger any anomalies
+	finfos, err := os.ReadDir(filepath.Join("testdata", "cases"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	vm := NewErigonVM("", "")
+	vm.EnableStackCheck()
+	for _, finfo := range finfos {
+		f, err := os.Open(filepath.Join("testdata", "traces", finfo.Name()+".erigon.stderr.txt"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		vm.Copy(io.Discard, f)
+		f.Close()
+	}
+	if n := vm.stats.stackAnomalies.Load(); n != 0 {
+		t.Fatalf("expected no anomalies, got %d", n)
+	}
+	// PUSH1 leaves the stack with two items instead of one
+	trace := strings.Join([]string{
+		`{"pc":0,"op":96,"gas":"0xb4213","gasCost":"0x3","memSize":0,"stack":[],"depth":1,"refund":0,"opName":"PUSH1"}`,
+		`{"pc":2,"op":96,"gas":"0xb4210","gasCost":"0x3","memSize":0,"stack":["0x2","0x2"],"depth":1,"refund":0,"opName":"PUSH1"}`,
+		`{"pc":4,"op":80,"gas":"0xb420d","gasCost":"0x2","memSize":0,"stack":["0x2","0x2","0x3"],"depth":1,"refund":0,"opName":"POP"}`,
+		`{"stateRoot": "0xa2b3391f7a85bf1ad08dc541a1b99da3c591c156351391f26ec88c557ff12134"}`,
+	}, "\n")
+	vm.Copy(io.Discard, strings.NewReader(trace))
+	if n := vm.stats.stackAnomalies.Load(); n != 1 {
+		t.Fatalf("expected one anomaly, got %d", n)
+	}
+}
+
+func TestErigonExecError(t *testing.T) {
+	// A fake erigon binary, which just spits out a canned trace
+	dir := t.TempDir()
+	trace := filepath.Join(dir, "trace.txt")
+	data := strings.Join([]string{
+		`{"pc":0,"op":96,"gas":"0xb4213","gasCost":"0x3","memSize":0,"stack":[],"depth":1,"refund":0,"opName":"PUSH1"}`,
+		`{"output":"0xdead","gasUsed":"0x2d1cc4","time":233624,"error":"gas uint64 overflow"}`,
+		`{"stateRoot": "0xa2b3391f7a85bf1ad08dc541a1b99da3c591c156351391f26ec88c557ff12134"}`,
+	}, "\n")
+	if err := os.WriteFile(trace, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	bin := filepath.Join(dir, "evm")
+	if err := os.WriteFile(bin, []byte("#!/bin/sh\ncat "+trace+" >&2\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	res, err := NewErigonVM(bin, "erigon").RunStateTest("test.json", io.Discard, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have, want := res.Error, "gas uint64 overflow"; have != want {
+		t.Fatalf("wrong error, have %q want %q", have, want)
+	}
+	if have, want := res.Output, "0xdead"; have != want {
+		t.Fatalf("wrong output, have %q want %q", have, want)
+	}
+}
+
+func TestErigonCommandFor(t *testing.T) {
+	vm := NewErigonVM("/bin/evm", "erigon")
+	if have, want := vm.CommandFor("test.json", false), "/bin/evm --json --noreturndata --nomemory statetest test.json"; have != want {
+		t.Errorf("got %q expected %q", have, want)
+	}
+	if have, want := vm.CommandFor("test.json", true), "/bin/evm --nomemory --noreturndata --nostack statetest test.json"; have != want {
+		t.Errorf("got %q expected %q", have, want)
+	}
+}
+
+func TestErigonTraceFields(t *testing.T) {
+	for i, tt := range []struct {
+		stack, memory, returndata bool
+		want                      string
+	}{
+		{true, false, false, "/bin/evm --json --noreturndata --nomemory statetest test.json"},
+		{false, false, false, "/bin/evm --json --noreturndata --nomemory --nostack statetest test.json"},
+		{true, true, false, "/bin/evm --json --noreturndata statetest test.json"},
+		{false, true, false, "/bin/evm --json --noreturndata --nostack statetest test.json"},
+		{true, false, true, "/bin/evm --json --nomemory statetest test.json"},
+		{false, false, true, "/bin/evm --json --nomemory --nostack statetest test.json"},
+		{true, true, true, "/bin/evm --json statetest test.json"},
+		{false, true, true, "/bin/evm --json --nostack statetest test.json"},
+	} {
+		vm := NewErigonVM("/bin/evm", "erigon")
+		Configure(vm, WithTraceFields(tt.stack, tt.memory, tt.returndata))
+		if have := vm.CommandFor("test.json", false); have != tt.want {
+			t.Errorf("test %d: got %q expected %q", i, have, tt.want)
+		}
+		// Speed-tests never trace any fields
+		if have, want := vm.CommandFor("test.json", true), "/bin/evm --nomemory --noreturndata --nostack statetest test.json"; have != want {
+			t.Errorf("test %d: got %q expected %q", i, have, want)
+		}
+		batch := NewErigonBatchVM("/bin/evm", "erigon")
+		Configure(batch, WithTraceFields(tt.stack, tt.memory, tt.returndata))
+		if have, want := batch.Instance(0).CommandFor("", false), strings.TrimSuffix(tt.want, " test.json"); have != want {
+			t.Errorf("test %d: batch: got %q expected %q", i, have, want)
+		}
+	}
+	// Without the stack, the output has none either, even if the client
+	// delivers it
+	trace := `{"pc":0,"op":80,"gas":"0xb4213","gasCost":"0x2","memSize":0,"stack":["0x2"],"depth":1,"refund":0,"opName":"POP"}`
+	vm := NewErigonVM("", "")
+	Configure(vm, WithTraceFields(false, false, false))
+	out := new(strings.Builder)
+	vm.Copy(out, strings.NewReader(trace))
+	if have := out.String(); !strings.Contains(have, `"stack":[]`) {
+		t.Errorf("expected no stack, got %v", have)
+	}
+}
+
+func TestErigonInstance(t *testing.T) {
+	vm := NewErigonVM("/bin/evm", "erigon")
+	vm.EnableStackCheck()
+	a := vm.Instance(0).(*ErigonVM)
+	b := vm.Instance(1).(*ErigonVM)
+	if a == vm || a == b {
+		t.Fatalf("expected distinct instances")
+	}
+	if a.stats == b.stats || a.stats == vm.stats {
+		t.Errorf("expected distinct stats")
+	}
+	for _, instance := range []*ErigonVM{a, b} {
+		if instance.path != vm.path || instance.Name() != vm.Name() || !instance.checkStack {
+			t.Errorf("got %v %v (stack check %v) expected %v %v", instance.path, instance.Name(), instance.checkStack, vm.path, vm.Name())
+		}
+	}
+}
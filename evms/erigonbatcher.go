@@ -17,6 +17,7 @@
 package evms
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"os/exec"
@@ -35,22 +36,46 @@ type ErigonBatchVM struct {
 
 func NewErigonBatchVM(path, name string) *ErigonBatchVM {
 	return &ErigonBatchVM{
-		ErigonVM: ErigonVM{path, name, &VmStat{}},
+		ErigonVM: ErigonVM{path: path, name: name, stats: &VmStat{}},
 	}
 }
 
 func (evm *ErigonBatchVM) Instance(threadId int) Evm {
 	return &ErigonBatchVM{
 		ErigonVM: ErigonVM{
-			path:  evm.path,
-			name:  fmt.Sprintf("%v-%d", evm.name, threadId),
-			stats: evm.stats,
+			path:    evm.path,
+			name:    fmt.Sprintf("%v-%d", evm.name, threadId),
+			stats:   evm.stats,
+			process: evm.process,
+
+			checkStack: evm.checkStack,
 		},
 	}
 }
 
+func (evm *ErigonBatchVM) batchCommand(speedTest bool) *exec.Cmd {
+	return evm.execCommand(evm.path, append(evm.traceFlags(speedTest), "statetest")...)
+}
+
+// CommandFor returns the command of the 'master' process which RunStateTest
+// uses. The test path is not part of it, but passed via stdin.
+func (evm *ErigonBatchVM) CommandFor(path string, speedTest bool) string {
+	if evm.cmd != nil {
+		return evm.cmd.String()
+	}
+	return evm.batchCommand(speedTest).String()
+}
+
 // RunStateTest implements the Evm interface
 func (evm *ErigonBatchVM) RunStateTest(path string, out io.Writer, speedTest bool) (*tracingResult, error) {
+	return evm.RunStateTestContext(context.Background(), path, out, speedTest)
+}
+
+// RunStateTestContext implements the CancellableEvm interface. If the context
+// is cancelled, the 'master' process is killed, and the next test starts a
+// new one.
+func (evm *ErigonBatchVM) RunStateTestContext(ctx context.Context, path string, out io.Writer, speedTest bool) (*tracingResult, error) {
+	out = evm.filterFields(out)
 	var (
 		t0     = time.Now()
 		err    error
@@ -59,12 +84,8 @@ func (evm *ErigonBatchVM) RunStateTest(path string, out io.Writer, speedTest boo
 		stdin  io.WriteCloser
 	)
 	if evm.cmd == nil {
-		if speedTest {
-			cmd = exec.Command(evm.path, "--nomemory", "--noreturndata", "--nostack", "statetest")
-		} else {
-			cmd = exec.Command(evm.path, "--json", "--noreturndata", "--nomemory", "statetest")
-		}
-		if stdout, err = cmd.StderrPipe(); err != nil {
+		cmd = evm.batchCommand(speedTest)
+		if stdout, err = evm.tracePipe(cmd, StderrStream); err != nil {
 			return &tracingResult{Cmd: cmd.String()}, err
 		}
 		if stdin, err = cmd.StdinPipe(); err != nil {
@@ -80,14 +101,25 @@ func (evm *ErigonBatchVM) RunStateTest(path string, out io.Writer, speedTest boo
 	evm.mu.Lock()
 	defer evm.mu.Unlock()
 	_, _ = evm.stdin.Write([]byte(fmt.Sprintf("%v\n", path)))
+	master := evm.cmd
+	stop := context.AfterFunc(ctx, func() { _ = master.Process.Kill() })
 	// copy everything for the _current_ statetest to the given writer
-	evm.copyUntilEnd(out, evm.stdout)
+	_, summary := evm.copyUntilEnd(out, evm.stdout)
+	if !stop() {
+		evm.stdin.Close()
+		_ = master.Wait()
+		evm.cmd = nil
+		return &tracingResult{Cmd: master.String()}, ctx.Err()
+	}
 	// release resources, handle error but ignore non-zero exit codes
-	duration, slow := evm.stats.TraceDone(t0)
+	duration, slow := evm.stats.TraceDone(t0, evm.cmd.String())
 	return &tracingResult{
 			Slow:     slow,
 			ExecTime: duration,
-			Cmd:      evm.cmd.String()},
+			Cmd:      evm.cmd.String(),
+			Output:   summary.Output,
+			Error:    summary.Error,
+			LogsHash: summary.LogsHash},
 		nil
 }
 
@@ -102,7 +134,7 @@ func (vm *ErigonBatchVM) Close() {
 
 func (evm *ErigonBatchVM) GetStateRoot(path string) (root, command string, err error) {
 	if evm.cmd == nil {
-		evm.cmd = exec.Command(evm.path)
+		evm.cmd = evm.execCommand(evm.path)
 		if evm.stdout, err = evm.cmd.StdoutPipe(); err != nil {
 			return "", evm.cmd.String(), err
 		}
@@ -116,6 +148,6 @@ func (evm *ErigonBatchVM) GetStateRoot(path string) (root, command string, err e
 	evm.mu.Lock()
 	defer evm.mu.Unlock()
 	_, _ = evm.stdin.Write([]byte(fmt.Sprintf("%v\n", path)))
-	sRoot := evm.copyUntilEnd(io.Discard, evm.stdout)
+	sRoot, _ := evm.copyUntilEnd(io.Discard, evm.stdout)
 	return sRoot.StateRoot, evm.cmd.String(), nil
 }
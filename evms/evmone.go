@@ -19,6 +19,7 @@ package evms
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -33,6 +34,7 @@ import (
 type EvmoneVM struct {
 	path string
 	name string
+	process
 
 	stats *VmStat
 }
@@ -54,7 +56,7 @@ func (evm *EvmoneVM) Name() string {
 }
 
 func (evm *EvmoneVM) GetStateRoot(path string) (root, command string, err error) {
-	cmd := exec.Command(evm.path, "--trace-summary", path)
+	cmd := evm.execCommand(evm.path, "--trace-summary", path)
 	data, err := StdErrOutput(cmd)
 
 	// In case of root hash mismatch evmone exists with 1. Ignore this.
@@ -84,31 +86,49 @@ func (evm *EvmoneVM) ParseStateRoot(data []byte) (root string, err error) {
 	return string(data[start:end]), nil
 }
 
+func (evm *EvmoneVM) command(ctx context.Context, path string) *exec.Cmd {
+	return evm.execCommandContext(ctx, evm.path, "--trace", path)
+}
+
+// CommandFor returns the command RunStateTest would use for the test.
+func (evm *EvmoneVM) CommandFor(path string, speedTest bool) string {
+	return evm.command(context.Background(), path).String()
+}
+
+// RunStateTest implements the Evm interface
 func (evm *EvmoneVM) RunStateTest(path string, out io.Writer, speedTest bool) (*tracingResult, error) {
+	return evm.RunStateTestContext(context.Background(), path, out, speedTest)
+}
+
+// RunStateTestContext implements the CancellableEvm interface. The process
+// is killed if the context is cancelled.
+func (evm *EvmoneVM) RunStateTestContext(ctx context.Context, path string, out io.Writer, speedTest bool) (*tracingResult, error) {
+	out = evm.filterFields(out)
 	var (
 		t0     = time.Now()
 		stderr io.ReadCloser
+		tail   *tailBuffer
 		err    error
-		cmd    *exec.Cmd
+		cmd    = evm.command(ctx, path)
 	)
 
-	cmd = exec.Command(evm.path, "--trace", path)
-
-	if stderr, err = cmd.StderrPipe(); err != nil {
+	if stderr, err = evm.tracePipe(cmd, StderrStream); err != nil {
 		return nil, err
 	}
+	stderr, tail = captureStderr(cmd, stderr)
 	if err = cmd.Start(); err != nil {
 		return nil, err
 	}
 
 	evm.Copy(out, stderr)
 	err = cmd.Wait()
-	duration, slow := evm.stats.TraceDone(t0)
+	duration, slow := evm.stats.TraceDone(t0, cmd.String())
 
 	// In case of root hash mismatch evmone exists with 1. Ignore this.
 	if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 1 {
 		err = nil
 	}
+	err = newExecError(cmd, tail, err)
 
 	return &tracingResult{
 		Slow:     slow,
@@ -152,13 +172,13 @@ func (evm *EvmoneVM) Copy(out io.Writer, input io.Reader) {
 		if elem.Op == 0x0 {
 			continue
 		}
-		jsondata := FastMarshal(&elem)
-		if _, err := out.Write(append(jsondata, '\n')); err != nil {
+		evm.stats.CountOp(byte(elem.Op))
+		evm.trimStep(&elem)
+		if err := writeStep(out, &elem); err != nil {
 			fmt.Fprintf(os.Stderr, "Error writing to out: %v\n", err)
 		}
 	}
-	root, _ := json.Marshal(stateRoot)
-	if _, err := out.Write(append(root, '\n')); err != nil {
+	if err := writeRoot(out, stateRoot); err != nil {
 		fmt.Fprintf(os.Stderr, "Error writing to output: %v\n", err)
 		return
 	}
@@ -167,3 +187,8 @@ func (evm *EvmoneVM) Copy(out io.Writer, input io.Reader) {
 func (evm *EvmoneVM) Stats() []any {
 	return evm.stats.Stats()
 }
+
+// VmStats returns the metrics of the vm, shared by its instances.
+func (evm *EvmoneVM) VmStats() *VmStat {
+	return evm.stats
+}
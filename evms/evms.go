@@ -19,6 +19,7 @@ package evms
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"sync"
@@ -30,6 +31,9 @@ type Evm interface {
 	// RunStateTest runs the statetest on the underlying EVM, and writes
 	// the output to the given writer
 	RunStateTest(path string, writer io.Writer, skipTrace bool) (*tracingResult, error)
+	// CommandFor returns the command which RunStateTest would execute for
+	// the test, without executing it.
+	CommandFor(path string, skipTrace bool) string
 	// GetStateRoot runs the test and returns the stateroot
 	GetStateRoot(path string) (root, command string, err error)
 	// ParseStateRoot reads the stateroot from the combined output.
@@ -49,6 +53,15 @@ type Evm interface {
 	Instance(threadId int) Evm
 }
 
+// CancellableEvm is an Evm whose execution can be aborted, e.g. once it is
+// known that the output is not going to be used.
+type CancellableEvm interface {
+	Evm
+	// RunStateTestContext is like RunStateTest, but aborts the execution
+	// when the context is cancelled.
+	RunStateTestContext(ctx context.Context, path string, writer io.Writer, skipTrace bool) (*tracingResult, error)
+}
+
 type stateRoot struct {
 	StateRoot string `json:"stateRoot"`
 }
@@ -0,0 +1,147 @@
+// Copyright 2024 Martin Holst Swende
+// This file is part of the goevmlab library.
+//
+// The library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the goevmlab library. If not, see <http://www.gnu.org/licenses/>.
+
+package evms
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"syscall"
+)
+
+// stderrTailSize is the number of bytes of stderr retained in an ExecError.
+const stderrTailSize = 2048
+
+// ExecError is the failure of a vm process.
+type ExecError struct {
+	Cmd      string // The command which was executed
+	ExitCode int    // The exit code, or -1 if the process did not exit normally
+	Signal   string // The signal which killed the process, if any
+	Stderr   string // The tail of the standard error of the process
+	Err      error  // The error returned when waiting for the process
+}
+
+func (e *ExecError) Error() string {
+	status := fmt.Sprintf("exit code %d", e.ExitCode)
+	if e.Signal != "" {
+		status = fmt.Sprintf("signal %v", e.Signal)
+	}
+	return fmt.Sprintf("%v: %v (%v)", e.Cmd, e.Err, status)
+}
+
+func (e *ExecError) Unwrap() error {
+	return e.Err
+}
+
+// newExecError wraps the error of a finished command into an ExecError. A nil
+// error is returned as is. The tail, if non-nil, holds the end of stderr.
+func newExecError(cmd *exec.Cmd, tail *tailBuffer, err error) error {
+	if err == nil {
+		return nil
+	}
+	e := &ExecError{Cmd: cmd.String(), ExitCode: -1, Err: err}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		e.ExitCode = exitErr.ExitCode()
+		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+			e.Signal = status.Signal().String()
+		}
+		// Set by cmd.Output, if stderr was not redirected
+		e.Stderr = string(tailOf(exitErr.Stderr))
+	}
+	if tail != nil {
+		e.Stderr = string(tail.buf)
+	}
+	return e
+}
+
+// tailBuffer is a writer which retains the last stderrTailSize bytes.
+type tailBuffer struct {
+	buf []byte
+}
+
+func (t *tailBuffer) Write(p []byte) (int, error) {
+	t.buf = tailOf(append(t.buf, p...))
+	return len(p), nil
+}
+
+func tailOf(data []byte) []byte {
+	if len(data) > stderrTailSize {
+		return data[len(data)-stderrTailSize:]
+	}
+	return data
+}
+
+// stepPrefix is the start of the lines which are steps of a trace.
+var stepPrefix = []byte(`{"pc":`)
+
+// nonTraceWriter writes the lines which are not steps of a trace to the tail,
+// so that the tail holds the errors, rather than the end of the trace.
+type nonTraceWriter struct {
+	tail    *tailBuffer
+	line    []byte // the start of the current line, until it is known whether it is a step
+	decided bool   // whether it is known if the current line is a step
+	skip    bool   // whether the current line is a step
+}
+
+func (w *nonTraceWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		end := bytes.IndexByte(p, '\n') + 1
+		if end == 0 {
+			end = len(p)
+		}
+		seg := p[:end]
+		p = p[end:]
+		if !w.decided {
+			w.line = append(w.line, seg...)
+			if len(w.line) < len(stepPrefix) && seg[len(seg)-1] != '\n' {
+				continue
+			}
+			w.decided = true
+			w.skip = bytes.HasPrefix(w.line, stepPrefix)
+			seg = w.line
+			w.line = w.line[:0]
+		}
+		if !w.skip {
+			w.tail.Write(seg)
+		}
+		if seg[len(seg)-1] == '\n' {
+			w.decided = false
+		}
+	}
+	return n, nil
+}
+
+// captureStderr retains the tail of the stderr of the command, which must not
+// have been started yet. If the trace is read from stderr, the tail is taken
+// from the lines which are not steps of the trace, and the returned reader
+// must be used in its place.
+func captureStderr(cmd *exec.Cmd, trace io.ReadCloser) (io.ReadCloser, *tailBuffer) {
+	tail := new(tailBuffer)
+	if cmd.Stderr == nil {
+		cmd.Stderr = tail
+		return trace, tail
+	}
+	// Stderr is the pipe carrying the trace
+	return struct {
+		io.Reader
+		io.Closer
+	}{io.TeeReader(trace, &nonTraceWriter{tail: tail}), trace}, tail
+}
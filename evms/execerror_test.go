@@ -0,0 +1,122 @@
+// Copyright 2024 Martin Holst Swende
+// This file is part of the goevmlab library.
+//
+// The library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the goevmlab library. If not, see <http://www.gnu.org/licenses/>.
+
+package evms
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeBinary creates an executable shell script with the given body.
+func fakeBinary(t *testing.T, body string) string {
+	t.Helper()
+	bin := filepath.Join(t.TempDir(), "evm")
+	if err := os.WriteFile(bin, []byte("#!/bin/sh\n"+body+"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return bin
+}
+
+func TestExecError(t *testing.T) {
+	for _, tt := range []struct {
+		vm       Evm
+		exitCode int
+		signal   string
+		stderr   string
+	}{
+		// Geth reads the trace from stderr
+		{NewGethEVM(fakeBinary(t, `echo '{"fatal":"bad test"}' >&2`+"\nexit 2"), "geth"), 2, "", `{"fatal":"bad test"}` + "\n"},
+		{NewGethEVM(fakeBinary(t, `echo '{"fatal":"going down"}' >&2`+"\nkill -9 $$"), "geth"), -1, "killed", `{"fatal":"going down"}` + "\n"},
+		// Besu reads the trace from stdout
+		{NewBesuVM(fakeBinary(t, "echo '{}'\necho 'fatal: bad test' >&2\nexit 2"), "besu"), 2, "", "fatal: bad test\n"},
+	} {
+		_, err := tt.vm.RunStateTest("test.json", io.Discard, false)
+		var execErr *ExecError
+		if !errors.As(err, &execErr) {
+			t.Fatalf("%v: got %v (%T) expected ExecError", tt.vm.Name(), err, err)
+		}
+		if execErr.ExitCode != tt.exitCode {
+			t.Errorf("%v: exit code: got %d expected %d", tt.vm.Name(), execErr.ExitCode, tt.exitCode)
+		}
+		if execErr.Signal != tt.signal {
+			t.Errorf("%v: signal: got %q expected %q", tt.vm.Name(), execErr.Signal, tt.signal)
+		}
+		if execErr.Stderr != tt.stderr {
+			t.Errorf("%v: stderr: got %q expected %q", tt.vm.Name(), execErr.Stderr, tt.stderr)
+		}
+		if !strings.Contains(execErr.Cmd, "test.json") {
+			t.Errorf("%v: cmd: got %q expected the test path in it", tt.vm.Name(), execErr.Cmd)
+		}
+	}
+	// Success is not an error
+	vm := NewGethEVM(fakeBinary(t, `echo '{"stateRoot":"0x00"}' >&2`), "geth")
+	if _, err := vm.RunStateTest("test.json", io.Discard, false); err != nil {
+		t.Errorf("got %v expected no error", err)
+	}
+}
+
+func TestTailBuffer(t *testing.T) {
+	tail := new(tailBuffer)
+	tail.Write([]byte(strings.Repeat("a", stderrTailSize)))
+	tail.Write([]byte("bcd"))
+	if have, want := string(tail.buf), strings.Repeat("a", stderrTailSize-3)+"bcd"; have != want {
+		t.Errorf("got %d bytes ending in %q expected %d bytes ending in bcd", len(have), have[len(have)-5:], len(want))
+	}
+}
+
+func TestNonTraceWriter(t *testing.T) {
+	var (
+		input = `{"pc":0,"op":96,"depth":1}` + "\n" + "fatal: bad\n" + `{"pc":2,"op":80,"depth":1}` + "\n" + `{"stateRoot":"0x01"}` + "\n"
+		want  = "fatal: bad\n" + `{"stateRoot":"0x01"}` + "\n"
+	)
+	// The result does not depend on how the input is split up
+	for _, size := range []int{1, 3, 7, len(input)} {
+		tail := new(tailBuffer)
+		w := &nonTraceWriter{tail: tail}
+		for data := []byte(input); len(data) > 0; {
+			n := min(size, len(data))
+			w.Write(data[:n])
+			data = data[n:]
+		}
+		if have := string(tail.buf); have != want {
+			t.Errorf("size %d: got %q expected %q", size, have, want)
+		}
+	}
+}
+
+func TestExecErrorTraceTail(t *testing.T) {
+	// The trace on stderr does not push the error out of the tail
+	bin := fakeBinary(t, `i=0
+while [ $i -lt 100 ]; do
+	echo '{"pc":0,"op":80,"gas":"0x1","gasCost":"0x2","memSize":0,"stack":["0x2"],"depth":1,"refund":0,"opName":"POP"}' >&2
+	i=$((i+1))
+done
+echo 'fatal: bad test' >&2
+exit 2`)
+	_, err := NewGethEVM(bin, "geth").RunStateTest("test.json", io.Discard, false)
+	var execErr *ExecError
+	if !errors.As(err, &execErr) {
+		t.Fatalf("got %v expected an ExecError", err)
+	}
+	if have, want := execErr.Stderr, "fatal: bad test\n"; have != want {
+		t.Errorf("got %q expected %q", have, want)
+	}
+}
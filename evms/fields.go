@@ -0,0 +1,143 @@
+// Copyright 2024 Martin Holst Swende
+// This file is part of the goevmlab library.
+//
+// The library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the goevmlab library. If not, see <http://www.gnu.org/licenses/>.
+
+package evms
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+
+	"github.com/theQRL/go-zond/zond/tracers/logger"
+)
+
+// fieldFilter is a writer which drops all but the selected fields from the
+// steps of a normalized trace. Other lines are written verbatim.
+type fieldFilter struct {
+	out    io.Writer
+	fields []string
+	buf    []byte // incomplete line
+}
+
+// newFieldFilter wraps the writer in a fieldFilter, unless no fields are
+// selected. A TraceSink, which receives the steps unmarshalled, is wrapped in
+// a sinkFilter instead.
+func newFieldFilter(out io.Writer, fields []string) io.Writer {
+	if len(fields) == 0 {
+		return out
+	}
+	if sink, ok := out.(TraceSink); ok {
+		selected := make(map[string]bool)
+		for _, field := range fields {
+			selected[field] = true
+		}
+		return &sinkFilter{Writer: out, sink: sink, fields: selected}
+	}
+	return &fieldFilter{out: out, fields: fields}
+}
+
+// Flush flushes the wrapped writer, if it buffers, so that the filter does
+// not hide it from writeRoot.
+func (f *fieldFilter) Flush() error {
+	if fl, ok := f.out.(flusher); ok {
+		return fl.Flush()
+	}
+	return nil
+}
+
+func (f *fieldFilter) Write(p []byte) (int, error) {
+	f.buf = append(f.buf, p...)
+	for {
+		i := bytes.IndexByte(f.buf, '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		if _, err := f.out.Write(f.filter(f.buf[:i+1])); err != nil {
+			return 0, err
+		}
+		f.buf = append(f.buf[:0], f.buf[i+1:]...)
+	}
+}
+
+// filter returns the (newline-terminated) line, with only the selected
+// fields if it is a step. The depth is always retained.
+func (f *fieldFilter) filter(line []byte) []byte {
+	if !bytes.HasPrefix(line, []byte(`{"depth":`)) {
+		return line
+	}
+	var step map[string]json.RawMessage
+	if err := json.Unmarshal(line, &step); err != nil {
+		return line
+	}
+	b := append(make([]byte, 0, len(line)), `{"depth":`...)
+	b = append(b, step["depth"]...)
+	for _, field := range f.fields {
+		if v, ok := step[field]; ok && field != "depth" {
+			b = append(b, ',', '"')
+			b = append(b, field...)
+			b = append(b, '"', ':')
+			b = append(b, v...)
+		}
+	}
+	return append(b, '}', '\n')
+}
+
+// sinkFilter is a TraceSink which zeroes all but the selected fields of the
+// steps, before delivering them to the wrapped sink.
+type sinkFilter struct {
+	io.Writer // the wrapped sink
+	sink      TraceSink
+	fields    map[string]bool
+}
+
+// Step implements TraceSink. The step is copied, since it must not be
+// modified.
+func (f *sinkFilter) Step(log *logger.StructLog) {
+	step := *log
+	if !f.fields["pc"] {
+		step.Pc = 0
+	}
+	if !f.fields["gas"] {
+		step.Gas = 0
+	}
+	if !f.fields["op"] {
+		step.Op = 0
+	}
+	if !f.fields["gasCost"] {
+		step.GasCost = 0
+	}
+	if !f.fields["memorySize"] {
+		step.MemorySize = 0
+	}
+	if !f.fields["refund"] {
+		step.RefundCounter = 0
+	}
+	if !f.fields["returnData"] {
+		step.ReturnData = nil
+	}
+	if !f.fields["stack"] {
+		step.Stack = nil
+	}
+	if !f.fields["error"] {
+		step.Err = nil
+	}
+	f.sink.Step(&step)
+}
+
+// Root implements TraceSink.
+func (f *sinkFilter) Root(root string) {
+	f.sink.Root(root)
+}
@@ -0,0 +1,89 @@
+// Copyright 2024 Martin Holst Swende
+// This file is part of the goevmlab library.
+//
+// The library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the goevmlab library. If not, see <http://www.gnu.org/licenses/>.
+
+package evms
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWithFields(t *testing.T) {
+	var (
+		a = NewMockVM("a", mockTrace(10, 10))
+		b = NewMockVM("b", mockTrace(10, 3)) // differs in gas
+	)
+	res, err := Compare("test.json", []Evm{a, b})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Consensus {
+		t.Fatal("expected the gas to differ")
+	}
+	for _, vm := range []Evm{a, b} {
+		Configure(vm, WithFields("pc", "op"))
+	}
+	if res, err = Compare("test.json", []Evm{a, b}); err != nil {
+		t.Fatal(err)
+	}
+	if !res.Consensus {
+		t.Errorf("expected consensus, got %v", res.Divergences)
+	}
+	out := new(bytes.Buffer)
+	if _, err := a.RunStateTest("test.json", out, false); err != nil {
+		t.Fatal(err)
+	}
+	lines := bytes.Split(out.Bytes(), []byte("\n"))
+	if have, want := string(lines[1]), `{"depth":1,"pc":1,"op":91}`; have != want {
+		t.Errorf("got %v expected %v", have, want)
+	}
+	// Other lines are left alone
+	if have, want := string(lines[10]), `{"stateRoot":"0xa2b3391f7a85bf1ad08dc541a1b99da3c591c156351391f26ec88c557ff12134"}`; have != want {
+		t.Errorf("got %v expected %v", have, want)
+	}
+}
+
+// fakeGethTrace creates a fake geth, which outputs a trace of two steps.
+func fakeGethTrace(t *testing.T) Evm {
+	t.Helper()
+	bin := fakeBinary(t, `echo '{"pc":0,"op":96,"gas":"0xb4213","gasCost":"0x3","memSize":0,"stack":[],"depth":1,"refund":0,"opName":"PUSH1"}' >&2
+echo '{"pc":2,"op":80,"gas":"0xb4210","gasCost":"0x2","memSize":0,"stack":["0x2"],"depth":1,"refund":0,"opName":"POP"}' >&2
+echo '{"stateRoot":"0x01"}' >&2`)
+	return Configure(NewGethEVM(bin, "geth"), WithFields("pc", "op"))
+}
+
+func TestWithFieldsBuffered(t *testing.T) {
+	out := new(bytes.Buffer)
+	if _, err := fakeGethTrace(t).RunStateTest("test.json", NewOutput(out, WithBufferedOutput(64*1024)), false); err != nil {
+		t.Fatal(err)
+	}
+	// The buffer is flushed through the filter at the end of the trace
+	if have, want := out.String(), "{\"depth\":1,\"pc\":0,\"op\":96}\n{\"depth\":1,\"pc\":2,\"op\":80}\n{\"stateRoot\":\"0x01\"}\n"; have != want {
+		t.Errorf("got %q expected %q", have, want)
+	}
+}
+
+func TestWithFieldsSink(t *testing.T) {
+	out := new(bytes.Buffer)
+	if _, err := fakeGethTrace(t).RunStateTest("test.json", NewMultiSink(NewWriterSink(out)), false); err != nil {
+		t.Fatal(err)
+	}
+	// The sink receives the other fields zeroed
+	lines := bytes.Split(out.Bytes(), []byte("\n"))
+	if have, want := string(lines[1]), `{"depth":1,"pc":2,"gas":0,"op":80,"opName":"POP","stack":[]}`; have != want {
+		t.Errorf("got %v expected %v", have, want)
+	}
+}
@@ -0,0 +1,174 @@
+// Copyright 2024 Martin Holst Swende
+// This file is part of the goevmlab library.
+//
+// The library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the goevmlab library. If not, see <http://www.gnu.org/licenses/>.
+
+package evms
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/rgeraldes24/goevmlab/fuzzing"
+	"github.com/theQRL/go-zond/common"
+)
+
+// TestForks returns the (sorted) forks which the statetest at the given path
+// covers, i.e. the keys of the post-states of all tests in the file.
+func TestForks(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var tests map[string]struct {
+		Post map[string]json.RawMessage `json:"post"`
+	}
+	if err := json.Unmarshal(data, &tests); err != nil {
+		return nil, fmt.Errorf("%v: %w", path, err)
+	}
+	var (
+		seen  = make(map[string]bool)
+		forks []string
+	)
+	for _, test := range tests {
+		for fork := range test.Post {
+			if !seen[fork] {
+				seen[fork] = true
+				forks = append(forks, fork)
+			}
+		}
+	}
+	sort.Strings(forks)
+	return forks, nil
+}
+
+// TestFork returns the fork which the statetest at the given path targets.
+// An error is returned unless the test covers exactly one fork.
+func TestFork(path string) (string, error) {
+	forks, err := TestForks(path)
+	if err != nil {
+		return "", err
+	}
+	switch len(forks) {
+	case 0:
+		return "", fmt.Errorf("%v: no forks found", path)
+	case 1:
+		return forks[0], nil
+	default:
+		return "", fmt.Errorf("%v: multiple forks: %v", path, strings.Join(forks, ", "))
+	}
+}
+
+// ExpectedResult is the post-state which a filled statetest expects.
+type ExpectedResult struct {
+	Root common.Hash `json:"hash"`
+	Logs common.Hash `json:"logs"`
+}
+
+// ParseExpected returns the expected results of the statetest at the given
+// path, keyed by fork. If there are several post-states for a fork, the first
+// one is used. An error is returned if the tests in the file expect different
+// results for the same fork.
+func ParseExpected(path string) (map[string]ExpectedResult, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var tests map[string]struct {
+		Post map[string][]ExpectedResult `json:"post"`
+	}
+	if err := json.Unmarshal(data, &tests); err != nil {
+		return nil, fmt.Errorf("%v: %w", path, err)
+	}
+	expected := make(map[string]ExpectedResult)
+	for _, test := range tests {
+		for fork, posts := range test.Post {
+			if len(posts) == 0 {
+				continue
+			}
+			if prev, ok := expected[fork]; ok && prev != posts[0] {
+				return nil, fmt.Errorf("%v: conflicting results for %v", path, fork)
+			}
+			expected[fork] = posts[0]
+		}
+	}
+	return expected, nil
+}
+
+// CheckAgainstExpected runs the filled statetest at the given path on the vm,
+// and reports whether the resulting stateroot matches the one which the test
+// expects for its fork. The test must target exactly one fork.
+func CheckAgainstExpected(vm Evm, path string) (bool, error) {
+	fork, err := TestFork(path)
+	if err != nil {
+		return false, err
+	}
+	expected, err := ParseExpected(path)
+	if err != nil {
+		return false, err
+	}
+	want, ok := expected[fork]
+	if !ok || want.Root == (common.Hash{}) {
+		return false, fmt.Errorf("%v: no expected root for %v", path, fork)
+	}
+	root, _, err := vm.GetStateRoot(path)
+	if err != nil {
+		return false, err
+	}
+	return common.HexToHash(root) == want.Root, nil
+}
+
+// ForkChange is the first difference in the output of a vm, between a test
+// executed before and after a fork transition.
+type ForkChange struct {
+	VM         string
+	Divergence // A is the output before the fork, B the output after it
+}
+
+// CompareForks executes the tests, which are expected to be identical except
+// for the fork (see fuzzing.GstMaker.ForkPair), on each of the vms. For each
+// vm whose output differs between the two, the first difference is returned.
+func CompareForks(vms []Evm, before, after *fuzzing.GeneralStateTest) ([]ForkChange, error) {
+	var paths []string
+	for _, test := range []*fuzzing.GeneralStateTest{before, after} {
+		path, cleanup, err := fuzzing.WriteTempTest(test)
+		if err != nil {
+			return nil, err
+		}
+		defer cleanup()
+		paths = append(paths, path)
+	}
+	var changes []ForkChange
+	for _, vm := range vms {
+		var scanners []*bufio.Scanner
+		for _, path := range paths {
+			out := new(bytes.Buffer)
+			if _, err := vm.RunStateTest(path, out, false); err != nil {
+				return nil, fmt.Errorf("%v: %w", vm.Name(), err)
+			}
+			scanner := bufio.NewScanner(out)
+			scanner.Buffer(make([]byte, 1024*1024), 32*1024*1024)
+			scanners = append(scanners, scanner)
+		}
+		if div := firstDivergence(scanners[0], scanners[1]); div != nil {
+			changes = append(changes, ForkChange{VM: vm.Name(), Divergence: *div})
+		}
+	}
+	return changes, nil
+}
@@ -0,0 +1,162 @@
+// Copyright 2024 Martin Holst Swende
+// This file is part of the goevmlab library.
+//
+// The library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the goevmlab library. If not, see <http://www.gnu.org/licenses/>.
+
+package evms
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rgeraldes24/goevmlab/fuzzing"
+	"github.com/rgeraldes24/goevmlab/ops"
+	"github.com/rgeraldes24/goevmlab/program"
+	"github.com/theQRL/go-zond/common"
+)
+
+func TestTestForks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "multi.json")
+	data := `{
+ "a": {"post": {"Shanghai": [], "Cancun": []}},
+ "b": {"post": {"Prague": [], "Shanghai": []}}
+}`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	forks, err := TestForks(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have, want := fmt.Sprint(forks), "[Cancun Prague Shanghai]"; have != want {
+		t.Errorf("got %v expected %v", have, want)
+	}
+	if _, err := TestFork(path); err == nil {
+		t.Errorf("expected error for multi-fork test")
+	}
+	// A single-fork test
+	fork, err := TestFork(filepath.Join("testdata", "cases", "00000006-naivefuzz-0.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fork != "London" {
+		t.Errorf("got %v expected %v", fork, "London")
+	}
+}
+
+func TestParseExpected(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "filled.json")
+	h := func(n int) string { return fmt.Sprintf(`"0x%064x"`, n) }
+	data := fmt.Sprintf(`{
+ "a": {"post": {
+  "Shanghai": [{"hash": %v, "logs": %v, "indexes": {"data": 0, "gas": 0, "value": 0}}],
+  "Cancun": [
+   {"hash": %v, "logs": %v, "indexes": {"data": 0, "gas": 0, "value": 0}},
+   {"hash": %v, "logs": %v, "indexes": {"data": 1, "gas": 0, "value": 0}}
+  ]
+ }}
+}`, h(1), h(2), h(3), h(4), h(5), h(6))
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	expected, err := ParseExpected(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for fork, want := range map[string]ExpectedResult{
+		"Shanghai": {common.HexToHash("0x01"), common.HexToHash("0x02")},
+		"Cancun":   {common.HexToHash("0x03"), common.HexToHash("0x04")},
+	} {
+		if have := expected[fork]; have != want {
+			t.Errorf("%v: got %v expected %v", fork, have, want)
+		}
+	}
+	if len(expected) != 2 {
+		t.Errorf("got %d forks expected 2", len(expected))
+	}
+	// A filled test from the testdata
+	expected, err = ParseExpected(filepath.Join("testdata", "cases", "statetest_filled.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have, want := expected["Byzantium"].Root, common.HexToHash("0xa2b3391f7a85bf1ad08dc541a1b99da3c591c156351391f26ec88c557ff12134"); have != want {
+		t.Errorf("got %v expected %v", have, want)
+	}
+}
+
+func TestCompareForks(t *testing.T) {
+	dest := common.HexToAddress("0xc0de")
+	gst := fuzzing.BasicStateTest("Shanghai")
+	p := program.NewProgram()
+	p.Tstore(0, 1)
+	p.Push(0)
+	p.Op(ops.TLOAD)
+	p.Push(0)
+	p.Op(ops.SSTORE)
+	gst.SetCode(dest, p.Bytecode())
+	fuzzing.AddTransaction(&dest, gst)
+	before, after := gst.ForkPair("tstore", "Shanghai", "Cancun")
+
+	var (
+		push0   = `{"depth":1,"pc":0,"gas":100,"op":96,"opName":"PUSH1","stack":[]}`
+		push1   = `{"depth":1,"pc":2,"gas":97,"op":96,"opName":"PUSH1","stack":["0x1"]}`
+		tstore  = `{"depth":1,"pc":4,"gas":94,"op":93,"opName":"TSTORE","stack":["0x1","0x0"]}`
+		invalid = `{"depth":1,"pc":4,"gas":94,"op":93,"opName":"TSTORE","stack":["0x1","0x0"],"error":"invalid opcode: TSTORE"}`
+		root    = `{"stateRoot":"0xa2b3391f7a85bf1ad08dc541a1b99da3c591c156351391f26ec88c557ff12134"}`
+	)
+	// The mock executes like a vm knowing both forks
+	forkAware := NewMockVM("forkaware", nil)
+	forkAware.OutputFor = func(path string) []byte {
+		if fork, _ := TestFork(path); fork == "Cancun" {
+			return []byte(strings.Join([]string{push0, push1, tstore, root}, "\n"))
+		}
+		return []byte(strings.Join([]string{push0, push1, invalid, root}, "\n"))
+	}
+	// The other mock does not care about forks
+	oblivious := NewMockVM("oblivious", []byte(strings.Join([]string{push0, push1, tstore, root}, "\n")))
+
+	changes, err := CompareForks([]Evm{forkAware, oblivious}, before, after)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 {
+		t.Fatalf("got %d changes expected 1: %v", len(changes), changes)
+	}
+	change := changes[0]
+	if change.VM != "forkaware" || change.Step != 2 {
+		t.Errorf("got %v at step %d expected forkaware at step 2", change.VM, change.Step)
+	}
+	if !strings.Contains(change.A, "invalid opcode") || strings.Contains(change.B, "error") {
+		t.Errorf("expected failure before the fork only, got\n%v\n%v", change.A, change.B)
+	}
+}
+
+func TestCheckAgainstExpected(t *testing.T) {
+	path := filepath.Join("testdata", "cases", "statetest_filled.json")
+	agreeing := NewMockVM("agreeing", []byte(`{"stateRoot":"0xa2b3391f7a85bf1ad08dc541a1b99da3c591c156351391f26ec88c557ff12134"}`))
+	if ok, err := CheckAgainstExpected(agreeing, path); err != nil || !ok {
+		t.Errorf("got %v (err %v) expected agreement", ok, err)
+	}
+	disagreeing := NewMockVM("disagreeing", []byte(`{"stateRoot":"0x0000000000000000000000000000000000000000000000000000000000000bad"}`))
+	if ok, err := CheckAgainstExpected(disagreeing, path); err != nil || ok {
+		t.Errorf("got %v (err %v) expected disagreement", ok, err)
+	}
+	// An unfilled test has nothing to compare against
+	if _, err := CheckAgainstExpected(agreeing, filepath.Join("testdata", "cases", "statetest1.json")); err == nil {
+		t.Errorf("expected error for unfilled test")
+	}
+}
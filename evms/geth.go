@@ -19,6 +19,7 @@ package evms
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -34,6 +35,7 @@ import (
 type GethEVM struct {
 	path string
 	name string // in case multiple instances are used
+	process
 
 	// Some metrics
 	stats *VmStat
@@ -61,7 +63,7 @@ func (evm *GethEVM) Name() string {
 // even in success-case
 func (evm *GethEVM) GetStateRoot(path string) (root, command string, err error) {
 	// In this mode, we can run it without tracing
-	cmd := exec.Command(evm.path, "statetest", path)
+	cmd := evm.execCommand(evm.path, "statetest", path)
 	data, err := cmd.Output()
 	if err != nil {
 		return "", cmd.String(), err
@@ -84,38 +86,61 @@ func (evm *GethEVM) ParseStateRoot(data []byte) (string, error) {
 	return string(data[start+14 : end]), nil
 }
 
+func (evm *GethEVM) command(ctx context.Context, path string, speedTest bool) *exec.Cmd {
+	if speedTest {
+		return evm.execCommandContext(ctx, evm.path, "--nomemory", "--noreturndata", "--nostack", "statetest", path)
+	}
+	args := append(evm.traceFlags(), "statetest", path)
+	return evm.execCommandContext(ctx, evm.path, args...)
+}
+
+// CommandFor returns the command RunStateTest would use for the test.
+func (evm *GethEVM) CommandFor(path string, speedTest bool) string {
+	return evm.command(context.Background(), path, speedTest).String()
+}
+
 // RunStateTest implements the Evm interface
 func (evm *GethEVM) RunStateTest(path string, out io.Writer, speedTest bool) (*tracingResult, error) {
+	return evm.RunStateTestContext(context.Background(), path, out, speedTest)
+}
+
+// RunStateTestContext implements the CancellableEvm interface. The process
+// is killed if the context is cancelled.
+func (evm *GethEVM) RunStateTestContext(ctx context.Context, path string, out io.Writer, speedTest bool) (*tracingResult, error) {
+	out = evm.filterFields(out)
 	var (
 		t0     = time.Now()
 		stderr io.ReadCloser
+		tail   *tailBuffer
 		err    error
-		cmd    *exec.Cmd
+		cmd    = evm.command(ctx, path, speedTest)
 	)
-	if speedTest {
-		cmd = exec.Command(evm.path, "--nomemory", "--noreturndata", "--nostack", "statetest", path)
-	} else {
-		cmd = exec.Command(evm.path, "--json", "--noreturndata", "--nomemory", "statetest", path)
-	}
-	if stderr, err = cmd.StderrPipe(); err != nil {
+	if stderr, err = evm.tracePipe(cmd, StderrStream); err != nil {
 		return &tracingResult{Cmd: cmd.String()}, err
 	}
+	stderr, tail = captureStderr(cmd, stderr)
 	if err = cmd.Start(); err != nil {
 		return &tracingResult{Cmd: cmd.String()}, err
 	}
 	// copy everything to the given writer
-	evm.Copy(out, stderr)
-	err = cmd.Wait()
+	_, summary := evm.copyUntilEnd(out, stderr)
+	err = newExecError(cmd, tail, cmd.Wait())
 	// release resources
-	duration, slow := evm.stats.TraceDone(t0)
+	duration, slow := evm.stats.TraceDone(t0, cmd.String())
 
 	return &tracingResult{
 		Slow:     slow,
 		ExecTime: duration,
 		Cmd:      cmd.String(),
+		Output:   summary.Output,
+		Error:    summary.Error,
+		LogsHash: summary.LogsHash,
 	}, err
 }
 
+// reportsOutput implements the outputReporter interface.
+func (evm *GethEVM) reportsOutput() {}
+
 func (vm *GethEVM) Close() {
 }
 
@@ -126,12 +151,23 @@ func (evm *GethEVM) Copy(out io.Writer, input io.Reader) {
 }
 
 // copyUntilEnd reads from the reader, does some geth-specific filtering and
-// outputs items onto the channel
-func (evm *GethEVM) copyUntilEnd(out io.Writer, input io.Reader) stateRoot {
+// outputs items onto the channel. It returns the stateroot, and the execution
+// summary reported by the client.
+func (evm *GethEVM) copyUntilEnd(out io.Writer, input io.Reader) (stateRoot, execSummary) {
+	stateRoot, summary := evm.copySteps(out, input)
+	if err := writeRoot(out, stateRoot); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing to out: %v\n", err)
+	}
+	return stateRoot, summary
+}
+
+// copySteps is like copyUntilEnd, but does not output the stateroot.
+func (evm *GethEVM) copySteps(out io.Writer, input io.Reader) (stateRoot, execSummary) {
 	buf := bufferPool.Get().([]byte)
 	//lint:ignore SA6002: argument should be pointer-like to avoid allocations.
 	defer bufferPool.Put(buf)
 	var stateRoot stateRoot
+	var summary execSummary
 	scanner := bufio.NewScanner(input)
 	scanner.Buffer(buf, 32*1024*1024)
 	// When geth encounters an error, it may already have spat out the info, prematurely.
@@ -143,8 +179,9 @@ func (evm *GethEVM) copyUntilEnd(out io.Writer, input io.Reader) stateRoot {
 			prev = current
 			return
 		}
-		data := FastMarshal(prev)
-		if _, err := out.Write(append(data, '\n')); err != nil {
+		evm.stats.CountOp(byte(prev.Op))
+		evm.trimStep(prev)
+		if err := writeStep(out, prev); err != nil {
 			fmt.Fprintf(os.Stderr, "Error writing to out: %v\n", err)
 		}
 		if current == nil { // final flush
@@ -181,6 +218,7 @@ func (evm *GethEVM) copyUntilEnd(out io.Writer, input io.Reader) stateRoot {
 			if stateRoot.StateRoot == "" {
 				_ = json.Unmarshal(data, &stateRoot)
 			}
+			summary.update(data)
 			// If we have a stateroot, we're done
 			if len(stateRoot.StateRoot) > 0 {
 				break
@@ -195,13 +233,14 @@ func (evm *GethEVM) copyUntilEnd(out io.Writer, input io.Reader) stateRoot {
 		yield(&elem)
 	}
 	yield(nil)
-	root, _ := json.Marshal(stateRoot)
-	if _, err := out.Write(append(root, '\n')); err != nil {
-		fmt.Fprintf(os.Stderr, "Error writing to out: %v\n", err)
-	}
-	return stateRoot
+	return stateRoot, summary
 }
 
 func (evm *GethEVM) Stats() []any {
 	return evm.stats.Stats()
 }
+
+// VmStats returns the metrics of the vm, shared by its instances.
+func (evm *GethEVM) VmStats() *VmStat {
+	return evm.stats
+}
@@ -17,6 +17,7 @@
 package evms
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"os/exec"
@@ -35,22 +36,47 @@ type GethBatchVM struct {
 
 func NewGethBatchVM(path, name string) *GethBatchVM {
 	return &GethBatchVM{
-		GethEVM: GethEVM{path, name, &VmStat{}},
+		GethEVM: GethEVM{path: path, name: name, stats: &VmStat{}},
 	}
 }
 
 func (evm *GethBatchVM) Instance(threadId int) Evm {
 	return &GethBatchVM{
 		GethEVM: GethEVM{
-			path:  evm.path,
-			name:  fmt.Sprintf("%v-%d", evm.name, threadId),
-			stats: evm.stats,
+			path:    evm.path,
+			name:    fmt.Sprintf("%v-%d", evm.name, threadId),
+			stats:   evm.stats,
+			process: evm.process,
 		},
 	}
 }
 
+func (evm *GethBatchVM) batchCommand(speedTest bool) *exec.Cmd {
+	if speedTest {
+		return evm.execCommand(evm.path, "--nomemory", "--noreturndata", "--nostack", "statetest")
+	}
+	return evm.execCommand(evm.path, append(evm.traceFlags(), "statetest")...)
+}
+
+// CommandFor returns the command of the 'master' process which RunStateTest
+// uses. The test path is not part of it, but passed via stdin.
+func (evm *GethBatchVM) CommandFor(path string, speedTest bool) string {
+	if evm.cmd != nil {
+		return evm.cmd.String()
+	}
+	return evm.batchCommand(speedTest).String()
+}
+
 // RunStateTest implements the Evm interface
 func (evm *GethBatchVM) RunStateTest(path string, out io.Writer, speedTest bool) (*tracingResult, error) {
+	return evm.RunStateTestContext(context.Background(), path, out, speedTest)
+}
+
+// RunStateTestContext implements the CancellableEvm interface. If the context
+// is cancelled, the 'master' process is killed, and the next test starts a
+// new one.
+func (evm *GethBatchVM) RunStateTestContext(ctx context.Context, path string, out io.Writer, speedTest bool) (*tracingResult, error) {
+	out = evm.filterFields(out)
 	var (
 		t0     = time.Now()
 		err    error
@@ -59,12 +85,8 @@ func (evm *GethBatchVM) RunStateTest(path string, out io.Writer, speedTest bool)
 		stdin  io.WriteCloser
 	)
 	if evm.cmd == nil {
-		if speedTest {
-			cmd = exec.Command(evm.path, "--nomemory", "--noreturndata", "--nostack", "statetest")
-		} else {
-			cmd = exec.Command(evm.path, "--json", "--noreturndata", "--nomemory", "statetest")
-		}
-		if stdout, err = cmd.StderrPipe(); err != nil {
+		cmd = evm.batchCommand(speedTest)
+		if stdout, err = evm.tracePipe(cmd, StderrStream); err != nil {
 			return &tracingResult{Cmd: cmd.String()}, err
 		}
 		if stdin, err = cmd.StdinPipe(); err != nil {
@@ -80,14 +102,25 @@ func (evm *GethBatchVM) RunStateTest(path string, out io.Writer, speedTest bool)
 	evm.mu.Lock()
 	defer evm.mu.Unlock()
 	_, _ = evm.stdin.Write([]byte(fmt.Sprintf("%v\n", path)))
+	master := evm.cmd
+	stop := context.AfterFunc(ctx, func() { _ = master.Process.Kill() })
 	// copy everything for the _current_ statetest to the given writer
-	evm.copyUntilEnd(out, evm.stdout)
+	_, summary := evm.copyUntilEnd(out, evm.stdout)
+	if !stop() {
+		evm.stdin.Close()
+		_ = master.Wait()
+		evm.cmd = nil
+		return &tracingResult{Cmd: master.String()}, ctx.Err()
+	}
 	// release resources, handle error but ignore non-zero exit codes
-	duration, slow := evm.stats.TraceDone(t0)
+	duration, slow := evm.stats.TraceDone(t0, evm.cmd.String())
 	return &tracingResult{
 			Slow:     slow,
 			ExecTime: duration,
-			Cmd:      evm.cmd.String()},
+			Cmd:      evm.cmd.String(),
+			Output:   summary.Output,
+			Error:    summary.Error,
+			LogsHash: summary.LogsHash},
 		nil
 }
 
@@ -102,7 +135,7 @@ func (vm *GethBatchVM) Close() {
 
 func (evm *GethBatchVM) GetStateRoot(path string) (root, command string, err error) {
 	if evm.cmd == nil {
-		evm.cmd = exec.Command(evm.path)
+		evm.cmd = evm.execCommand(evm.path)
 		if evm.stdout, err = evm.cmd.StdoutPipe(); err != nil {
 			return "", evm.cmd.String(), err
 		}
@@ -116,6 +149,6 @@ func (evm *GethBatchVM) GetStateRoot(path string) (root, command string, err err
 	evm.mu.Lock()
 	defer evm.mu.Unlock()
 	_, _ = evm.stdin.Write([]byte(fmt.Sprintf("%v\n", path)))
-	sRoot := evm.copyUntilEnd(io.Discard, evm.stdout)
+	sRoot, _ := evm.copyUntilEnd(io.Discard, evm.stdout)
 	return sRoot.StateRoot, evm.cmd.String(), nil
 }
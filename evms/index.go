@@ -0,0 +1,115 @@
+// Copyright 2024 Martin Holst Swende
+// This file is part of the goevmlab library.
+//
+// The library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the goevmlab library. If not, see <http://www.gnu.org/licenses/>.
+
+package evms
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/theQRL/go-zond/zond/tracers/logger"
+)
+
+// TraceIndex provides random access to the steps of a trace. Only the
+// location of each step in a backing file is held in memory.
+type TraceIndex struct {
+	file  *os.File
+	temp  bool // Whether the backing file was created by the index
+	steps []traceSpan
+}
+
+// traceSpan is the location of a step in the backing file.
+type traceSpan struct {
+	offset int64
+	size   int
+}
+
+// IndexTrace reads a trace and indexes the steps in it. Lines which are not
+// steps are skipped. If r is a file, the file itself backs the index, and it
+// must not be modified or closed while the index is in use. Otherwise, the
+// trace is copied to a temporary file, removed on Close.
+func IndexTrace(r io.Reader) (*TraceIndex, error) {
+	var (
+		idx    = new(TraceIndex)
+		offset int64
+	)
+	if f, ok := r.(*os.File); ok {
+		pos, err := f.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return nil, err
+		}
+		idx.file, offset = f, pos
+	} else {
+		f, err := os.CreateTemp("", "goevmlab-trace-*.jsonl")
+		if err != nil {
+			return nil, err
+		}
+		idx.file, idx.temp = f, true
+		r = io.TeeReader(r, f)
+	}
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadBytes('\n')
+		if len(line) > 0 {
+			var step traceStep
+			if json.Unmarshal(line, &step) == nil && step.Op != nil {
+				idx.steps = append(idx.steps, traceSpan{offset, len(line)})
+			}
+			offset += int64(len(line))
+		}
+		if err == io.EOF {
+			return idx, nil
+		}
+		if err != nil {
+			idx.Close()
+			return nil, err
+		}
+	}
+}
+
+// Len returns the number of steps in the trace.
+func (idx *TraceIndex) Len() int {
+	return len(idx.steps)
+}
+
+// Step reads the i:th step of the trace from the backing file.
+func (idx *TraceIndex) Step(i int) (*logger.StructLog, error) {
+	if i < 0 || i >= len(idx.steps) {
+		return nil, fmt.Errorf("step %d out of range, trace has %d steps", i, len(idx.steps))
+	}
+	span := idx.steps[i]
+	data := make([]byte, span.size)
+	if _, err := idx.file.ReadAt(data, span.offset); err != nil {
+		return nil, err
+	}
+	var log logger.StructLog
+	if err := json.Unmarshal(data, &log); err != nil {
+		return nil, fmt.Errorf("step %d: %w", i, err)
+	}
+	return &log, nil
+}
+
+// Close releases the backing file, if it was created by the index.
+func (idx *TraceIndex) Close() error {
+	if !idx.temp {
+		return nil
+	}
+	idx.file.Close()
+	return os.Remove(idx.file.Name())
+}
@@ -0,0 +1,92 @@
+// Copyright 2024 Martin Holst Swende
+// This file is part of the goevmlab library.
+//
+// The library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the goevmlab library. If not, see <http://www.gnu.org/licenses/>.
+
+package evms
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/rand"
+	"os"
+	"testing"
+
+	"github.com/theQRL/go-zond/zond/tracers/logger"
+)
+
+func TestIndexTrace(t *testing.T) {
+	const path = "testdata/traces/00000006-naivefuzz-0.json.geth.stderr.txt"
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want []*logger.StructLog
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		var step traceStep
+		if json.Unmarshal(line, &step) != nil || step.Op == nil {
+			continue
+		}
+		var log logger.StructLog
+		if err := json.Unmarshal(line, &log); err != nil {
+			t.Fatal(err)
+		}
+		want = append(want, &log)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	fromFile, err := IndexTrace(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromReader, err := IndexTrace(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, idx := range map[string]*TraceIndex{"file": fromFile, "reader": fromReader} {
+		if have := idx.Len(); have != len(want) {
+			t.Fatalf("%v: got %d steps expected %d", name, have, len(want))
+		}
+		for _, i := range rand.New(rand.NewSource(1)).Perm(len(want)) {
+			have, err := idx.Step(i)
+			if err != nil {
+				t.Fatalf("%v: step %d: %v", name, i, err)
+			}
+			if have.Pc != want[i].Pc || have.Op != want[i].Op || have.Gas != want[i].Gas || have.Depth != want[i].Depth {
+				t.Errorf("%v: step %d: got pc %d op %v gas %d, expected pc %d op %v gas %d", name, i,
+					have.Pc, have.Op, have.Gas, want[i].Pc, want[i].Op, want[i].Gas)
+			}
+		}
+		if _, err := idx.Step(len(want)); err == nil {
+			t.Errorf("%v: expected error for step out of range", name)
+		}
+	}
+	// The copy of the reader is removed on close
+	temp := fromReader.file.Name()
+	if err := fromReader.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(temp); !os.IsNotExist(err) {
+		t.Errorf("temp file not removed: %v", err)
+	}
+	if err := fromFile.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("trace removed: %v", err)
+	}
+}
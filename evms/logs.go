@@ -0,0 +1,53 @@
+// Copyright 2024 Martin Holst Swende
+// This file is part of the goevmlab library.
+//
+// The library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the goevmlab library. If not, see <http://www.gnu.org/licenses/>.
+
+package evms
+
+import (
+	"fmt"
+	"io"
+)
+
+// CompareLogs executes the test on the vms, and returns whether they agree on
+// the hash of the logs, along with the hash reported by each vm. The hash is
+// read from the summary which the clients output at the end of the trace; the
+// vms which do not report it are left out. An error is returned if a vm fails
+// to run the test, or if fewer than two vms report the hash, since there is
+// nothing to compare then.
+func CompareLogs(vms []Evm, testPath string) (agree bool, hashes map[string]string, err error) {
+	var first string
+	agree = true
+	hashes = make(map[string]string)
+	for _, vm := range vms {
+		res, err := vm.RunStateTest(testPath, io.Discard, false)
+		if err != nil {
+			return false, nil, fmt.Errorf("%v: %w", vm.Name(), err)
+		}
+		if len(res.LogsHash) == 0 {
+			continue
+		}
+		if len(hashes) == 0 {
+			first = res.LogsHash
+		} else if res.LogsHash != first {
+			agree = false
+		}
+		hashes[vm.Name()] = res.LogsHash
+	}
+	if len(hashes) < 2 {
+		return false, hashes, fmt.Errorf("only %d of %d vms reported the logs hash", len(hashes), len(vms))
+	}
+	return agree, hashes, nil
+}
@@ -0,0 +1,84 @@
+// Copyright 2024 Martin Holst Swende
+// This file is part of the goevmlab library.
+//
+// The library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the goevmlab library. If not, see <http://www.gnu.org/licenses/>.
+
+package evms
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeBesu creates a fake besu binary, which reports the given logs hash.
+func fakeBesu(t *testing.T, name, logsHash string) *BesuVM {
+	t.Helper()
+	dir := t.TempDir()
+	out := filepath.Join(dir, "out.txt")
+	data := fmt.Sprintf(`{"output":"","gasUsed":"0xb8665","postHash":"0xad1024c87b5548e77c937aa50f72b6cb620d278f4dd79bae7f78f71ff75af458","postLogsHash":"%v","pass":false}`+"\n", logsHash)
+	if err := os.WriteFile(out, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	bin := filepath.Join(dir, "besu")
+	if err := os.WriteFile(bin, []byte("#!/bin/sh\ncat "+out+"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return NewBesuVM(bin, name)
+}
+
+func TestCompareLogs(t *testing.T) {
+	var (
+		hashA = "0x1dcc4de8dec75d7aab85b567b6ccd41ad312451b948a7413f0a142fd40d49347"
+		hashB = "0x2dcc4de8dec75d7aab85b567b6ccd41ad312451b948a7413f0a142fd40d49347"
+		a     = fakeBesu(t, "a", hashA)
+		b     = fakeBesu(t, "b", hashA)
+		c     = fakeBesu(t, "c", hashB)
+		// Geth-style clients report the hash at the end of the trace
+		geth = NewGethEVM(fakeBinary(t, `echo '{"output":"","gasUsed":"0x0","logsHash":"`+strings.ToUpper(hashA[2:])+`"}' >&2
+echo '{"stateRoot":"0x01"}' >&2`), "geth")
+		mock = NewMockVM("mock", nil) // does not report logs
+	)
+	agree, hashes, err := CompareLogs([]Evm{a, mock, geth}, "test.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !agree {
+		t.Errorf("expected agreement, got %v", hashes)
+	}
+	if len(hashes) != 2 || hashes["a"] != hashA || hashes[geth.Name()] != hashA {
+		t.Errorf("got %v expected %v for a and geth", hashes, hashA)
+	}
+	agree, hashes, err = CompareLogs([]Evm{a, b, c}, "test.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if agree {
+		t.Errorf("expected disagreement, got %v", hashes)
+	}
+	if have := hashes["c"]; have != hashB {
+		t.Errorf("got %v expected %v", have, hashB)
+	}
+	// Nothing is compared with a single hash
+	if _, _, err := CompareLogs([]Evm{a, mock}, "test.json"); err == nil {
+		t.Errorf("expected error with a single logs hash")
+	}
+	// A vm which fails to run is an error
+	broken := NewGethEVM(filepath.Join(t.TempDir(), "missing"), "broken")
+	if _, _, err := CompareLogs([]Evm{a, b, broken}, "test.json"); err == nil {
+		t.Errorf("expected error from a broken vm")
+	}
+}
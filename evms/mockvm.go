@@ -0,0 +1,176 @@
+// Copyright 2024 Martin Holst Swende
+// This file is part of the goevmlab library.
+//
+// The library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the goevmlab library. If not, see <http://www.gnu.org/licenses/>.
+
+package evms
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+// MockVM is an Evm which does not execute anything, but delivers a canned,
+// already normalized, output. It is meant for testing the machinery around
+// the VMs.
+type MockVM struct {
+	name   string
+	output []byte
+
+	// StepDelay is the time taken to deliver each line of output.
+	StepDelay time.Duration
+	// Output is the return data reported for every run.
+	Output string
+	// ExecTimes, if set, are reported as the execution times of consecutive
+	// runs, instead of the measured times. The list is cycled.
+	ExecTimes []time.Duration
+	// OutputFor, if set, gives the output for the test at the given path,
+	// instead of the canned output.
+	OutputFor func(path string) []byte
+
+	fields    []string // if set, the only fields retained in the steps
+	stats     *VmStat
+	lines     atomic.Uint64 // number of lines delivered by the last run
+	cancelled atomic.Bool   // whether the last run was cancelled
+	runs      atomic.Uint64 // number of completed runs
+}
+
+// NewMockVM creates a MockVM which delivers the given output for every test.
+func NewMockVM(name string, output []byte) *MockVM {
+	return &MockVM{
+		name:   name,
+		output: output,
+		stats:  new(VmStat),
+	}
+}
+
+func (evm *MockVM) Instance(int) Evm {
+	return evm
+}
+
+func (evm *MockVM) Name() string {
+	return evm.name
+}
+
+// GetStateRoot implements the Evm interface.
+func (evm *MockVM) GetStateRoot(path string) (root, command string, err error) {
+	root, err = evm.ParseStateRoot(evm.output)
+	return root, "mock " + path, err
+}
+
+// ParseStateRoot reads the stateroot from the normalized output.
+func (evm *MockVM) ParseStateRoot(data []byte) (string, error) {
+	lines := bytes.Split(bytes.TrimSpace(data), []byte("\n"))
+	var root stateRoot
+	if err := json.Unmarshal(lines[len(lines)-1], &root); err != nil || root.StateRoot == "" {
+		return "", fmt.Errorf("%v: no stateroot found", evm.Name())
+	}
+	return root.StateRoot, nil
+}
+
+// CommandFor implements the Evm interface.
+func (evm *MockVM) CommandFor(path string, speedTest bool) string {
+	return "mock " + path
+}
+
+// RunStateTest implements the Evm interface.
+func (evm *MockVM) RunStateTest(path string, out io.Writer, speedTest bool) (*tracingResult, error) {
+	return evm.RunStateTestContext(context.Background(), path, out, speedTest)
+}
+
+// RunStateTestContext implements the CancellableEvm interface.
+func (evm *MockVM) RunStateTestContext(ctx context.Context, path string, out io.Writer, speedTest bool) (*tracingResult, error) {
+	out = evm.filterFields(out)
+	var (
+		t0     = time.Now()
+		cmd    = evm.CommandFor(path, speedTest)
+		output = evm.output
+	)
+	if evm.OutputFor != nil {
+		output = evm.OutputFor(path)
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	evm.lines.Store(0)
+	evm.cancelled.Store(false)
+	for scanner.Scan() {
+		if evm.StepDelay > 0 {
+			time.Sleep(evm.StepDelay)
+		}
+		if ctx.Err() != nil {
+			evm.cancelled.Store(true)
+			return &tracingResult{Cmd: cmd}, ctx.Err()
+		}
+		if _, err := out.Write(append(scanner.Bytes(), '\n')); err != nil {
+			evm.cancelled.Store(ctx.Err() != nil)
+			return &tracingResult{Cmd: cmd}, err
+		}
+		evm.lines.Add(1)
+	}
+	duration, slow := evm.stats.TraceDone(t0, cmd)
+	if n := len(evm.ExecTimes); n > 0 {
+		duration = evm.ExecTimes[(evm.runs.Add(1)-1)%uint64(n)]
+	}
+	return &tracingResult{
+		Slow:     slow,
+		ExecTime: duration,
+		Cmd:      cmd,
+		Output:   evm.Output,
+	}, nil
+}
+
+// SetFields restricts the trace steps to the given fields.
+func (evm *MockVM) SetFields(fields []string) {
+	evm.fields = fields
+}
+
+func (evm *MockVM) filterFields(out io.Writer) io.Writer {
+	return newFieldFilter(out, evm.fields)
+}
+
+// Lines returns the number of lines delivered during the last run.
+func (evm *MockVM) Lines() int {
+	return int(evm.lines.Load())
+}
+
+// Cancelled returns whether the last run was cancelled before completion.
+func (evm *MockVM) Cancelled() bool {
+	return evm.cancelled.Load()
+}
+
+// Copy copies the input verbatim, since it is expected to already be
+// normalized.
+func (evm *MockVM) Copy(out io.Writer, input io.Reader) {
+	_, _ = io.Copy(out, input)
+}
+
+// reportsOutput implements the outputReporter interface.
+func (evm *MockVM) reportsOutput() {}
+
+func (evm *MockVM) Close() {
+}
+
+func (evm *MockVM) Stats() []any {
+	return evm.stats.Stats()
+}
+
+// VmStats returns the metrics of the vm, shared by its instances.
+func (evm *MockVM) VmStats() *VmStat {
+	return evm.stats
+}
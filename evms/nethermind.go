@@ -19,6 +19,7 @@ package evms
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -34,6 +35,7 @@ import (
 type NethermindVM struct {
 	path string
 	name string
+	process
 	// Some metrics
 	stats *VmStat
 }
@@ -48,9 +50,10 @@ func NewNethermindVM(path, name string) *NethermindVM {
 
 func (evm *NethermindVM) Instance(threadId int) Evm {
 	return &NethermindVM{
-		path:  evm.path,
-		name:  fmt.Sprintf("%v-%d", evm.name, threadId),
-		stats: evm.stats,
+		path:    evm.path,
+		name:    fmt.Sprintf("%v-%d", evm.name, threadId),
+		stats:   evm.stats,
+		process: evm.process,
 	}
 }
 
@@ -61,7 +64,7 @@ func (evm *NethermindVM) Name() string {
 // GetStateRoot runs the test and returns the stateroot
 func (evm *NethermindVM) GetStateRoot(path string) (root, command string, err error) {
 	// In this mode, we can run it without tracing
-	cmd := exec.Command(evm.path, "--neverTrace", "-m", "-s", "-i", path)
+	cmd := evm.execCommand(evm.path, "--neverTrace", "-m", "-s", "-i", path)
 	data, err := cmd.Output()
 	if err != nil {
 		return "", cmd.String(), err
@@ -84,34 +87,56 @@ func (evm *NethermindVM) ParseStateRoot(data []byte) (string, error) {
 	return string(data[start+14 : end]), nil
 }
 
+func (evm *NethermindVM) command(ctx context.Context, path string, speedTest bool) *exec.Cmd {
+	if speedTest {
+		return evm.execCommandContext(ctx, evm.path, "-m", "--neverTrace", "--input", path)
+	}
+	return evm.execCommandContext(ctx, evm.path, "--trace", "-m", "--input", path)
+}
+
+// CommandFor returns the command RunStateTest would use for the test.
+func (evm *NethermindVM) CommandFor(path string, speedTest bool) string {
+	return evm.command(context.Background(), path, speedTest).String()
+}
+
 // RunStateTest implements the Evm interface
 func (evm *NethermindVM) RunStateTest(path string, out io.Writer, speedTest bool) (*tracingResult, error) {
+	return evm.RunStateTestContext(context.Background(), path, out, speedTest)
+}
+
+// RunStateTestContext implements the CancellableEvm interface. The process
+// is killed if the context is cancelled.
+func (evm *NethermindVM) RunStateTestContext(ctx context.Context, path string, out io.Writer, speedTest bool) (*tracingResult, error) {
+	out = evm.filterFields(out)
 	var (
 		t0     = time.Now()
 		stderr io.ReadCloser
 		err    error
-		cmd    = exec.Command(evm.path, "--trace", "-m", "--input", path)
+		cmd    = evm.command(ctx, path, speedTest)
 	)
-	if speedTest {
-		cmd = exec.Command(evm.path, "-m", "--neverTrace", "--input", path)
-	}
-	if stderr, err = cmd.StderrPipe(); err != nil {
+	if stderr, err = evm.tracePipe(cmd, StderrStream); err != nil {
 		return &tracingResult{Cmd: cmd.String()}, err
 	}
 	if err = cmd.Start(); err != nil {
 		return &tracingResult{Cmd: cmd.String()}, err
 	}
 	// copy everything to the given writer
-	evm.Copy(out, stderr)
+	_, summary := evm.copyUntilEnd(out, stderr)
 	// release resources, handle error but ignore non-zero exit codes
 	_ = cmd.Wait()
-	duration, slow := evm.stats.TraceDone(t0)
+	duration, slow := evm.stats.TraceDone(t0, cmd.String())
 	return &tracingResult{
 		Slow:     slow,
 		ExecTime: duration,
-		Cmd:      cmd.String()}, nil
+		Cmd:      cmd.String(),
+		Output:   summary.Output,
+		Error:    summary.Error,
+		LogsHash: summary.LogsHash}, nil
 }
 
+// reportsOutput implements the outputReporter interface.
+func (evm *NethermindVM) reportsOutput() {}
+
 func (vm *NethermindVM) Close() {
 }
 
@@ -121,11 +146,14 @@ func (evm *NethermindVM) Copy(out io.Writer, input io.Reader) {
 	evm.copyUntilEnd(out, input)
 }
 
-func (evm *NethermindVM) copyUntilEnd(out io.Writer, input io.Reader) stateRoot {
+// copyUntilEnd returns the stateroot, and the execution summary reported by
+// the client.
+func (evm *NethermindVM) copyUntilEnd(out io.Writer, input io.Reader) (stateRoot, execSummary) {
 	buf := bufferPool.Get().([]byte)
 	//lint:ignore SA6002: argument should be pointer-like to avoid allocations.
 	defer bufferPool.Put(buf)
 	var stateRoot stateRoot
+	var summary execSummary
 	scanner := bufio.NewScanner(input)
 	scanner.Buffer(buf, 32*1024*1024)
 
@@ -149,6 +177,7 @@ func (evm *NethermindVM) copyUntilEnd(out io.Writer, input io.Reader) stateRoot
 			if stateRoot.StateRoot == "" {
 				_ = json.Unmarshal(data, &stateRoot)
 			}
+			summary.update(data)
 			// If we have a stateroot, we're done
 			if len(stateRoot.StateRoot) > 0 {
 				break
@@ -163,19 +192,24 @@ func (evm *NethermindVM) copyUntilEnd(out io.Writer, input io.Reader) stateRoot
 		if elem.Op == 0x0 {
 			continue
 		}
-		outp := FastMarshal(&elem)
-		if _, err := out.Write(append(outp, '\n')); err != nil {
+		evm.stats.CountOp(byte(elem.Op))
+		evm.trimStep(&elem)
+		if err := writeStep(out, &elem); err != nil {
 			fmt.Fprintf(os.Stderr, "Error writing to out: %v\n", err)
-			return stateRoot
+			return stateRoot, summary
 		}
 	}
-	root, _ := json.Marshal(stateRoot)
-	if _, err := out.Write(append(root, '\n')); err != nil {
+	if err := writeRoot(out, stateRoot); err != nil {
 		fmt.Fprintf(os.Stderr, "Error writing to out: %v\n", err)
 	}
-	return stateRoot
+	return stateRoot, summary
 }
 
 func (evm *NethermindVM) Stats() []any {
 	return evm.stats.Stats()
 }
+
+// VmStats returns the metrics of the vm, shared by its instances.
+func (evm *NethermindVM) VmStats() *VmStat {
+	return evm.stats
+}
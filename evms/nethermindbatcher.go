@@ -17,6 +17,7 @@
 package evms
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"os/exec"
@@ -42,15 +43,40 @@ func NewNethermindBatchVM(path, name string) *NethermindBatchVM {
 func (evm *NethermindBatchVM) Instance(threadId int) Evm {
 	return &NethermindBatchVM{
 		NethermindVM: NethermindVM{
-			path:  evm.path,
-			name:  fmt.Sprintf("%v-%d", evm.name, threadId),
-			stats: evm.stats,
+			path:    evm.path,
+			name:    fmt.Sprintf("%v-%d", evm.name, threadId),
+			stats:   evm.stats,
+			process: evm.process,
 		},
 	}
 }
 
+func (evm *NethermindBatchVM) batchCommand(speedTest bool) *exec.Cmd {
+	if speedTest {
+		return evm.execCommand(evm.path, "-x", "--trace", "-m", "--neverTrace")
+	}
+	return evm.execCommand(evm.path, "-x", "--trace", "-m")
+}
+
+// CommandFor returns the command of the 'master' process which RunStateTest
+// uses. The test path is not part of it, but passed via stdin.
+func (evm *NethermindBatchVM) CommandFor(path string, speedTest bool) string {
+	if evm.cmd != nil {
+		return evm.cmd.String()
+	}
+	return evm.batchCommand(speedTest).String()
+}
+
 // RunStateTest implements the Evm interface
 func (evm *NethermindBatchVM) RunStateTest(path string, out io.Writer, speedTest bool) (*tracingResult, error) {
+	return evm.RunStateTestContext(context.Background(), path, out, speedTest)
+}
+
+// RunStateTestContext implements the CancellableEvm interface. If the context
+// is cancelled, the 'master' process is killed, and the next test starts a
+// new one.
+func (evm *NethermindBatchVM) RunStateTestContext(ctx context.Context, path string, out io.Writer, speedTest bool) (*tracingResult, error) {
+	out = evm.filterFields(out)
 	var (
 		t0     = time.Now()
 		err    error
@@ -58,11 +84,8 @@ func (evm *NethermindBatchVM) RunStateTest(path string, out io.Writer, speedTest
 		stdin  io.WriteCloser
 	)
 	if evm.cmd == nil {
-		cmd := exec.Command(evm.path, "-x", "--trace", "-m")
-		if speedTest {
-			cmd = exec.Command(evm.path, "-x", "--trace", "-m", "--neverTrace")
-		}
-		if stdout, err = cmd.StderrPipe(); err != nil {
+		cmd := evm.batchCommand(speedTest)
+		if stdout, err = evm.tracePipe(cmd, StderrStream); err != nil {
 			return &tracingResult{Cmd: cmd.String()}, err
 		}
 		if stdin, err = cmd.StdinPipe(); err != nil {
@@ -78,13 +101,24 @@ func (evm *NethermindBatchVM) RunStateTest(path string, out io.Writer, speedTest
 	evm.mu.Lock()
 	defer evm.mu.Unlock()
 	_, _ = evm.stdin.Write([]byte(fmt.Sprintf("%v\n", path)))
+	master := evm.cmd
+	stop := context.AfterFunc(ctx, func() { _ = master.Process.Kill() })
 	// copy everything for the _current_ statetest to the given writer
-	evm.copyUntilEnd(out, evm.stdout)
-	duration, slow := evm.stats.TraceDone(t0)
+	_, summary := evm.copyUntilEnd(out, evm.stdout)
+	if !stop() {
+		evm.stdin.Close()
+		_ = master.Wait()
+		evm.cmd = nil
+		return &tracingResult{Cmd: master.String()}, ctx.Err()
+	}
+	duration, slow := evm.stats.TraceDone(t0, evm.cmd.String())
 	return &tracingResult{
 		Slow:     slow,
 		ExecTime: duration,
 		Cmd:      evm.cmd.String(),
+		Output:   summary.Output,
+		Error:    summary.Error,
+		LogsHash: summary.LogsHash,
 	}, nil
 }
 
@@ -99,7 +133,7 @@ func (vm *NethermindBatchVM) Close() {
 
 func (evm *NethermindBatchVM) GetStateRoot(path string) (root, command string, err error) {
 	if evm.cmd == nil {
-		evm.cmd = exec.Command(evm.path, "--neverTrace", "-m", "-s", "-x")
+		evm.cmd = evm.execCommand(evm.path, "--neverTrace", "-m", "-s", "-x")
 		if evm.stdout, err = evm.cmd.StdoutPipe(); err != nil {
 			return "", evm.cmd.String(), err
 		}
@@ -113,6 +147,6 @@ func (evm *NethermindBatchVM) GetStateRoot(path string) (root, command string, e
 	evm.mu.Lock()
 	defer evm.mu.Unlock()
 	_, _ = evm.stdin.Write([]byte(fmt.Sprintf("%v\n", path)))
-	sRoot := evm.copyUntilEnd(io.Discard, evm.stdout)
+	sRoot, _ := evm.copyUntilEnd(io.Discard, evm.stdout)
 	return sRoot.StateRoot, evm.cmd.String(), nil
 }
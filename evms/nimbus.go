@@ -19,6 +19,7 @@ package evms
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -35,6 +36,7 @@ import (
 type NimbusEVM struct {
 	path string
 	name string
+	process
 	// Some metrics
 	stats *VmStat
 }
@@ -61,7 +63,7 @@ func (evm *NimbusEVM) Name() string {
 // even in success-case
 func (evm *NimbusEVM) GetStateRoot(path string) (root, command string, err error) {
 	// In this mode, we can run it without tracing
-	cmd := exec.Command(evm.path, path)
+	cmd := evm.execCommand(evm.path, path)
 	data, _ := cmd.Output()
 
 	root, err = evm.ParseStateRoot(data)
@@ -83,44 +85,72 @@ func (evm *NimbusEVM) ParseStateRoot(data []byte) (string, error) {
 	return string(data[start+14 : end]), nil
 }
 
+func (evm *NimbusEVM) command(ctx context.Context, path string, speedTest bool) *exec.Cmd {
+	if speedTest {
+		return evm.execCommandContext(ctx, evm.path, "--noreturndata", "--nomemory", "--nostorage", path)
+	}
+	args := append(evm.traceFlags(), "--nostorage", path)
+	return evm.execCommandContext(ctx, evm.path, args...)
+}
+
+// CommandFor returns the command RunStateTest would use for the test.
+func (evm *NimbusEVM) CommandFor(path string, speedTest bool) string {
+	return evm.command(context.Background(), path, speedTest).String()
+}
+
 // RunStateTest implements the Evm interface
 func (evm *NimbusEVM) RunStateTest(path string, out io.Writer, speedTest bool) (*tracingResult, error) {
+	return evm.RunStateTestContext(context.Background(), path, out, speedTest)
+}
+
+// RunStateTestContext implements the CancellableEvm interface. The process
+// is killed if the context is cancelled.
+func (evm *NimbusEVM) RunStateTestContext(ctx context.Context, path string, out io.Writer, speedTest bool) (*tracingResult, error) {
+	out = evm.filterFields(out)
 	var (
 		t0     = time.Now()
 		stderr io.ReadCloser
 		err    error
-		cmd    *exec.Cmd
+		cmd    = evm.command(ctx, path, speedTest)
 	)
-	if speedTest {
-		cmd = exec.Command(evm.path, "--noreturndata", "--nomemory", "--nostorage", path)
-	} else {
-		cmd = exec.Command(evm.path, "--json", "--noreturndata", "--nomemory", "--nostorage", path)
-	}
-	if stderr, err = cmd.StderrPipe(); err != nil {
+	if stderr, err = evm.tracePipe(cmd, StderrStream); err != nil {
 		return &tracingResult{Cmd: cmd.String()}, err
 	}
 	if err = cmd.Start(); err != nil {
 		return &tracingResult{Cmd: cmd.String()}, err
 	}
 	// copy everything to the given writer
-	evm.Copy(out, stderr)
+	_, summary := evm.copyUntilEnd(out, stderr)
 	// Nimbus returns a non-zero exit code for tests that do not pass. We just ignore that.
 	_ = cmd.Wait()
 	// release resources
-	duration, slow := evm.stats.TraceDone(t0)
+	duration, slow := evm.stats.TraceDone(t0, cmd.String())
 
 	return &tracingResult{
 		Slow:     slow,
 		ExecTime: duration,
 		Cmd:      cmd.String(),
+		Output:   summary.Output,
+		Error:    summary.Error,
+		LogsHash: summary.LogsHash,
 	}, nil
 }
 
+// reportsOutput implements the outputReporter interface.
+func (evm *NimbusEVM) reportsOutput() {}
+
 func (vm *NimbusEVM) Close() {
 }
 
 func (evm *NimbusEVM) Copy(out io.Writer, input io.Reader) {
+	evm.copyUntilEnd(out, input)
+}
+
+// copyUntilEnd returns the stateroot, and the execution summary reported by
+// the client.
+func (evm *NimbusEVM) copyUntilEnd(out io.Writer, input io.Reader) (stateRoot, execSummary) {
 	var stateRoot stateRoot
+	var summary execSummary
 	scanner := bufio.NewScanner(input)
 	scanner.Buffer(make([]byte, 1024*1024), 32*1024*1024) // Start with 1MB buffer, allow up to 32 MB
 
@@ -133,8 +163,9 @@ func (evm *NimbusEVM) Copy(out io.Writer, input io.Reader) {
 			prev = current
 			return
 		}
-		data := FastMarshal(prev)
-		if _, err := out.Write(append(data, '\n')); err != nil {
+		evm.stats.CountOp(byte(prev.Op))
+		evm.trimStep(prev)
+		if err := writeStep(out, prev); err != nil {
 			fmt.Fprintf(os.Stderr, "Error writing to out: %v\n", err)
 		}
 		if current == nil { // final flush
@@ -169,6 +200,7 @@ func (evm *NimbusEVM) Copy(out io.Writer, input io.Reader) {
 			if stateRoot.StateRoot == "" {
 				_ = json.Unmarshal(data, &stateRoot)
 			}
+			summary.update(data)
 			continue
 		}
 		// When geth encounters end of code, it continues anyway, on a 'virtual' STOP.
@@ -179,12 +211,17 @@ func (evm *NimbusEVM) Copy(out io.Writer, input io.Reader) {
 		yield(&elem)
 	}
 	yield(nil)
-	root, _ := json.Marshal(stateRoot)
-	if _, err := out.Write(append(root, '\n')); err != nil {
+	if err := writeRoot(out, stateRoot); err != nil {
 		fmt.Fprintf(os.Stderr, "Error writing to out: %v\n", err)
 	}
+	return stateRoot, summary
 }
 
 func (evm *NimbusEVM) Stats() []any {
 	return evm.stats.Stats()
 }
+
+// VmStats returns the metrics of the vm, shared by its instances.
+func (evm *NimbusEVM) VmStats() *VmStat {
+	return evm.stats
+}
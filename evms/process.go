@@ -0,0 +1,220 @@
+// Copyright 2024 Martin Holst Swende
+// This file is part of the goevmlab library.
+//
+// The library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the goevmlab library. If not, see <http://www.gnu.org/licenses/>.
+
+package evms
+
+import (
+	"context"
+	"io"
+	"os/exec"
+	"time"
+
+	"github.com/theQRL/go-zond/zond/tracers/logger"
+)
+
+// TraceStream is the output stream of a vm process which carries the trace.
+type TraceStream int
+
+const (
+	DefaultStream TraceStream = iota // The stream the client is known to use
+	StdoutStream
+	StderrStream
+)
+
+// process is embedded in the vms, and holds the settings for the vm processes.
+type process struct {
+	// dir is the directory in which the processes are started. If empty, they
+	// inherit the working directory of the caller.
+	dir string
+	// stream is where the trace is read from.
+	stream TraceStream
+	// fields, if set, are the only fields retained in the trace steps.
+	fields []string
+	// trace, if set, selects the optional fields of the trace.
+	trace *TraceFields
+}
+
+// SetWorkDir sets the working directory of the vm processes.
+func (p *process) SetWorkDir(dir string) {
+	p.dir = dir
+}
+
+// SetTraceStream sets the stream which the trace is read from.
+func (p *process) SetTraceStream(stream TraceStream) {
+	p.stream = stream
+}
+
+// SetFields restricts the trace steps to the given fields.
+func (p *process) SetFields(fields []string) {
+	p.fields = fields
+}
+
+// SetTraceFields sets the optional fields to include in the trace. By
+// default, only the stack is included.
+func (p *process) SetTraceFields(fields TraceFields) {
+	p.trace = &fields
+}
+
+// traceFields returns the optional fields to include in the trace.
+func (p *process) traceFields() TraceFields {
+	if p.trace == nil {
+		return TraceFields{Stack: true}
+	}
+	return *p.trace
+}
+
+// traceFlags returns the flags which select the fields of the trace, for the
+// clients which take the flags of geth.
+func (p *process) traceFlags() []string {
+	var (
+		fields = p.traceFields()
+		flags  = []string{"--json"}
+	)
+	if !fields.ReturnData {
+		flags = append(flags, "--noreturndata")
+	}
+	if !fields.Memory {
+		flags = append(flags, "--nomemory")
+	}
+	if !fields.Stack {
+		flags = append(flags, "--nostack")
+	}
+	return flags
+}
+
+// trimStep drops the optional fields which are not selected from the step,
+// for the clients which deliver them regardless.
+func (p *process) trimStep(elem *logger.StructLog) {
+	fields := p.traceFields()
+	if !fields.Stack {
+		elem.Stack = nil
+	}
+	if !fields.Memory {
+		elem.Memory = nil
+	}
+	if !fields.ReturnData {
+		elem.ReturnData = nil
+	}
+}
+
+// filterFields wraps the writer, dropping the fields which are not selected.
+func (p *process) filterFields(out io.Writer) io.Writer {
+	return newFieldFilter(out, p.fields)
+}
+
+// execCommand returns a command, which runs in the working directory.
+func (p *process) execCommand(name string, args ...string) *exec.Cmd {
+	cmd := exec.Command(name, args...)
+	cmd.Dir = p.dir
+	return cmd
+}
+
+// execCommandContext is like execCommand, but uses the given context.
+func (p *process) execCommandContext(ctx context.Context, name string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Dir = p.dir
+	return cmd
+}
+
+// tracePipe returns a pipe of the stream carrying the trace of the command. The
+// given stream is used unless another one has been set.
+func (p *process) tracePipe(cmd *exec.Cmd, def TraceStream) (io.ReadCloser, error) {
+	stream := p.stream
+	if stream == DefaultStream {
+		stream = def
+	}
+	if stream == StdoutStream {
+		return cmd.StdoutPipe()
+	}
+	return cmd.StderrPipe()
+}
+
+// VMOption configures an Evm.
+type VMOption func(Evm)
+
+// WithWorkDir makes the vm start its processes in the given directory. It has
+// no effect on vms which do not spawn processes.
+func WithWorkDir(dir string) VMOption {
+	return func(vm Evm) {
+		if p, ok := vm.(interface{ SetWorkDir(string) }); ok {
+			p.SetWorkDir(dir)
+		}
+	}
+}
+
+// WithTraceStream makes the vm read the trace from the given stream of its
+// processes. It has no effect on vms which do not spawn processes.
+func WithTraceStream(stream TraceStream) VMOption {
+	return func(vm Evm) {
+		if p, ok := vm.(interface{ SetTraceStream(TraceStream) }); ok {
+			p.SetTraceStream(stream)
+		}
+	}
+}
+
+// TraceFields selects the optional fields of the trace steps. The normalized
+// json trace never carries the memory, but a TraceSink receives it, and the
+// client flag can be matched with the one used for another client.
+type TraceFields struct {
+	Stack      bool
+	Memory     bool
+	ReturnData bool
+}
+
+// WithTraceFields makes the vm include only the given fields in the trace.
+// The fields are dropped from the normalized output of all process-based
+// vms, and, for the clients which support it (geth, erigon and nimbus), the
+// client is also told not to trace them. It has no effect on vms which do not
+// spawn processes.
+func WithTraceFields(stack, memory, returndata bool) VMOption {
+	return func(vm Evm) {
+		if p, ok := vm.(interface{ SetTraceFields(TraceFields) }); ok {
+			p.SetTraceFields(TraceFields{Stack: stack, Memory: memory, ReturnData: returndata})
+		}
+	}
+}
+
+// WithFields makes the vm retain only the given fields, e.g. "pc" and "op",
+// in the steps of the normalized trace, so that comparisons ignore the other
+// fields. The depth is always retained. A TraceSink receives the steps with
+// the other fields zeroed.
+func WithFields(fields ...string) VMOption {
+	return func(vm Evm) {
+		if p, ok := vm.(interface{ SetFields([]string) }); ok {
+			p.SetFields(fields)
+		}
+	}
+}
+
+// WithSlowThreshold makes runs of the vm exceeding the given duration count as
+// slow, and logs them if logSlow is set. It has no effect on vms without
+// metrics.
+func WithSlowThreshold(threshold time.Duration, logSlow bool) VMOption {
+	return func(vm Evm) {
+		if p, ok := vm.(interface{ VmStats() *VmStat }); ok {
+			p.VmStats().SetSlowThreshold(threshold)
+			p.VmStats().SetLogSlow(logSlow)
+		}
+	}
+}
+
+// Configure applies the options to the vm, and returns it.
+func Configure(vm Evm, opts ...VMOption) Evm {
+	for _, opt := range opts {
+		opt(vm)
+	}
+	return vm
+}
@@ -0,0 +1,122 @@
+// Copyright 2024 Martin Holst Swende
+// This file is part of the goevmlab library.
+//
+// The library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the goevmlab library. If not, see <http://www.gnu.org/licenses/>.
+
+package evms
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWithWorkDir(t *testing.T) {
+	var (
+		base = NewGethBatchVM("/bin/evm", "geth")
+		a    = Configure(base.Instance(0), WithWorkDir("/tmp/a")).(*GethBatchVM)
+		b    = Configure(base.Instance(1), WithWorkDir("/tmp/b")).(*GethBatchVM)
+	)
+	if have, want := a.batchCommand(false).Dir, "/tmp/a"; have != want {
+		t.Errorf("got %v expected %v", have, want)
+	}
+	if have, want := b.batchCommand(false).Dir, "/tmp/b"; have != want {
+		t.Errorf("got %v expected %v", have, want)
+	}
+	// Instances inherit the directory of their parent.
+	if have, want := Configure(base, WithWorkDir("/tmp/c")).Instance(2).(*GethBatchVM).batchCommand(true).Dir, "/tmp/c"; have != want {
+		t.Errorf("got %v expected %v", have, want)
+	}
+	// Without the option, the processes run in the current directory.
+	if have := NewGethEVM("/bin/evm", "geth").command(context.Background(), "test.json", false).Dir; have != "" {
+		t.Errorf("got %v expected empty dir", have)
+	}
+	// Vms without processes are left alone.
+	Configure(NewMockVM("mock", nil), WithWorkDir("/tmp/d"))
+}
+
+func TestWithTraceStream(t *testing.T) {
+	trace, err := filepath.Abs("testdata/traces/00000006-naivefuzz-0.json.geth.stderr.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A fake client, which emits the trace on stdout.
+	bin := filepath.Join(t.TempDir(), "evm")
+	if err := os.WriteFile(bin, []byte(fmt.Sprintf("#!/bin/sh\ncat %v\n", trace)), 0755); err != nil {
+		t.Fatal(err)
+	}
+	var (
+		root = "0xad1024c87b5548e77c937aa50f72b6cb620d278f4dd79bae7f78f71ff75af458"
+		out  bytes.Buffer
+		vm   = NewGethEVM(bin, "geth")
+	)
+	// By default, geth traces are read from stderr, so nothing is captured.
+	if _, err := vm.RunStateTest("test.json", &out, false); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(out.String(), root) {
+		t.Errorf("unexpected stateroot in output from stderr")
+	}
+	out.Reset()
+	Configure(vm, WithTraceStream(StdoutStream))
+	if _, err := vm.RunStateTest("test.json", &out, false); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), root) {
+		t.Errorf("stateroot missing from output: %v", out.String())
+	}
+	if have := strings.Count(out.String(), `{"depth":`); have == 0 {
+		t.Errorf("no steps captured")
+	}
+}
+
+func TestWithTraceFieldsWrappers(t *testing.T) {
+	geth := Configure(NewGethEVM("/bin/evm", "geth"), WithTraceFields(false, false, true))
+	if have, want := geth.(*GethEVM).CommandFor("test.json", false), "/bin/evm --json --nomemory --nostack statetest test.json"; have != want {
+		t.Errorf("got %q expected %q", have, want)
+	}
+	batch := Configure(NewGethBatchVM("/bin/evm", "geth"), WithTraceFields(false, false, true))
+	if have, want := batch.Instance(0).(*GethBatchVM).batchCommand(false).String(), "/bin/evm --json --nomemory --nostack statetest"; have != want {
+		t.Errorf("got %q expected %q", have, want)
+	}
+	// Clients which deliver the stack regardless have it dropped
+	trace := `{"pc":0,"op":80,"gas":"0xb4213","gasCost":"0x2","memSize":0,"stack":["0x2"],"depth":1,"refund":0,"opName":"POP"}`
+	vm := Configure(NewNethermindVM("", ""), WithTraceFields(false, false, false))
+	out := new(strings.Builder)
+	vm.Copy(out, strings.NewReader(trace))
+	if have := out.String(); !strings.Contains(have, `"stack":[]`) {
+		t.Errorf("expected no stack, got %v", have)
+	}
+}
+
+func TestBatchVMContext(t *testing.T) {
+	// The 'master' process reads the test path, but never finishes the test.
+	vm := NewGethBatchVM(fakeBinary(t, "read path\nexec sleep 30"), "geth")
+	defer vm.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if _, err := vm.RunStateTestContext(ctx, "test.json", io.Discard, false); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v expected %v", err, context.DeadlineExceeded)
+	}
+	if vm.cmd != nil {
+		t.Errorf("expected the master process to be released")
+	}
+}
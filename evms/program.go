@@ -0,0 +1,59 @@
+// Copyright 2024 Martin Holst Swende
+// This file is part of the goevmlab library.
+//
+// The library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the goevmlab library. If not, see <http://www.gnu.org/licenses/>.
+
+package evms
+
+import (
+	"io"
+	"math/big"
+
+	"github.com/rgeraldes24/goevmlab/fuzzing"
+	"github.com/theQRL/go-zond/common"
+)
+
+// programAddr is the address where RunProgram places the code.
+var programAddr = common.HexToAddress("0x00000000000000000000000000000000c0de")
+
+// RunProgram executes the code on the vm, by wrapping it in a (filled)
+// statetest with a transaction to the code, with the given gas limit. The
+// (normalized) trace is written to out. The statetest is removed afterwards.
+func RunProgram(vm Evm, code []byte, gas uint64, out io.Writer) (*tracingResult, error) {
+	path, cleanup, err := writeProgram(code, gas)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+	return vm.RunStateTest(path, out, false)
+}
+
+// writeProgram writes the statetest used by RunProgram to a temporary file.
+// The cleanup function removes the file.
+func writeProgram(code []byte, gas uint64) (path string, cleanup func(), err error) {
+	// The sender is added by BasicStateTest
+	mkr := fuzzing.BasicStateTest("Shanghai")
+	mkr.AddAccount(programAddr, fuzzing.GenesisAccount{
+		Code:    code,
+		Balance: new(big.Int),
+		Storage: make(map[common.Hash]common.Hash),
+	})
+	fuzzing.AddTransaction(&programAddr, mkr)
+	mkr.SetGasLimit(gas)
+	mkr.SetValue(new(big.Int))
+	if err := mkr.Fill(nil); err != nil {
+		return "", nil, err
+	}
+	return fuzzing.WriteTempTest(mkr.ToGeneralStateTest("program"))
+}
@@ -0,0 +1,76 @@
+// Copyright 2024 Martin Holst Swende
+// This file is part of the goevmlab library.
+//
+// The library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the goevmlab library. If not, see <http://www.gnu.org/licenses/>.
+
+package evms
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/rgeraldes24/goevmlab/ops"
+	"github.com/rgeraldes24/goevmlab/program"
+	"github.com/theQRL/go-zond/common/hexutil"
+)
+
+// testReadingVM is a MockVM which also records the test it was given.
+type testReadingVM struct {
+	*MockVM
+	path string
+	test []byte
+}
+
+func (vm *testReadingVM) RunStateTest(path string, out io.Writer, speedTest bool) (*tracingResult, error) {
+	vm.path = path
+	vm.test, _ = os.ReadFile(path)
+	return vm.MockVM.RunStateTest(path, out, speedTest)
+}
+
+func TestRunProgram(t *testing.T) {
+	// Program B from the calltree example, a self-call
+	b := program.NewProgram()
+	b.Op(ops.PC)      // get zero on stack (out size)
+	b.Op(ops.DUP1)    // out offset
+	b.Op(ops.DUP1)    // insize
+	b.Op(ops.DUP1)    // inoffset
+	b.Op(ops.DUP1)    // value
+	b.Op(ops.ADDRESS) // address
+	b.Op(ops.GAS)     // Gas
+	b.Op(ops.CALL)
+
+	var (
+		trace = mockTrace(5, 5)
+		vm    = &testReadingVM{MockVM: NewMockVM("mock", trace)}
+		out   bytes.Buffer
+	)
+	if _, err := RunProgram(vm, b.Bytecode(), 100000, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out.Bytes(), trace) {
+		t.Errorf("wrong output, got\n%v\nexpected\n%v", out.String(), string(trace))
+	}
+	if !strings.Contains(string(vm.test), hexutil.Encode(b.Bytecode())) {
+		t.Errorf("code missing from test:\n%v", string(vm.test))
+	}
+	if !strings.Contains(string(vm.test), `"0x186a0"`) {
+		t.Errorf("gas limit missing from test:\n%v", string(vm.test))
+	}
+	if _, err := os.Stat(vm.path); !os.IsNotExist(err) {
+		t.Errorf("test file not removed: %v", err)
+	}
+}
@@ -0,0 +1,166 @@
+// Copyright 2024 Martin Holst Swende
+// This file is part of the goevmlab library.
+//
+// The library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the goevmlab library. If not, see <http://www.gnu.org/licenses/>.
+
+package evms
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/theQRL/go-zond/core/vm"
+	"github.com/theQRL/go-zond/params"
+	"github.com/theQRL/go-zond/zond/tracers/logger"
+)
+
+// sstoreRefundDeltas are the possible changes of the refund counter caused by
+// a single SSTORE, as per EIP-2200, with the costs from EIP-2929 and EIP-3529.
+var sstoreRefundDeltas = map[int64]bool{
+	0: true,
+	// Clearing a slot, or un-clearing it
+	int64(params.SstoreClearsScheduleRefundEIP3529):  true,
+	-int64(params.SstoreClearsScheduleRefundEIP3529): true,
+	// Resetting to the original non-zero value
+	int64(params.SstoreResetGasEIP2200 - params.ColdSloadCostEIP2929 - params.WarmStorageReadCostEIP2929): true,
+	// Resetting to the original non-zero value, after having cleared it
+	int64(params.SstoreResetGasEIP2200-params.ColdSloadCostEIP2929-params.WarmStorageReadCostEIP2929) -
+		int64(params.SstoreClearsScheduleRefundEIP3529): true,
+	// Resetting to the original zero value
+	int64(params.SstoreSetGas - params.WarmStorageReadCostEIP2929): true,
+}
+
+// CheckRefunds reconciles the refund counter in a trace with the SSTORE steps,
+// and with the gas used by the transaction. The trace must contain the refund
+// counter and the gas costs, e.g. as output by geth, or by a wrapper with
+// FastMarshal set to JsonMarshal. The STOP steps, which the wrappers drop, may
+// be missing.
+//
+// The following is checked:
+//   - Every change of the refund counter after an SSTORE is one that an SSTORE
+//     can cause. The original storage values are not known, so this is only a
+//     check of the possible deltas.
+//   - No other op (e.g. SELFDESTRUCT) changes the refund counter, except that
+//     it is restored when returning from a failed call.
+//   - The gas used equals the intrinsic gas plus the execution gas, minus the
+//     refund capped at a fifth of that.
+//
+// The intrinsic gas is not part of the trace, so besides the gas used, the gas
+// limit of the transaction is needed: the intrinsic gas is what it exceeds the
+// gas available at the first step by.
+func CheckRefunds(r io.Reader, gasUsed, gasLimit uint64) (ok bool, detail string) {
+	var (
+		first, prev *logger.StructLog
+		frames      []refundFrame // the open call frames
+		scanner     = bufio.NewScanner(r)
+	)
+	scanner.Buffer(make([]byte, 1024*1024), 32*1024*1024)
+	for scanner.Scan() {
+		var step logger.StructLog
+		if err := json.Unmarshal(scanner.Bytes(), &step); err != nil || step.Depth == 0 {
+			continue
+		}
+		if first == nil {
+			first = &step
+		}
+		if prev == nil {
+			prev = &step
+			continue
+		}
+		switch {
+		case step.Depth > prev.Depth:
+			frames = append(frames, refundFrame{call: prev, start: step.Gas, entry: prev.RefundCounter})
+			if step.RefundCounter != prev.RefundCounter {
+				return false, fmt.Sprintf("refund changed from %d to %d on entering call at pc %d",
+					prev.RefundCounter, step.RefundCounter, prev.Pc)
+			}
+		case step.Depth < prev.Depth:
+			// The STOP ending a frame is not in the trace, so several frames
+			// may be left at once. Any of them may have failed.
+			var (
+				restored = make(map[uint64]bool)
+				delta    = int64(step.RefundCounter) - int64(prev.RefundCounter)
+			)
+			for len(frames) > 0 && len(frames) >= step.Depth {
+				restored[frames[len(frames)-1].entry] = true
+				frames = frames[:len(frames)-1]
+			}
+			// The last step of the callee may be an SSTORE, if the callee
+			// then stopped. Its refund change shows on the step returned to.
+			sstore := prev.Op == vm.SSTORE && sstoreRefundDeltas[delta]
+			if delta != 0 && !sstore && !restored[step.RefundCounter] {
+				return false, fmt.Sprintf("refund changed from %d to %d on returning to depth %d",
+					prev.RefundCounter, step.RefundCounter, step.Depth)
+			}
+		default:
+			delta := int64(step.RefundCounter) - int64(prev.RefundCounter)
+			if prev.Op == vm.SSTORE && !sstoreRefundDeltas[delta] {
+				return false, fmt.Sprintf("impossible refund change %d by SSTORE at pc %d", delta, prev.Pc)
+			}
+			if prev.Op != vm.SSTORE && delta != 0 {
+				return false, fmt.Sprintf("refund changed by %d after %v at pc %d", delta, prev.Op, prev.Pc)
+			}
+		}
+		prev = &step
+	}
+	if first == nil {
+		return false, "no steps in trace"
+	}
+	if first.Gas > gasLimit {
+		return false, fmt.Sprintf("gas %d at first step exceeds gas limit %d", first.Gas, gasLimit)
+	}
+	intrinsic := gasLimit - first.Gas
+	// The trace may end within a call, if the callees stopped. The gas left
+	// in each frame is then returned to the caller.
+	left := prev.Gas - prev.GasCost
+	for i := len(frames) - 1; i >= 0; i-- {
+		call := frames[i].call
+		left += call.Gas - call.GasCost
+		// The gas cost of a call includes the gas given to the callee, but
+		// for a create it is deducted afterwards.
+		if call.Op == vm.CREATE || call.Op == vm.CREATE2 {
+			left -= frames[i].start
+		}
+	}
+	executed := first.Gas - left
+	// If the trace ends with an SSTORE, its refund change is not known.
+	refunds := []uint64{prev.RefundCounter}
+	if prev.Op == vm.SSTORE {
+		for delta := range sstoreRefundDeltas {
+			if delta != 0 && int64(prev.RefundCounter)+delta >= 0 {
+				refunds = append(refunds, uint64(int64(prev.RefundCounter)+delta))
+			}
+		}
+	}
+	total := intrinsic + executed
+	for _, refund := range refunds {
+		applied := min(refund, total/params.RefundQuotientEIP3529)
+		if gasUsed == total-applied {
+			return true, fmt.Sprintf("intrinsic %d, executed %d, refund %d (applied %d)",
+				intrinsic, executed, refund, applied)
+		}
+	}
+	applied := min(prev.RefundCounter, total/params.RefundQuotientEIP3529)
+	return false, fmt.Sprintf("gas used %d, expected %d: intrinsic %d, executed %d, refund %d (applied %d)",
+		gasUsed, total-applied, intrinsic, executed, prev.RefundCounter, applied)
+}
+
+// refundFrame is a call frame entered in a trace checked by CheckRefunds.
+type refundFrame struct {
+	call  *logger.StructLog // the step in the caller
+	start uint64            // the gas at the first step in the callee
+	entry uint64            // the refund counter when entering
+}
@@ -0,0 +1,122 @@
+// Copyright 2024 Martin Holst Swende
+// This file is part of the goevmlab library.
+//
+// The library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the goevmlab library. If not, see <http://www.gnu.org/licenses/>.
+
+package evms
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/theQRL/go-zond/zond/tracers/logger"
+)
+
+// sstoreClearTrace is the trace of PUSH1 0, PUSH1 0, SSTORE, STOP, where
+// slot 0 was originally non-zero, with the given refund after the SSTORE.
+func sstoreClearTrace(refund string) string {
+	return strings.Join([]string{
+		`{"depth":1,"pc":0,"gas":79000,"gasCost":3,"op":96,"refund":0}`,
+		`{"depth":1,"pc":2,"gas":78997,"gasCost":3,"op":96,"refund":0}`,
+		`{"depth":1,"pc":4,"gas":78994,"gasCost":5000,"op":85,"refund":0}`,
+		`{"depth":1,"pc":5,"gas":73994,"gasCost":0,"op":0,"refund":` + refund + `}`,
+		`{"stateRoot":"0xa2b3391f7a85bf1ad08dc541a1b99da3c591c156351391f26ec88c557ff12134"}`,
+	}, "\n")
+}
+
+func TestCheckRefunds(t *testing.T) {
+	for i, tt := range []struct {
+		trace    string
+		gasUsed  uint64
+		gasLimit uint64
+		want     bool
+	}{
+		// 21000 intrinsic + 5006 executed - 4800 refund
+		{sstoreClearTrace("4800"), 21206, 100000, true},
+		// The refund not applied
+		{sstoreClearTrace("4800"), 26006, 100000, false},
+		// The refund not applied, with calldata making up for it
+		{sstoreClearTrace("4800"), 26006, 104800, true},
+		// Too much refunded
+		{sstoreClearTrace("4800"), 16000, 100000, false},
+		// The pre-EIP-3529 clearing refund
+		{sstoreClearTrace("15000"), 21206, 100000, false},
+		// A gas limit below the gas available
+		{sstoreClearTrace("4800"), 21206, 78000, false},
+		// A refund by SELFDESTRUCT
+		{strings.Join([]string{
+			`{"depth":1,"pc":0,"gas":79000,"gasCost":5000,"op":255,"refund":0}`,
+			`{"depth":1,"pc":1,"gas":74000,"gasCost":0,"op":0,"refund":24000}`,
+		}, "\n"), 21000, 100000, false},
+		// A refund which is reverted on returning from a failed call
+		{strings.Join([]string{
+			`{"depth":1,"pc":0,"gas":79000,"gasCost":100,"op":241,"refund":0}`,
+			`{"depth":2,"pc":0,"gas":70000,"gasCost":5000,"op":85,"refund":0}`,
+			`{"depth":2,"pc":1,"gas":65000,"gasCost":0,"op":253,"refund":4800}`,
+			`{"depth":1,"pc":1,"gas":74000,"gasCost":0,"op":0,"refund":0}`,
+		}, "\n"), 26000, 100000, true},
+	} {
+		if ok, detail := CheckRefunds(strings.NewReader(tt.trace), tt.gasUsed, tt.gasLimit); ok != tt.want {
+			t.Errorf("test %d: got %v expected %v: %v", i, ok, tt.want, detail)
+		}
+	}
+}
+
+// callSstoreTrace is the geth trace of a CALL to a callee which clears a slot
+// and stops, followed by POP in the caller, if pop is set, and STOP.
+func callSstoreTrace(pop bool) string {
+	lines := []string{
+		`{"pc":0,"op":241,"gas":"0x13498","gasCost":"0xcd78","refund":0,"depth":1,"opName":"CALL"}`,
+		`{"pc":0,"op":85,"gas":"0xc350","gasCost":"0x1388","refund":0,"depth":2,"opName":"SSTORE"}`,
+		`{"pc":1,"op":0,"gas":"0xafc8","gasCost":"0x0","refund":4800,"depth":2,"opName":"STOP"}`,
+	}
+	if pop {
+		lines = append(lines,
+			`{"pc":1,"op":80,"gas":"0x116e8","gasCost":"0x2","refund":4800,"depth":1,"opName":"POP"}`,
+			`{"pc":2,"op":0,"gas":"0x116e6","gasCost":"0x0","refund":4800,"depth":1,"opName":"STOP"}`)
+	} else {
+		lines = append(lines,
+			`{"pc":1,"op":0,"gas":"0x116e8","gasCost":"0x0","refund":4800,"depth":1,"opName":"STOP"}`)
+	}
+	lines = append(lines, `{"stateRoot": "0xa2b3391f7a85bf1ad08dc541a1b99da3c591c156351391f26ec88c557ff12134"}`)
+	return strings.Join(lines, "\n")
+}
+
+func TestCheckRefundsNormalized(t *testing.T) {
+	defer func(marshal func(*logger.StructLog) []byte) { FastMarshal = marshal }(FastMarshal)
+	FastMarshal = JsonMarshal
+	for i, tt := range []struct {
+		trace    string
+		gasUsed  uint64
+		gasLimit uint64
+		want     bool
+	}{
+		// 21000 intrinsic + 7602 executed - 4800 refund, with 79000 gas at
+		// the first step
+		{callSstoreTrace(true), 23802, 100000, true},
+		{callSstoreTrace(true), 28602, 100000, false},
+		// The trace ends within the callee, with the SSTORE
+		{callSstoreTrace(false), 23800, 100000, true},
+		// The SSTORE may not have changed the refund
+		{callSstoreTrace(false), 28600, 100000, true},
+		{callSstoreTrace(false), 23802, 100000, false},
+	} {
+		var out bytes.Buffer
+		NewGethEVM("", "geth").Copy(&out, strings.NewReader(tt.trace))
+		if ok, detail := CheckRefunds(&out, tt.gasUsed, tt.gasLimit); ok != tt.want {
+			t.Errorf("test %d: got %v expected %v: %v", i, ok, tt.want, detail)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+// Copyright 2024 Martin Holst Swende
+// This file is part of the goevmlab library.
+//
+// The library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the goevmlab library. If not, see <http://www.gnu.org/licenses/>.
+
+package evms
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+
+	"github.com/rgeraldes24/goevmlab/ops"
+	"github.com/theQRL/go-zond/common"
+)
+
+// replayStep is the part of a trace line which is needed to replay storage.
+type replayStep struct {
+	Depth int      `json:"depth"`
+	Op    *uint8   `json:"op"`
+	Stack []string `json:"stack"`
+	Error string   `json:"error"`
+}
+
+// storageWrite is an SSTORE. The address is shared with the frame, as it is
+// not known until a CREATE returns.
+type storageWrite struct {
+	addr       *common.Address
+	key, value common.Hash
+}
+
+// storageFrame holds the writes of a call frame, until it returns.
+type storageFrame struct {
+	addr   *common.Address
+	create bool
+	writes []storageWrite
+}
+
+// ReplayStorage reconstructs the storage written during the execution of a
+// trace, without re-executing it. The SSTOREs of frames which revert or fail
+// are discarded. The outcome of a call, and the address created by CREATE and
+// CREATE2, are read from the top of the stack of the caller once the call
+// returns. The trace does not name the account executing at depth 1, so it is
+// given as the destination of the transaction. Slots which are written to
+// zero are included, with the zero value.
+func ReplayStorage(r io.Reader, to common.Address) map[common.Address]map[common.Hash]common.Hash {
+	var (
+		scanner = bufio.NewScanner(r)
+		frames  = []*storageFrame{{addr: &to}}
+		prev    *replayStep
+	)
+	scanner.Buffer(make([]byte, 1024*1024), 32*1024*1024)
+	for scanner.Scan() {
+		step := new(replayStep)
+		if err := json.Unmarshal(scanner.Bytes(), step); err != nil || step.Op == nil || step.Depth < 1 {
+			continue
+		}
+		if prev != nil && step.Depth > prev.Depth {
+			frames = append(frames, enterFrame(prev, frames[len(frames)-1]))
+		}
+		for len(frames) > step.Depth {
+			// A call returned, the outcome is on top of the stack
+			var (
+				child   = frames[len(frames)-1]
+				outcome common.Hash
+			)
+			if n := len(step.Stack); n > 0 {
+				outcome = common.HexToHash(step.Stack[n-1])
+			}
+			frames = frames[:len(frames)-1]
+			if outcome == (common.Hash{}) {
+				continue
+			}
+			if child.create {
+				*child.addr = common.BytesToAddress(outcome.Bytes())
+			}
+			parent := frames[len(frames)-1]
+			parent.writes = append(parent.writes, child.writes...)
+		}
+		if n := len(step.Stack); ops.OpCode(*step.Op) == ops.SSTORE && n >= 2 && len(step.Error) == 0 {
+			f := frames[len(frames)-1]
+			f.writes = append(f.writes, storageWrite{f.addr, common.HexToHash(step.Stack[n-1]), common.HexToHash(step.Stack[n-2])})
+		}
+		prev = step
+	}
+	storage := make(map[common.Address]map[common.Hash]common.Hash)
+	if prev == nil || len(prev.Error) > 0 || ops.OpCode(*prev.Op) == ops.REVERT {
+		return storage
+	}
+	// Frames which did not return to the caller within the trace are kept
+	var writes []storageWrite
+	for _, f := range frames {
+		writes = append(writes, f.writes...)
+	}
+	for _, w := range writes {
+		if storage[*w.addr] == nil {
+			storage[*w.addr] = make(map[common.Hash]common.Hash)
+		}
+		storage[*w.addr][w.key] = w.value
+	}
+	return storage
+}
+
+// enterFrame returns the frame entered by the call op of the step.
+func enterFrame(call *replayStep, parent *storageFrame) *storageFrame {
+	var (
+		op = ops.OpCode(*call.Op)
+		n  = len(call.Stack)
+	)
+	switch {
+	case op == ops.CREATE || op == ops.CREATE2:
+		return &storageFrame{addr: new(common.Address), create: true}
+	case (op == ops.CALL || op == ops.STATICCALL) && n >= 2:
+		addr := common.BytesToAddress(common.FromHex(call.Stack[n-2]))
+		return &storageFrame{addr: &addr}
+	default:
+		// DELEGATECALL and CALLCODE execute in the context of the caller
+		return &storageFrame{addr: parent.addr}
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright 2024 Martin Holst Swende
+// This file is part of the goevmlab library.
+//
+// The library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the goevmlab library. If not, see <http://www.gnu.org/licenses/>.
+
+package evms
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/theQRL/go-zond/common"
+)
+
+func TestReplayStorage(t *testing.T) {
+	var (
+		callee = common.HexToAddress("0xca11ee")
+		top    = common.HexToAddress("0xc0de")
+	)
+	trace := strings.Join([]string{
+		// sstore(1, 0xa), sstore(1, 0xb)
+		`{"pc":4,"op":85,"gas":"0x100","depth":1,"stack":["0xa","0x1"],"opName":"SSTORE"}`,
+		`{"pc":9,"op":85,"gas":"0xf0","depth":1,"stack":["0xb","0x1"],"opName":"SSTORE"}`,
+		// A call which succeeds
+		`{"pc":10,"op":241,"gas":"0xe0","depth":1,"stack":["0x0","0x0","0x0","0x0","0x0","0xca11ee","0x50"],"opName":"CALL"}`,
+		`{"pc":4,"op":85,"gas":"0x50","depth":2,"stack":["0xc","0x2"],"opName":"SSTORE"}`,
+		`{"pc":5,"op":0,"gas":"0x40","depth":2,"stack":[],"opName":"STOP"}`,
+		`{"pc":11,"op":80,"gas":"0xd0","depth":1,"stack":["0x1"],"opName":"POP"}`,
+		// A call which reverts
+		`{"pc":12,"op":241,"gas":"0xc0","depth":1,"stack":["0x0","0x0","0x0","0x0","0x0","0xca11ee","0x50"],"opName":"CALL"}`,
+		`{"pc":4,"op":85,"gas":"0x50","depth":2,"stack":["0xd","0x2"],"opName":"SSTORE"}`,
+		`{"pc":9,"op":253,"gas":"0x40","depth":2,"stack":["0x0","0x0"],"opName":"REVERT"}`,
+		`{"pc":13,"op":80,"gas":"0xb0","depth":1,"stack":["0x0"],"opName":"POP"}`,
+		// A delegatecall writes to the storage of the caller
+		`{"pc":14,"op":244,"gas":"0xa0","depth":1,"stack":["0x0","0x0","0x0","0x0","0xca11ee","0x50"],"opName":"DELEGATECALL"}`,
+		`{"pc":4,"op":85,"gas":"0x50","depth":2,"stack":["0xe","0x3"],"opName":"SSTORE"}`,
+		`{"pc":5,"op":0,"gas":"0x40","depth":2,"stack":[],"opName":"STOP"}`,
+		`{"pc":15,"op":80,"gas":"0x90","depth":1,"stack":["0x1"],"opName":"POP"}`,
+		// A re-entrant call overwrites the storage of the top frame
+		`{"pc":15,"op":241,"gas":"0x90","depth":1,"stack":["0x0","0x0","0x0","0x0","0x0","0xc0de","0x50"],"opName":"CALL"}`,
+		`{"pc":4,"op":85,"gas":"0x50","depth":2,"stack":["0x10","0x1"],"opName":"SSTORE"}`,
+		`{"pc":5,"op":0,"gas":"0x40","depth":2,"stack":[],"opName":"STOP"}`,
+		`{"pc":16,"op":80,"gas":"0x88","depth":1,"stack":["0x1"],"opName":"POP"}`,
+		// A create, whose address is known when it returns
+		`{"pc":16,"op":240,"gas":"0x80","depth":1,"stack":["0x5","0x0","0x0"],"opName":"CREATE"}`,
+		`{"pc":4,"op":85,"gas":"0x50","depth":2,"stack":["0xf","0x4"],"opName":"SSTORE"}`,
+		`{"pc":5,"op":0,"gas":"0x40","depth":2,"stack":[],"opName":"STOP"}`,
+		`{"pc":17,"op":0,"gas":"0x70","depth":1,"stack":["0xc4ea7e"],"opName":"STOP"}`,
+		`{"stateRoot":"0xa2b3391f7a85bf1ad08dc541a1b99da3c591c156351391f26ec88c557ff12134"}`,
+	}, "\n")
+	h := common.HexToHash
+	want := map[common.Address]map[common.Hash]common.Hash{
+		top:                             {h("0x1"): h("0x10"), h("0x3"): h("0xe")},
+		callee:                          {h("0x2"): h("0xc")},
+		common.HexToAddress("0xc4ea7e"): {h("0x4"): h("0xf")},
+	}
+	have := ReplayStorage(strings.NewReader(trace), top)
+	if len(have) != len(want) {
+		t.Fatalf("got %d accounts expected %d: %v", len(have), len(want), have)
+	}
+	for addr, slots := range want {
+		if len(have[addr]) != len(slots) {
+			t.Errorf("%v: got %v expected %v", addr, have[addr], slots)
+			continue
+		}
+		for key, value := range slots {
+			if have[addr][key] != value {
+				t.Errorf("%v slot %v: got %v expected %v", addr, key, have[addr][key], value)
+			}
+		}
+	}
+	// If the transaction reverts, nothing is stored
+	reverted := strings.Join([]string{
+		`{"pc":4,"op":85,"gas":"0x100","depth":1,"stack":["0xa","0x1"],"opName":"SSTORE"}`,
+		`{"pc":9,"op":253,"gas":"0xf0","depth":1,"stack":["0x0","0x0"],"opName":"REVERT"}`,
+	}, "\n")
+	if have := ReplayStorage(strings.NewReader(reverted), top); len(have) != 0 {
+		t.Errorf("got %v expected no storage", have)
+	}
+}
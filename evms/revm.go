@@ -20,6 +20,7 @@ package evms
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -39,6 +40,7 @@ import (
 type RethVM struct {
 	path string
 	name string
+	process
 
 	stats *VmStat
 }
@@ -60,7 +62,7 @@ func (evm *RethVM) Name() string {
 }
 
 func (evm *RethVM) GetStateRoot(path string) (root, command string, err error) {
-	cmd := exec.Command(evm.path, "statetest", "--json-outcome", path)
+	cmd := evm.execCommand(evm.path, "statetest", "--json-outcome", path)
 	data, err := StdErrOutput(cmd)
 
 	// If revm exits with 1 on stateroot errors, uncomment to ignore:
@@ -90,31 +92,49 @@ func (evm *RethVM) ParseStateRoot(data []byte) (root string, err error) {
 	return string(data[start:end]), nil
 }
 
+func (evm *RethVM) command(ctx context.Context, path string, speedTest bool) *exec.Cmd {
+	if speedTest {
+		return evm.execCommandContext(ctx, evm.path, "statetest", "--json-outcome", path)
+	}
+	return evm.execCommandContext(ctx, evm.path, "statetest", "--json", path)
+}
+
+// CommandFor returns the command RunStateTest would use for the test.
+func (evm *RethVM) CommandFor(path string, speedTest bool) string {
+	return evm.command(context.Background(), path, speedTest).String()
+}
+
+// RunStateTest implements the Evm interface
 func (evm *RethVM) RunStateTest(path string, out io.Writer, speedTest bool) (*tracingResult, error) {
+	return evm.RunStateTestContext(context.Background(), path, out, speedTest)
+}
+
+// RunStateTestContext implements the CancellableEvm interface. The process
+// is killed if the context is cancelled.
+func (evm *RethVM) RunStateTestContext(ctx context.Context, path string, out io.Writer, speedTest bool) (*tracingResult, error) {
+	out = evm.filterFields(out)
 	var (
 		t0     = time.Now()
 		stderr io.ReadCloser
+		tail   *tailBuffer
 		err    error
-		cmd    *exec.Cmd
+		cmd    = evm.command(ctx, path, speedTest)
 	)
-	cmd = exec.Command(evm.path, "statetest", "--json", path)
-	if speedTest {
-		cmd = exec.Command(evm.path, "statetest", "--json-outcome", path)
-	}
 
-	if stderr, err = cmd.StderrPipe(); err != nil {
+	if stderr, err = evm.tracePipe(cmd, StderrStream); err != nil {
 		return nil, err
 	}
+	stderr, tail = captureStderr(cmd, stderr)
 	if err = cmd.Start(); err != nil {
 		return nil, err
 	}
 
-	evm.Copy(out, stderr)
-	err = cmd.Wait()
-	duration, slow := evm.stats.TraceDone(t0)
+	_, summary := evm.copyUntilEnd(out, stderr)
+	err = newExecError(cmd, tail, cmd.Wait())
+	duration, slow := evm.stats.TraceDone(t0, cmd.String())
 
 	// If revm exits with 1 on stateroot errors, uncomment to ignore:
-	//if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 1 {
+	//if execErr := new(ExecError); errors.As(err, &execErr) && execErr.ExitCode == 1 {
 	//	err = nil
 	//}
 
@@ -122,9 +142,15 @@ func (evm *RethVM) RunStateTest(path string, out io.Writer, speedTest bool) (*tr
 		Slow:     slow,
 		ExecTime: duration,
 		Cmd:      cmd.String(),
+		Output:   summary.Output,
+		Error:    summary.Error,
+		LogsHash: summary.LogsHash,
 	}, err
 }
 
+// reportsOutput implements the outputReporter interface.
+func (evm *RethVM) reportsOutput() {}
+
 func (vm *RethVM) Close() {
 }
 
@@ -164,10 +190,17 @@ type revmStructLogMarshaling struct {
 }
 
 func (evm *RethVM) Copy(out io.Writer, input io.Reader) {
+	evm.copyUntilEnd(out, input)
+}
+
+// copyUntilEnd returns the stateroot, and the execution summary reported by
+// the client.
+func (evm *RethVM) copyUntilEnd(out io.Writer, input io.Reader) (stateRoot, execSummary) {
 	buf := bufferPool.Get().([]byte)
 	//lint:ignore SA6002: argument should be pointer-like to avoid allocations.
 	defer bufferPool.Put(buf)
 	var stateRoot stateRoot
+	var summary execSummary
 	scanner := bufio.NewScanner(input)
 	scanner.Buffer(buf, 32*1024*1024)
 
@@ -177,6 +210,7 @@ func (evm *RethVM) Copy(out io.Writer, input io.Reader) {
 		if bytes.Contains(data, []byte("stateRoot")) {
 			if stateRoot.StateRoot == "" {
 				_ = json.Unmarshal(data, &stateRoot)
+				summary.update(data)
 				continue
 			}
 		}
@@ -203,16 +237,16 @@ func (evm *RethVM) Copy(out io.Writer, input io.Reader) {
 		if elem.Op == 0x0 {
 			continue
 		}
-		jsondata := FastMarshal(&elem)
-		if _, err := out.Write(append(jsondata, '\n')); err != nil {
+		evm.stats.CountOp(byte(elem.Op))
+		evm.trimStep(&elem)
+		if err := writeStep(out, &elem); err != nil {
 			fmt.Fprintf(os.Stderr, "Error writing to out: %v\n", err)
 		}
 	}
-	root, _ := json.Marshal(stateRoot)
-	if _, err := out.Write(append(root, '\n')); err != nil {
+	if err := writeRoot(out, stateRoot); err != nil {
 		fmt.Fprintf(os.Stderr, "Error writing to output: %v\n", err)
-		return
 	}
+	return stateRoot, summary
 }
 
 func (evm *RethVM) Stats() []any {
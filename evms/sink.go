@@ -0,0 +1,163 @@
+// Copyright 2024 Martin Holst Swende
+// This file is part of the goevmlab library.
+//
+// The library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the goevmlab library. If not, see <http://www.gnu.org/licenses/>.
+
+package evms
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+
+	"github.com/theQRL/go-zond/zond/tracers/logger"
+)
+
+// TraceSink receives the normalized trace of an execution. If the writer given
+// to Evm.Copy (or Evm.RunStateTest) implements TraceSink, the steps are
+// delivered to it directly, instead of being marshalled onto the writer.
+type TraceSink interface {
+	// Step is called for each (normalized) step in the trace. The sink must
+	// not modify the log.
+	Step(log *logger.StructLog)
+	// Root is called with the stateroot, after the last step.
+	Root(root string)
+}
+
+// writeStep delivers a step to the sink, or writes it to the writer
+// in the normalized json format.
+func writeStep(out io.Writer, log *logger.StructLog) error {
+	if sink, ok := out.(TraceSink); ok {
+		sink.Step(log)
+		return nil
+	}
+	_, err := out.Write(append(FastMarshal(log), '\n'))
+	return err
+}
+
+// writeRoot delivers the stateroot to the sink, or writes it to the writer
+// in the normalized json format.
+func writeRoot(out io.Writer, root stateRoot) error {
+	if sink, ok := out.(TraceSink); ok {
+		sink.Root(root.StateRoot)
+		return nil
+	}
+	data, _ := json.Marshal(root)
+	if _, err := out.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	// The stateroot ends the trace, so flush any buffered output.
+	if f, ok := out.(flusher); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
+type flusher interface {
+	Flush() error
+}
+
+// OutputOption configures the writer given to Evm.Copy (or Evm.RunStateTest).
+type OutputOption func(io.Writer) io.Writer
+
+// WithBufferedOutput buffers the output in a buffer of the given size. The
+// buffer is flushed when the trace ends, that is, after the stateroot has been
+// written. Writers which are TraceSinks are not buffered.
+func WithBufferedOutput(size int) OutputOption {
+	return func(w io.Writer) io.Writer {
+		if _, ok := w.(TraceSink); ok {
+			return w
+		}
+		return bufio.NewWriterSize(w, size)
+	}
+}
+
+// NewOutput applies the options to the writer.
+func NewOutput(w io.Writer, opts ...OutputOption) io.Writer {
+	for _, opt := range opts {
+		w = opt(w)
+	}
+	return w
+}
+
+// WriterSink is a TraceSink which writes the normalized trace to a writer.
+type WriterSink struct {
+	w io.Writer
+}
+
+// NewWriterSink creates a TraceSink which writes to w.
+func NewWriterSink(w io.Writer) *WriterSink {
+	return &WriterSink{w}
+}
+
+// Step implements TraceSink.
+func (s *WriterSink) Step(log *logger.StructLog) {
+	_ = writeStep(s.w, log)
+}
+
+// Root implements TraceSink.
+func (s *WriterSink) Root(root string) {
+	_ = writeRoot(s.w, stateRoot{root})
+}
+
+// MultiSink delivers the trace to several sinks.
+// It also implements io.Writer, and can thus be passed to Evm.Copy.
+type MultiSink struct {
+	sinks []TraceSink
+	buf   []byte // incomplete line, for Write
+}
+
+// NewMultiSink creates a sink which delivers to all the given sinks.
+func NewMultiSink(sinks ...TraceSink) *MultiSink {
+	return &MultiSink{sinks: sinks}
+}
+
+// Step implements TraceSink.
+func (s *MultiSink) Step(log *logger.StructLog) {
+	for _, sink := range s.sinks {
+		sink.Step(log)
+	}
+}
+
+// Root implements TraceSink.
+func (s *MultiSink) Root(root string) {
+	for _, sink := range s.sinks {
+		sink.Root(root)
+	}
+}
+
+// Write implements io.Writer. The data is expected to be a normalized trace,
+// which is parsed and delivered to the sinks. Lines which are neither steps
+// nor stateroots are ignored.
+func (s *MultiSink) Write(data []byte) (int, error) {
+	s.buf = append(s.buf, data...)
+	for {
+		idx := bytes.IndexByte(s.buf, '\n')
+		if idx < 0 {
+			break
+		}
+		line := s.buf[:idx]
+		if step, stack := parseStep(line); step != nil {
+			s.Step(step.toStructLog(stack))
+		} else {
+			var root stateRoot
+			if err := json.Unmarshal(line, &root); err == nil && len(root.StateRoot) > 0 {
+				s.Root(root.StateRoot)
+			}
+		}
+		s.buf = s.buf[idx+1:]
+	}
+	return len(data), nil
+}
@@ -0,0 +1,101 @@
+// Copyright 2024 Martin Holst Swende
+// This file is part of the goevmlab library.
+//
+// The library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the goevmlab library. If not, see <http://www.gnu.org/licenses/>.
+
+package evms
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/theQRL/go-zond/zond/tracers/logger"
+)
+
+type countingSink struct {
+	steps int
+	root  string
+}
+
+func (s *countingSink) Step(*logger.StructLog) { s.steps++ }
+func (s *countingSink) Root(root string)       { s.root = root }
+
+func TestTraceSinks(t *testing.T) {
+	fname := filepath.Join("testdata", "traces", "negative_refund.json.geth.stderr.txt")
+	raw, err := os.ReadFile(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vm := NewGethEVM("", "")
+	// The reference output, via a plain writer
+	want := new(bytes.Buffer)
+	vm.Copy(want, bytes.NewReader(raw))
+	lines := bytes.Split(bytes.TrimSpace(want.Bytes()), []byte("\n"))
+	var wantRoot stateRoot
+	if err := json.Unmarshal(lines[len(lines)-1], &wantRoot); err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		counter = new(countingSink)
+		have    = new(bytes.Buffer)
+		sinks   = NewMultiSink(counter, NewWriterSink(have))
+	)
+	vm.Copy(sinks, bytes.NewReader(raw))
+	if exp := len(lines) - 1; counter.steps != exp {
+		t.Errorf("wrong step count, have %d want %d", counter.steps, exp)
+	}
+	if counter.root != wantRoot.StateRoot {
+		t.Errorf("wrong root, have %v want %v", counter.root, wantRoot.StateRoot)
+	}
+	if !bytes.Equal(have.Bytes(), want.Bytes()) {
+		t.Errorf("writer sink output differs\nhave:\n%s\nwant:\n%s", have.Bytes(), want.Bytes())
+	}
+	// Writing the normalized output to the sink should have the same effect
+	counter2 := new(countingSink)
+	NewMultiSink(counter2).Write(want.Bytes())
+	if *counter2 != *counter {
+		t.Errorf("parsed sink mismatch, have %+v want %+v", *counter2, *counter)
+	}
+}
+
+func TestBufferedOutput(t *testing.T) {
+	fname := filepath.Join("testdata", "traces", "negative_refund.json.geth.stderr.txt")
+	raw, err := os.ReadFile(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vm := NewGethEVM("", "")
+	want := new(bytes.Buffer)
+	vm.Copy(want, bytes.NewReader(raw))
+
+	have := new(bytes.Buffer)
+	// The buffer is larger than the trace, so nothing is written until the flush.
+	vm.Copy(NewOutput(have, WithBufferedOutput(1024*1024)), bytes.NewReader(raw))
+	if !bytes.Equal(have.Bytes(), want.Bytes()) {
+		t.Fatalf("got %d bytes expected %d", have.Len(), want.Len())
+	}
+	if have, want := bytes.Count(have.Bytes(), []byte("\n")), bytes.Count(want.Bytes(), []byte("\n")); have != want {
+		t.Errorf("got %d lines expected %d", have, want)
+	}
+	// TraceSinks are delivered to directly
+	sink := NewMultiSink(new(countingSink))
+	if out := NewOutput(sink, WithBufferedOutput(1024)); out != io.Writer(sink) {
+		t.Errorf("got %T expected %T", out, sink)
+	}
+}
@@ -0,0 +1,185 @@
+// Copyright 2024 Martin Holst Swende
+// This file is part of the goevmlab library.
+//
+// The library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the goevmlab library. If not, see <http://www.gnu.org/licenses/>.
+
+package evms
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// SocketVM is an Evm which talks to a long-lived server over a unix socket,
+// avoiding the cost of starting a process per test. The protocol is the same
+// as for the batch vms: the path of a test is written as one line, and the
+// server responds with the trace in the geth format, ending with the
+// stateroot. For speed tests, the path is followed by a tab and "speedtest",
+// and the server need not respond with the steps. The connection is reused
+// across tests.
+type SocketVM struct {
+	*GethEVM // for the parsing of the output
+	socket   string
+	timeout  time.Duration
+
+	conn net.Conn
+	mu   sync.Mutex
+}
+
+// defaultSocketTimeout is the time a request may take, before it is aborted.
+const defaultSocketTimeout = time.Minute
+
+// NewSocketVM creates a SocketVM, which connects to the given socket.
+func NewSocketVM(socket, name string) *SocketVM {
+	return &SocketVM{
+		GethEVM: NewGethEVM("", name),
+		socket:  socket,
+		timeout: defaultSocketTimeout,
+	}
+}
+
+// SetTimeout sets the time a request may take, before it is aborted.
+func (evm *SocketVM) SetTimeout(timeout time.Duration) {
+	evm.timeout = timeout
+}
+
+// Instance returns a SocketVM with a connection of its own.
+func (evm *SocketVM) Instance(threadId int) Evm {
+	return &SocketVM{
+		GethEVM: &GethEVM{
+			name:    fmt.Sprintf("%v-%d", evm.name, threadId),
+			stats:   evm.stats,
+			process: evm.process,
+		},
+		socket:  evm.socket,
+		timeout: evm.timeout,
+	}
+}
+
+// CommandFor returns a description of the request RunStateTest makes.
+func (evm *SocketVM) CommandFor(path string, speedTest bool) string {
+	return fmt.Sprintf("%v < %v", evm.socket, path)
+}
+
+// request sends the path to the server, and copies the response to out. The
+// request is aborted when the context is cancelled, by closing the connection.
+func (evm *SocketVM) request(ctx context.Context, path string, out io.Writer, speedTest bool) (stateRoot, execSummary, error) {
+	evm.mu.Lock()
+	defer evm.mu.Unlock()
+	if evm.conn == nil {
+		conn, err := net.Dial("unix", evm.socket)
+		if err != nil {
+			return stateRoot{}, execSummary{}, err
+		}
+		evm.conn = conn
+	}
+	// Reconnect on the next request, since the position in the stream is
+	// unknown after a failure
+	reset := func() {
+		evm.conn.Close()
+		evm.conn = nil
+	}
+	deadline := time.Now().Add(evm.timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	if err := evm.conn.SetDeadline(deadline); err != nil {
+		reset()
+		return stateRoot{}, execSummary{}, err
+	}
+	conn := evm.conn
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	line := path
+	if speedTest {
+		line += "\tspeedtest"
+	}
+	if _, err := fmt.Fprintf(conn, "%v\n", line); err != nil {
+		stop()
+		reset()
+		if ctx.Err() != nil {
+			return stateRoot{}, execSummary{}, ctx.Err()
+		}
+		return stateRoot{}, execSummary{}, err
+	}
+	root, summary := evm.copyUntilEnd(out, conn)
+	// If the context was cancelled meanwhile, the connection is closed
+	if !stop() || root.StateRoot == "" {
+		// The connection was closed, timed out, or the response was malformed
+		reset()
+		if root.StateRoot == "" {
+			if ctx.Err() != nil {
+				return root, summary, ctx.Err()
+			}
+			return root, summary, errors.New("socket: no stateroot in response")
+		}
+	}
+	return root, summary, nil
+}
+
+// RunStateTest implements the Evm interface.
+func (evm *SocketVM) RunStateTest(path string, out io.Writer, speedTest bool) (*tracingResult, error) {
+	return evm.RunStateTestContext(context.Background(), path, out, speedTest)
+}
+
+// RunStateTestContext implements the CancellableEvm interface. The request is
+// aborted if the context is cancelled, or its deadline passes.
+func (evm *SocketVM) RunStateTestContext(ctx context.Context, path string, out io.Writer, speedTest bool) (*tracingResult, error) {
+	out = evm.filterFields(out)
+	var (
+		t0  = time.Now()
+		cmd = evm.CommandFor(path, speedTest)
+	)
+	_, summary, err := evm.request(ctx, path, out, speedTest)
+	if err != nil {
+		return &tracingResult{Cmd: cmd}, err
+	}
+	duration, slow := evm.stats.TraceDone(t0, cmd)
+	return &tracingResult{
+		Slow:     slow,
+		ExecTime: duration,
+		Cmd:      cmd,
+		Output:   summary.Output,
+		Error:    summary.Error,
+		LogsHash: summary.LogsHash,
+	}, nil
+}
+
+// GetStateRoot implements the Evm interface.
+func (evm *SocketVM) GetStateRoot(path string) (root, command string, err error) {
+	sRoot, _, err := evm.request(context.Background(), path, io.Discard, true)
+	return sRoot.StateRoot, evm.CommandFor(path, false), err
+}
+
+// Copy implements the Evm interface. The server responds in the geth format.
+func (evm *SocketVM) Copy(out io.Writer, input io.Reader) {
+	evm.GethEVM.Copy(out, input)
+}
+
+// reportsOutput implements the outputReporter interface.
+func (evm *SocketVM) reportsOutput() {}
+
+// Close closes the connection to the server.
+func (evm *SocketVM) Close() {
+	evm.mu.Lock()
+	defer evm.mu.Unlock()
+	if evm.conn != nil {
+		evm.conn.Close()
+		evm.conn = nil
+	}
+}
@@ -0,0 +1,216 @@
+// Copyright 2024 Martin Holst Swende
+// This file is part of the goevmlab library.
+//
+// The library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the goevmlab library. If not, see <http://www.gnu.org/licenses/>.
+
+package evms
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSocketVM(t *testing.T) {
+	trace, err := os.ReadFile("testdata/traces/00000006-naivefuzz-0.json.geth.stderr.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	socket := filepath.Join(t.TempDir(), "vm.sock")
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	// A fake server, responding with the canned trace to each request.
+	var accepted atomic.Int32
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			accepted.Add(1)
+			go func() {
+				defer conn.Close()
+				scanner := bufio.NewScanner(conn)
+				for scanner.Scan() {
+					if _, err := conn.Write(trace); err != nil {
+						return
+					}
+				}
+			}()
+		}
+	}()
+	var (
+		root = "0xad1024c87b5548e77c937aa50f72b6cb620d278f4dd79bae7f78f71ff75af458"
+		vm   = NewSocketVM(socket, "socket")
+	)
+	defer vm.Close()
+	for i := 0; i < 2; i++ {
+		var out bytes.Buffer
+		if _, err := vm.RunStateTest("test.json", &out, false); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(out.String(), root) {
+			t.Errorf("run %d: stateroot missing from output", i)
+		}
+		if n := strings.Count(out.String(), `{"depth":`); n == 0 {
+			t.Errorf("run %d: no steps in output", i)
+		}
+	}
+	if have, _, err := vm.GetStateRoot("test.json"); err != nil || have != root {
+		t.Errorf("got %v, %v expected %v", have, err, root)
+	}
+	if have := accepted.Load(); have != 1 {
+		t.Errorf("got %d connections expected 1", have)
+	}
+}
+
+// fakeSocketServer listens on a socket, and calls handle for each line
+// received. The connection is closed when handle returns false.
+func fakeSocketServer(t *testing.T, handle func(conn net.Conn, line string) bool) string {
+	socket := filepath.Join(t.TempDir(), "vm.sock")
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				scanner := bufio.NewScanner(conn)
+				for scanner.Scan() {
+					if !handle(conn, scanner.Text()) {
+						return
+					}
+				}
+			}()
+		}
+	}()
+	return socket
+}
+
+func TestSocketVMFailures(t *testing.T) {
+	var (
+		root  = `{"stateRoot":"0xad1024c87b5548e77c937aa50f72b6cb620d278f4dd79bae7f78f71ff75af458"}` + "\n"
+		lines = make(chan string, 10)
+	)
+	socket := fakeSocketServer(t, func(conn net.Conn, line string) bool {
+		lines <- line
+		switch {
+		case strings.HasPrefix(line, "hang"):
+			time.Sleep(time.Second)
+			return false
+		case strings.HasPrefix(line, "close"):
+			return false
+		}
+		conn.Write([]byte(root))
+		return true
+	})
+	vm := NewSocketVM(socket, "socket")
+	vm.SetTimeout(100 * time.Millisecond)
+	defer vm.Close()
+
+	// The speedtest flag is passed to the server
+	if _, err := vm.RunStateTest("test.json", io.Discard, true); err != nil {
+		t.Fatal(err)
+	}
+	if have, want := <-lines, "test.json\tspeedtest"; have != want {
+		t.Errorf("got %q expected %q", have, want)
+	}
+	// A server which does not respond in time
+	t0 := time.Now()
+	if _, err := vm.RunStateTest("hang.json", io.Discard, false); err == nil {
+		t.Errorf("expected error")
+	}
+	if d := time.Since(t0); d > 500*time.Millisecond {
+		t.Errorf("request took %v, expected a timeout", d)
+	}
+	<-lines
+	if vm.conn != nil {
+		t.Errorf("expected the connection to be dropped")
+	}
+	// A server which closes the connection without a response
+	if _, err := vm.RunStateTest("close.json", io.Discard, false); err == nil {
+		t.Errorf("expected error")
+	}
+	<-lines
+	if vm.conn != nil {
+		t.Errorf("expected the connection to be dropped")
+	}
+	// The next request reconnects
+	if _, err := vm.RunStateTest("test.json", io.Discard, false); err != nil {
+		t.Fatal(err)
+	}
+	if have, want := <-lines, "test.json"; have != want {
+		t.Errorf("got %q expected %q", have, want)
+	}
+}
+
+func TestSocketVMContext(t *testing.T) {
+	var (
+		root    = `{"stateRoot":"0xad1024c87b5548e77c937aa50f72b6cb620d278f4dd79bae7f78f71ff75af458"}` + "\n"
+		release = make(chan struct{})
+	)
+	defer close(release)
+	socket := fakeSocketServer(t, func(conn net.Conn, line string) bool {
+		if strings.HasPrefix(line, "hang") {
+			<-release
+			return false
+		}
+		conn.Write([]byte(root))
+		return true
+	})
+	var vm CancellableEvm = NewSocketVM(socket, "socket")
+	defer vm.Close()
+
+	if _, err := vm.RunStateTestContext(context.Background(), "test.json", io.Discard, false); err != nil {
+		t.Fatal(err)
+	}
+	// A cancelled request is aborted before the timeout
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	t0 := time.Now()
+	if _, err := vm.RunStateTestContext(ctx, "hang.json", io.Discard, false); !errors.Is(err, context.Canceled) {
+		t.Errorf("got %v expected %v", err, context.Canceled)
+	}
+	if d := time.Since(t0); d > 500*time.Millisecond {
+		t.Errorf("request took %v, expected it to be cancelled", d)
+	}
+	// As is one with a deadline
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := vm.RunStateTestContext(ctx, "hang.json", io.Discard, false); err == nil {
+		t.Errorf("expected error")
+	}
+	// The next request reconnects
+	if _, err := vm.RunStateTestContext(context.Background(), "test.json", io.Discard, false); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,182 @@
+// Copyright 2024 Martin Holst Swende
+// This file is part of the goevmlab library.
+//
+// The library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the goevmlab library. If not, see <http://www.gnu.org/licenses/>.
+
+package evms
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/rgeraldes24/goevmlab/ops"
+)
+
+// errDiverged is used to abort the writers once the outputs have diverged.
+var errDiverged = errors.New("outputs diverged")
+
+// Divergence describes the first difference between the outputs of two VMs.
+type Divergence struct {
+	Step int    // Index of the first differing line of output
+	A, B string // The differing lines, empty if the output had ended
+}
+
+// StreamCompare runs the test on both VMs concurrently, and compares the
+// outputs line by line, as they are produced. On the first divergence, both
+// executions are cancelled and the divergence is returned. If the outputs are
+// equal, nil is returned. If a vm cannot be run, e.g. because the client
+// fails to start, the error is returned instead of a divergence.
+func StreamCompare(ctx context.Context, a, b CancellableEvm, path string) (*Divergence, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		vms     = []CancellableEvm{a, b}
+		readers = make([]*io.PipeReader, 2)
+		errs    = make([]error, 2)
+		wg      sync.WaitGroup
+	)
+	for i, vm := range vms {
+		r, w := io.Pipe()
+		readers[i] = r
+		wg.Add(1)
+		go func(i int, vm CancellableEvm, w *io.PipeWriter) {
+			defer wg.Done()
+			_, err := vm.RunStateTestContext(ctx, path, w, false)
+			errs[i] = err
+			w.CloseWithError(err)
+		}(i, vm, w)
+	}
+	var (
+		scanA = bufio.NewScanner(readers[0])
+		scanB = bufio.NewScanner(readers[1])
+	)
+	scanA.Buffer(make([]byte, 1024*1024), 32*1024*1024)
+	scanB.Buffer(make([]byte, 1024*1024), 32*1024*1024)
+	div := firstDivergence(scanA, scanB)
+	if div != nil {
+		cancel()
+	}
+	// Unblock any pending writes, and wait for the executions to end
+	for _, r := range readers {
+		r.CloseWithError(errDiverged)
+	}
+	wg.Wait()
+	// A vm which could not be run has ended its output prematurely, which is
+	// not a divergence. The errors of the cancelled executions, and the exit
+	// errors of the clients, are expected though.
+	for _, err := range errs {
+		var execErr *ExecError
+		if err != nil && !errors.As(err, &execErr) &&
+			!errors.Is(err, context.Canceled) && !errors.Is(err, errDiverged) {
+			return nil, err
+		}
+	}
+	if div != nil {
+		return div, nil
+	}
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := scanA.Err(); err != nil {
+		return nil, err
+	}
+	return nil, scanB.Err()
+}
+
+// firstDivergence reads both scanners line by line, and returns the first
+// difference, or nil if they deliver the same lines.
+func firstDivergence(scanA, scanB *bufio.Scanner) *Divergence {
+	for step := 0; ; step++ {
+		okA, okB := scanA.Scan(), scanB.Scan()
+		if !okA && !okB {
+			return nil
+		}
+		var lineA, lineB string
+		if okA {
+			lineA = scanA.Text()
+		}
+		if okB {
+			lineB = scanB.Text()
+		}
+		if okA != okB || lineA != lineB {
+			return &Divergence{Step: step, A: lineA, B: lineB}
+		}
+	}
+}
+
+// DivergenceStats counts, across the given divergences, which opcode was
+// executing at the point of divergence. The opcode is taken from the line of
+// the first VM, or from the second if the first output had ended. Divergences
+// where neither line is a step, e.g. differing stateroots, are not counted.
+func DivergenceStats(diffs []*Divergence) map[ops.OpCode]int {
+	stats := make(map[ops.OpCode]int)
+	for _, div := range diffs {
+		if div == nil {
+			continue
+		}
+		for _, line := range []string{div.A, div.B} {
+			var step struct {
+				Op *uint8 `json:"op"`
+			}
+			if err := json.Unmarshal([]byte(line), &step); err == nil && step.Op != nil {
+				stats[ops.OpCode(*step.Op)]++
+				break
+			}
+		}
+	}
+	return stats
+}
+
+// IsPrefix reads two normalized traces, and reports whether the steps of short
+// are equal to the leading steps of long, i.e. whether the execution in short
+// merely ended earlier than in long. Lines which are not steps, such as the
+// stateroot, are ignored. The returned index is the number of matching steps:
+// on a mismatch, it is the index of the first step which differs.
+func IsPrefix(short, long io.Reader) (bool, int, error) {
+	var (
+		scanS = bufio.NewScanner(short)
+		scanL = bufio.NewScanner(long)
+	)
+	scanS.Buffer(make([]byte, 1024*1024), 32*1024*1024)
+	scanL.Buffer(make([]byte, 1024*1024), 32*1024*1024)
+	for step := 0; ; step++ {
+		if !nextStep(scanS) {
+			return scanS.Err() == nil, step, scanS.Err()
+		}
+		if !nextStep(scanL) {
+			return false, step, scanL.Err()
+		}
+		if !bytes.Equal(scanS.Bytes(), scanL.Bytes()) {
+			return false, step, nil
+		}
+	}
+}
+
+// nextStep advances the scanner to the next line which is a normalized step.
+func nextStep(scanner *bufio.Scanner) bool {
+	for scanner.Scan() {
+		if bytes.HasPrefix(scanner.Bytes(), []byte(`{"depth":`)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,138 @@
+// Copyright 2024 Martin Holst Swende
+// This file is part of the goevmlab library.
+//
+// The library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the goevmlab library. If not, see <http://www.gnu.org/licenses/>.
+
+package evms
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rgeraldes24/goevmlab/ops"
+)
+
+// mockTrace creates a normalized trace with the given number of steps,
+// where the gas differs from step divergeAt and onwards.
+func mockTrace(steps, divergeAt int) []byte {
+	var b strings.Builder
+	for i := 0; i < steps; i++ {
+		gas := 1000000 - i
+		if i >= divergeAt {
+			gas--
+		}
+		fmt.Fprintf(&b, `{"depth":1,"pc":%d,"gas":%d,"op":91,"opName":"JUMPDEST","stack":[]}`+"\n", i, gas)
+	}
+	b.WriteString(`{"stateRoot":"0xa2b3391f7a85bf1ad08dc541a1b99da3c591c156351391f26ec88c557ff12134"}` + "\n")
+	return []byte(b.String())
+}
+
+func TestStreamCompare(t *testing.T) {
+	const steps, divergeAt = 1000, 10
+	var (
+		a = NewMockVM("a", mockTrace(steps, steps))
+		b = NewMockVM("b", mockTrace(steps, divergeAt))
+	)
+	a.StepDelay, b.StepDelay = time.Millisecond, time.Millisecond
+	div, err := StreamCompare(context.Background(), a, b, "test.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if div == nil {
+		t.Fatal("expected divergence")
+	}
+	if div.Step != divergeAt {
+		t.Errorf("wrong divergence step, have %d want %d", div.Step, divergeAt)
+	}
+	for _, vm := range []*MockVM{a, b} {
+		if !vm.Cancelled() {
+			t.Errorf("%v: not cancelled", vm.Name())
+		}
+		if vm.Lines() >= steps {
+			t.Errorf("%v: ran to completion", vm.Name())
+		}
+	}
+	// Equal outputs
+	b = NewMockVM("b", mockTrace(steps, steps))
+	if div, err := StreamCompare(context.Background(), a, b, "test.json"); err != nil || div != nil {
+		t.Fatalf("expected equality, got %v (err %v)", div, err)
+	}
+	if a.Cancelled() || b.Cancelled() || a.Lines() != steps+1 {
+		t.Errorf("expected full runs, got %d lines", a.Lines())
+	}
+}
+
+func TestStreamCompareStartError(t *testing.T) {
+	var (
+		a = NewGethEVM(filepath.Join(t.TempDir(), "missing"), "a")
+		b = NewMockVM("b", mockTrace(10, 10))
+	)
+	div, err := StreamCompare(context.Background(), a, b, "test.json")
+	if err == nil {
+		t.Fatalf("expected error, got divergence %v", div)
+	}
+	if div != nil {
+		t.Errorf("got %v expected no divergence", div)
+	}
+}
+
+func TestIsPrefix(t *testing.T) {
+	for i, tt := range []struct {
+		short, long []byte
+		prefix      bool
+		index       int
+	}{
+		{mockTrace(5, 5), mockTrace(10, 10), true, 5},
+		{mockTrace(10, 10), mockTrace(10, 10), true, 10},
+		{mockTrace(5, 2), mockTrace(10, 10), false, 2},
+		{mockTrace(10, 10), mockTrace(5, 5), false, 5},
+	} {
+		prefix, index, err := IsPrefix(bytes.NewReader(tt.short), bytes.NewReader(tt.long))
+		if err != nil {
+			t.Fatalf("test %d: %v", i, err)
+		}
+		if prefix != tt.prefix || index != tt.index {
+			t.Errorf("test %d: got %v, %d expected %v, %d", i, prefix, index, tt.prefix, tt.index)
+		}
+	}
+}
+
+func TestDivergenceStats(t *testing.T) {
+	step := func(op ops.OpCode, gas int) string {
+		return fmt.Sprintf(`{"depth":1,"pc":0,"gas":%d,"op":%d,"opName":"%v","stack":[]}`, gas, byte(op), op)
+	}
+	diffs := []*Divergence{
+		{Step: 3, A: step(ops.SSTORE, 100), B: step(ops.SSTORE, 99)},
+		{Step: 7, A: step(ops.CALL, 100), B: step(ops.CALL, 98)},
+		{Step: 1, A: step(ops.SSTORE, 50), B: step(ops.SSTORE, 51)},
+		{Step: 9, A: "", B: step(ops.EXP, 10)},
+		{Step: 5, A: `{"stateRoot":"0x01"}`, B: `{"stateRoot":"0x02"}`},
+		nil,
+	}
+	want := map[ops.OpCode]int{ops.SSTORE: 2, ops.CALL: 1, ops.EXP: 1}
+	have := DivergenceStats(diffs)
+	if len(have) != len(want) {
+		t.Errorf("got %v expected %v", have, want)
+	}
+	for op, n := range want {
+		if have[op] != n {
+			t.Errorf("op %v: got %d expected %d", op, have[op], n)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+// Copyright 2024 Martin Holst Swende
+// This file is part of the goevmlab library.
+//
+// The library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the goevmlab library. If not, see <http://www.gnu.org/licenses/>.
+
+package evms
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rgeraldes24/goevmlab/fuzzing"
+)
+
+// T8nVM is a wrapper around the `evm t8n` state transition tool. Since it is
+// the same binary, it also runs statetests, like GethEVM.
+type T8nVM struct {
+	*GethEVM
+	fork string
+}
+
+// NewT8nVM creates a T8nVM, which executes transitions using the Shanghai fork.
+func NewT8nVM(path string, name string) *T8nVM {
+	return &T8nVM{
+		GethEVM: NewGethEVM(path, name),
+		fork:    "Shanghai",
+	}
+}
+
+func (evm *T8nVM) Instance(int) Evm {
+	return evm
+}
+
+// t8nOutput is the stdout output of `evm t8n`.
+type t8nOutput struct {
+	Result struct {
+		StateRoot string `json:"stateRoot"`
+	} `json:"result"`
+}
+
+// ParseT8nStateRoot reads the post-state root from the output of `evm t8n`.
+func (evm *T8nVM) ParseT8nStateRoot(data []byte) (string, error) {
+	var output t8nOutput
+	if err := json.Unmarshal(data, &output); err != nil {
+		return "", fmt.Errorf("%v: %w", evm.Name(), err)
+	}
+	if output.Result.StateRoot == "" {
+		return "", fmt.Errorf("%v: no stateroot found", evm.Name())
+	}
+	return output.Result.StateRoot, nil
+}
+
+// RunTransition applies the transactions in the txs file, on top of the state
+// in the alloc file, using the block environment in the env file. The
+// normalized traces of all transactions are written to out, followed by the
+// post-state root.
+func (evm *T8nVM) RunTransition(alloc, env, txs string, out io.Writer) (*tracingResult, error) {
+	return evm.runTransition(alloc, env, txs, evm.fork, out)
+}
+
+// runTransition is like RunTransition, but applies the given fork.
+func (evm *T8nVM) runTransition(alloc, env, txs, fork string, out io.Writer) (*tracingResult, error) {
+	out = evm.filterFields(out)
+	t0 := time.Now()
+	dir, err := os.MkdirTemp("", "goevmlab-t8n")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	cmd := evm.execCommand(evm.path, "t8n",
+		"--input.alloc", alloc, "--input.env", env, "--input.txs", txs,
+		"--state.fork", fork, "--trace", "--output.basedir", dir,
+		"--output.result", "stdout", "--output.alloc", "stdout")
+	data, err := cmd.Output()
+	if err != nil {
+		return &tracingResult{Cmd: cmd.String()}, newExecError(cmd, nil, err)
+	}
+	root, err := evm.ParseT8nStateRoot(data)
+	if err != nil {
+		return &tracingResult{Cmd: cmd.String()}, err
+	}
+	// The traces are written to one file per transaction, named
+	// trace-<index>-<hash>.jsonl.
+	traces, err := filepath.Glob(filepath.Join(dir, "trace-*.jsonl"))
+	if err != nil {
+		return &tracingResult{Cmd: cmd.String()}, err
+	}
+	sort.Slice(traces, func(i, j int) bool {
+		return traceIndex(traces[i]) < traceIndex(traces[j])
+	})
+	// The summary of the last transaction is the one reported.
+	var summary execSummary
+	for _, trace := range traces {
+		f, err := os.Open(trace)
+		if err != nil {
+			return &tracingResult{Cmd: cmd.String()}, err
+		}
+		_, summary = evm.copySteps(out, f)
+		f.Close()
+	}
+	if err := writeRoot(out, stateRoot{root}); err != nil {
+		return &tracingResult{Cmd: cmd.String()}, err
+	}
+	duration, slow := evm.stats.TraceDone(t0, cmd.String())
+	return &tracingResult{
+		Slow:     slow,
+		ExecTime: duration,
+		Cmd:      cmd.String(),
+		Output:   summary.Output,
+		Error:    summary.Error,
+		LogsHash: summary.LogsHash,
+	}, nil
+}
+
+// traceIndex returns the transaction index from a trace file name.
+func traceIndex(path string) int {
+	parts := strings.SplitN(filepath.Base(path), "-", 3)
+	if len(parts) < 3 {
+		return -1
+	}
+	index, _ := strconv.Atoi(parts[1])
+	return index
+}
+
+// CompareTransitions runs the transactions of the test on all the vms, using
+// the fork of the test, and compares the resulting post-state roots, keyed by
+// vm name.
+func CompareTransitions(vms []*T8nVM, test *fuzzing.MultiTxTest) (agree bool, roots map[string]string, err error) {
+	dir, err := os.MkdirTemp("", "goevmlab-multitx")
+	if err != nil {
+		return false, nil, err
+	}
+	defer os.RemoveAll(dir)
+	alloc, env, txs, err := test.WriteT8nInputs(dir)
+	if err != nil {
+		return false, nil, err
+	}
+	roots = make(map[string]string)
+	agree = true
+	for i, vm := range vms {
+		var (
+			out  bytes.Buffer
+			fork = test.Fork()
+		)
+		if fork == "" {
+			fork = vm.fork
+		}
+		if _, err := vm.runTransition(alloc, env, txs, fork, &out); err != nil {
+			return false, roots, fmt.Errorf("%v: %w", vm.Name(), err)
+		}
+		lines := bytes.Split(bytes.TrimSpace(out.Bytes()), []byte("\n"))
+		var root stateRoot
+		if err := json.Unmarshal(lines[len(lines)-1], &root); err != nil {
+			return false, roots, fmt.Errorf("%v: %w", vm.Name(), err)
+		}
+		roots[vm.Name()] = root.StateRoot
+		if i > 0 && root.StateRoot != roots[vms[0].Name()] {
+			agree = false
+		}
+	}
+	return agree, roots, nil
+}
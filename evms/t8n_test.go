@@ -0,0 +1,134 @@
+// Copyright 2024 Martin Holst Swende
+// This file is part of the goevmlab library.
+//
+// The library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the goevmlab library. If not, see <http://www.gnu.org/licenses/>.
+
+package evms
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rgeraldes24/goevmlab/fuzzing"
+	"github.com/theQRL/go-zond/common"
+)
+
+func TestT8nVM(t *testing.T) {
+	// A fake evm binary, which outputs the expected result, and writes
+	// the traces for two transactions into the basedir.
+	var (
+		dir   = t.TempDir()
+		input = filepath.Join("testdata", "t8n")
+	)
+	exp, err := filepath.Abs(filepath.Join(input, "exp.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	script := `#!/bin/sh
+while [ $# -gt 0 ]; do
+	if [ "$1" = "--output.basedir" ]; then basedir=$2; fi
+	shift
+done
+echo '{"pc":0,"op":96,"gas":"0x2","gasCost":"0x3","memSize":0,"stack":[],"depth":1,"refund":0,"opName":"PUSH1"}' > $basedir/trace-1-0xbb.jsonl
+echo '{"output":"","gasUsed":"0x3"}' >> $basedir/trace-1-0xbb.jsonl
+echo '{"pc":0,"op":96,"gas":"0x1","gasCost":"0x3","memSize":0,"stack":[],"depth":1,"refund":0,"opName":"PUSH1"}' > $basedir/trace-0-0xaa.jsonl
+echo '{"output":"","gasUsed":"0x3"}' >> $basedir/trace-0-0xaa.jsonl
+cat ` + exp + "\n"
+	bin := filepath.Join(dir, "evm")
+	if err := os.WriteFile(bin, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	var (
+		vm  = NewT8nVM(bin, "t8n")
+		out bytes.Buffer
+	)
+	_, err = vm.RunTransition(filepath.Join(input, "alloc.json"),
+		filepath.Join(input, "env.json"), filepath.Join(input, "txs.json"), &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("got %d lines expected 3:\n%v", len(lines), out.String())
+	}
+	// The traces should be ordered by transaction index
+	if !strings.Contains(lines[0], `"gas":1`) || !strings.Contains(lines[1], `"gas":2`) {
+		t.Errorf("wrong trace order:\n%v", out.String())
+	}
+	want := `{"stateRoot":"0x84208a19bc2b46ada7445180c1db162be5b39b9abc8c0a54b05d32943eae4e13"}`
+	if lines[2] != want {
+		t.Errorf("got root %v expected %v", lines[2], want)
+	}
+}
+
+// fakeT8n creates a fake evm binary, which reports the given post-state root
+// for any transition. The arguments are written to the file "args" next to
+// the binary.
+func fakeT8n(t *testing.T, name, root string) *T8nVM {
+	bin := filepath.Join(t.TempDir(), "evm")
+	script := fmt.Sprintf("#!/bin/sh\necho \"$@\" > $(dirname $0)/args\necho '{\"result\":{\"stateRoot\":\"%v\"}}'\n", root)
+	if err := os.WriteFile(bin, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return NewT8nVM(bin, name)
+}
+
+func TestCompareTransitions(t *testing.T) {
+	dest := common.HexToAddress("0xc0de")
+	gst := fuzzing.BasicStateTest("Shanghai")
+	gst.SetCode(dest, []byte{0x60, 0x01, 0x60, 0x00, 0x55})
+	test := fuzzing.NewMultiTxTest(gst)
+	test.AddTx(dest, nil)
+	test.AddTx(dest, nil)
+
+	var (
+		a = fakeT8n(t, "a", "0xaa")
+		b = fakeT8n(t, "b", "0xaa")
+		c = fakeT8n(t, "c", "0xcc")
+	)
+	agree, roots, err := CompareTransitions([]*T8nVM{a, b}, test)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !agree {
+		t.Errorf("expected agreement, got %v", roots)
+	}
+	agree, roots, err = CompareTransitions([]*T8nVM{a, b, c}, test)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if agree {
+		t.Errorf("expected disagreement, got %v", roots)
+	}
+	if have, want := roots["c"], "0xcc"; have != want {
+		t.Errorf("got %v expected %v", have, want)
+	}
+	// The fork of the test is applied
+	test = fuzzing.NewMultiTxTest(fuzzing.BasicStateTest("Prague"))
+	test.AddTx(dest, nil)
+	if _, _, err = CompareTransitions([]*T8nVM{a}, test); err != nil {
+		t.Fatal(err)
+	}
+	args, err := os.ReadFile(filepath.Join(filepath.Dir(a.path), "args"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "--state.fork Prague "; !strings.Contains(string(args), want) {
+		t.Errorf("got args %v expected %v", string(args), want)
+	}
+}
@@ -2,8 +2,14 @@ package evms
 
 import (
 	"bytes"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"os/exec"
+	"strings"
+
+	"github.com/rgeraldes24/goevmlab/ops"
+	"github.com/theQRL/go-zond/zond/tracers/logger"
 )
 
 const (
@@ -39,3 +45,61 @@ func StdErrOutput(c *exec.Cmd) ([]byte, error) {
 	err := c.Run()
 	return b.Bytes(), err
 }
+
+// checkStackDelta returns an error if the stack size of cur is not what the
+// execution of prev should have resulted in. Steps which are not consecutive
+// within the same call frame, or where prev failed, are not checked.
+func checkStackDelta(prev, cur *logger.StructLog) error {
+	if prev == nil || prev.Depth != cur.Depth || prev.Err != nil {
+		return nil
+	}
+	op := ops.OpCode(prev.Op)
+	if !ops.IsDefined(op) {
+		return nil
+	}
+	if want := len(prev.Stack) + op.Stackdelta(); len(cur.Stack) != want {
+		return fmt.Errorf("pc %d: stack size %d after %v with stack size %d, expected %d",
+			cur.Pc, len(cur.Stack), op, len(prev.Stack), want)
+	}
+	return nil
+}
+
+// execSummary is the summary which geth-style clients output after the
+// execution, e.g.
+//
+//	{"output":"","gasUsed":"0x2d1cc4","time":233624,"error":"gas uint64 overflow"}
+type execSummary struct {
+	Output   string `json:"output"`
+	Error    string `json:"error"`
+	LogsHash string `json:"logsHash"`
+}
+
+// update sets the fields present in the (depth zero) line.
+func (s *execSummary) update(data []byte) {
+	var line struct {
+		Output *string `json:"output"`
+		Error  *string `json:"error"`
+		// Clients differ in the name of the logs hash
+		LogsHash     *string `json:"logsHash"`
+		PostLogsHash *string `json:"postLogsHash"`
+		LogsRoot     *string `json:"logsRoot"`
+	}
+	if err := json.Unmarshal(data, &line); err != nil {
+		return
+	}
+	for _, hash := range []*string{line.LogsHash, line.PostLogsHash, line.LogsRoot} {
+		if hash != nil && len(*hash) > 0 {
+			s.LogsHash = "0x" + strings.ToLower(strings.TrimPrefix(*hash, "0x"))
+		}
+	}
+	if line.Output != nil {
+		// Clients differ in the notation of the output, e.g. "0x" or ""
+		s.Output = strings.ToLower(strings.TrimPrefix(*line.Output, "0x"))
+		if len(s.Output) > 0 {
+			s.Output = "0x" + s.Output
+		}
+	}
+	if line.Error != nil && len(*line.Error) > 0 {
+		s.Error = *line.Error
+	}
+}
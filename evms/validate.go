@@ -0,0 +1,93 @@
+// Copyright 2024 Martin Holst Swende
+// This file is part of the goevmlab library.
+//
+// The library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the goevmlab library. If not, see <http://www.gnu.org/licenses/>.
+
+package evms
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/rgeraldes24/goevmlab/ops"
+)
+
+// Anomaly is a step of a trace which is inconsistent with the previous step.
+type Anomaly struct {
+	Line   int    // Index of the offending line of the trace
+	Pc     uint64 // The pc of the offending step
+	Reason string
+}
+
+func (a Anomaly) String() string {
+	return fmt.Sprintf("line %d, pc %d: %v", a.Line, a.Pc, a.Reason)
+}
+
+// traceStep is the part of a trace line which is needed for validation.
+type traceStep struct {
+	Depth int    `json:"depth"`
+	Pc    uint64 `json:"pc"`
+	Op    *uint8 `json:"op"`
+	Error string `json:"error"`
+}
+
+// ValidateTrace reads a trace, and checks that within each call frame, the
+// pc advances by exactly the size of the instruction after any op which does
+// not jump. Lines which are not steps are ignored. The trace may be either
+// normalized or in the geth format.
+func ValidateTrace(r io.Reader) []Anomaly {
+	var (
+		scanner   = bufio.NewScanner(r)
+		anomalies []Anomaly
+		prevDepth int
+		// last holds the previous step of each call frame, by depth
+		last = make(map[int]*traceStep)
+	)
+	scanner.Buffer(make([]byte, 1024*1024), 32*1024*1024)
+	for line := 0; scanner.Scan(); line++ {
+		var step traceStep
+		if err := json.Unmarshal(scanner.Bytes(), &step); err != nil || step.Op == nil {
+			continue
+		}
+		if step.Depth > prevDepth {
+			// A new frame, unrelated to any earlier frame at this depth
+			delete(last, step.Depth)
+		}
+		prevDepth = step.Depth
+		prev := last[step.Depth]
+		last[step.Depth] = &step
+		if prev == nil || len(prev.Error) > 0 {
+			continue
+		}
+		// Geth repeats a failing step, with the error
+		if step.Pc == prev.Pc && len(step.Error) > 0 {
+			continue
+		}
+		switch op := ops.OpCode(*prev.Op); op {
+		case ops.JUMP, ops.JUMPI, ops.STOP, ops.RETURN, ops.REVERT, ops.INVALID, ops.SELFDESTRUCT:
+			continue
+		default:
+			if want := prev.Pc + 1 + uint64(op.PushSize()); step.Pc != want {
+				anomalies = append(anomalies, Anomaly{
+					Line:   line,
+					Pc:     step.Pc,
+					Reason: fmt.Sprintf("pc after %v at pc %d, expected %d", op, prev.Pc, want),
+				})
+			}
+		}
+	}
+	return anomalies
+}
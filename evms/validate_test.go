@@ -0,0 +1,58 @@
+// Copyright 2024 Martin Holst Swende
+// This file is part of the goevmlab library.
+//
+// The library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the goevmlab library. If not, see <http://www.gnu.org/licenses/>.
+
+package evms
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidateTrace(t *testing.T) {
+	trace := strings.Join([]string{
+		`{"depth":1,"pc":0,"gas":100,"op":96,"opName":"PUSH1","stack":[]}`,
+		`{"depth":1,"pc":2,"gas":97,"op":96,"opName":"PUSH1","stack":["0x1"]}`,
+		`{"depth":1,"pc":4,"gas":94,"op":1,"opName":"ADD","stack":["0x1","0x1"]}`,
+		`{"depth":1,"pc":1,"gas":91,"op":91,"opName":"JUMPDEST","stack":["0x2"]}`, // backwards after ADD
+		`{"depth":1,"pc":2,"gas":90,"op":86,"opName":"JUMP","stack":["0x2"]}`,
+		`{"depth":1,"pc":7,"gas":82,"op":91,"opName":"JUMPDEST","stack":[]}`,
+		`{"stateRoot":"0xa2b3391f7a85bf1ad08dc541a1b99da3c591c156351391f26ec88c557ff12134"}`,
+	}, "\n")
+	anomalies := ValidateTrace(strings.NewReader(trace))
+	if len(anomalies) != 1 {
+		t.Fatalf("got %d anomalies expected 1: %v", len(anomalies), anomalies)
+	}
+	if have, want := anomalies[0], (Anomaly{Line: 3, Pc: 1}); have.Line != want.Line || have.Pc != want.Pc {
+		t.Errorf("got %v expected line %d, pc %d", have, want.Line, want.Pc)
+	}
+	// The traces of the clients are sane
+	files, err := filepath.Glob(filepath.Join("testdata", "traces", "*.geth.stderr.txt"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no traces found: %v", err)
+	}
+	for _, file := range files {
+		f, err := os.Open(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if anomalies := ValidateTrace(f); len(anomalies) > 0 {
+			t.Errorf("%v: unexpected anomalies: %v", file, anomalies)
+		}
+		f.Close()
+	}
+}
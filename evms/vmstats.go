@@ -1,46 +1,206 @@
 package evms
 
 import (
+	"fmt"
+	"io"
 	"time"
 
+	"sync"
 	"sync/atomic"
 
+	"github.com/rgeraldes24/goevmlab/ops"
 	"github.com/rgeraldes24/goevmlab/utils"
+	"github.com/theQRL/go-zond/log"
 )
 
+// execTimeBuckets are the upper bounds of the execution time histogram.
+var execTimeBuckets = []time.Duration{
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	5 * time.Second,
+}
+
+// VmStat holds the metrics of a vm. It is safe for concurrent use, as the
+// instances of a vm may share it.
 type VmStat struct {
 	// Some metrics
+	mu                 sync.Mutex // Protects the average and the longest time
 	tracingSpeedWMA    utils.SlidingAverage
 	longestTracingTime time.Duration
 	numExecs           atomic.Uint64
+	stackAnomalies     atomic.Uint64
+	numSlow            atomic.Uint64
+	totalTime          atomic.Int64 // nanoseconds
+	// execTimes[i] counts the executions within execTimeBuckets[i], the last
+	// element counts the ones exceeding all buckets.
+	execTimes [9]atomic.Uint64
+	opCounts  [256]atomic.Uint64
+
+	slowThreshold atomic.Int64 // nanoseconds; if zero, a run is slow if it's the slowest so far
+	logSlow       atomic.Bool
+}
+
+// SetSlowThreshold makes runs exceeding the given duration count as slow.
+// By default, a run is slow if it is slower than all previous runs.
+func (stat *VmStat) SetSlowThreshold(d time.Duration) {
+	stat.slowThreshold.Store(int64(d))
+}
+
+// SetLogSlow enables or disables logging of slow runs.
+func (stat *VmStat) SetLogSlow(enabled bool) {
+	stat.logSlow.Store(enabled)
+}
+
+// SlowCount returns the number of runs which were slow.
+func (stat *VmStat) SlowCount() uint64 {
+	return stat.numSlow.Load()
 }
 
 // TraceDone marks the tracing speed metric, and returns 'true' if the test is
-// 'slow'.
-func (stat *VmStat) TraceDone(start time.Time) (time.Duration, bool) {
+// 'slow'. The command is only used for logging.
+func (stat *VmStat) TraceDone(start time.Time, cmd string) (time.Duration, bool) {
 	numexecs := stat.numExecs.Add(1)
 	duration := time.Since(start)
+	stat.totalTime.Add(int64(duration))
+	bucket := 0
+	for bucket < len(execTimeBuckets) && duration > execTimeBuckets[bucket] {
+		bucket++
+	}
+	stat.execTimes[bucket].Add(1)
+	var (
+		slow      = false
+		threshold = time.Duration(stat.slowThreshold.Load())
+	)
+	stat.mu.Lock()
 	stat.tracingSpeedWMA.Add(int(duration))
 	if duration > stat.longestTracingTime {
 		stat.longestTracingTime = duration
 		// Don't count the first 500 runs, let it accumulate.
-		if numexecs > 500 {
-			return duration, true
+		slow = threshold == 0 && numexecs > 500
+	}
+	stat.mu.Unlock()
+	if threshold > 0 {
+		slow = duration > threshold
+	}
+	if slow {
+		stat.numSlow.Add(1)
+		if stat.logSlow.Load() {
+			log.Warn("Slow execution", "time", duration, "cmd", cmd)
 		}
 	}
-	return duration, false
+	return duration, slow
+}
+
+// CountOp records the execution of an opcode.
+func (stat *VmStat) CountOp(op byte) {
+	stat.opCounts[op].Add(1)
 }
 
 func (stat *VmStat) Stats() []any {
-	return []interface{}{
+	stat.mu.Lock()
+	stats := []interface{}{
 		"execSpeed", time.Duration(stat.tracingSpeedWMA.Avg()).Round(100 * time.Microsecond),
 		"longest", stat.longestTracingTime,
 		"count", stat.numExecs.Load(),
 	}
+	stat.mu.Unlock()
+	if n := stat.stackAnomalies.Load(); n > 0 {
+		stats = append(stats, "stackAnomalies", n)
+	}
+	return stats
+}
+
+// WritePrometheus writes the metrics in the Prometheus text exposition format,
+// with the series labelled with the given vm name.
+func (stat *VmStat) WritePrometheus(w io.Writer, name string) error {
+	return writePrometheus(w, []string{name}, []*VmStat{stat})
+}
+
+// WritePrometheus writes the metrics of the vms in the Prometheus text
+// exposition format, with the series labelled with the vm names. Vms without
+// metrics are skipped.
+func WritePrometheus(w io.Writer, vms ...Evm) error {
+	var (
+		names []string
+		stats []*VmStat
+	)
+	for _, vm := range vms {
+		if p, ok := vm.(interface{ VmStats() *VmStat }); ok {
+			names = append(names, vm.Name())
+			stats = append(stats, p.VmStats())
+		}
+	}
+	return writePrometheus(w, names, stats)
+}
+
+// writePrometheus writes the metrics, grouped by metric family as the format
+// requires.
+func writePrometheus(w io.Writer, names []string, stats []*VmStat) error {
+	var err error
+	printf := func(format string, args ...any) {
+		if err == nil {
+			_, err = fmt.Fprintf(w, format, args...)
+		}
+	}
+	family := func(metric, typ, help string, samples func(stat *VmStat, label string)) {
+		printf("# HELP %v %v\n", metric, help)
+		printf("# TYPE %v %v\n", metric, typ)
+		for i, stat := range stats {
+			samples(stat, fmt.Sprintf("vm=%q", names[i]))
+		}
+	}
+	family("goevmlab_executions_total", "counter", "Number of executions.", func(stat *VmStat, label string) {
+		printf("goevmlab_executions_total{%v} %d\n", label, stat.numExecs.Load())
+	})
+	family("goevmlab_slow_executions_total", "counter", "Number of slow executions, exceeding the slow threshold or, without one, slower than all previous ones.", func(stat *VmStat, label string) {
+		printf("goevmlab_slow_executions_total{%v} %d\n", label, stat.numSlow.Load())
+	})
+	family("goevmlab_stack_anomalies_total", "counter", "Number of unexpected stack height changes.", func(stat *VmStat, label string) {
+		printf("goevmlab_stack_anomalies_total{%v} %d\n", label, stat.stackAnomalies.Load())
+	})
+	family("goevmlab_execution_seconds", "histogram", "Execution time.", func(stat *VmStat, label string) {
+		var cumulative uint64
+		for i, bound := range execTimeBuckets {
+			cumulative += stat.execTimes[i].Load()
+			printf("goevmlab_execution_seconds_bucket{%v,le=\"%g\"} %d\n", label, bound.Seconds(), cumulative)
+		}
+		cumulative += stat.execTimes[len(execTimeBuckets)].Load()
+		printf("goevmlab_execution_seconds_bucket{%v,le=\"+Inf\"} %d\n", label, cumulative)
+		printf("goevmlab_execution_seconds_sum{%v} %g\n", label, time.Duration(stat.totalTime.Load()).Seconds())
+		printf("goevmlab_execution_seconds_count{%v} %d\n", label, cumulative)
+	})
+	family("goevmlab_opcodes_total", "counter", "Number of executed opcodes, by opcode.", func(stat *VmStat, label string) {
+		for op := range stat.opCounts {
+			n := stat.opCounts[op].Load()
+			if n == 0 {
+				continue
+			}
+			name := fmt.Sprintf("0x%02x", op)
+			if ops.IsDefined(ops.OpCode(op)) {
+				name = ops.OpCode(op).String()
+			}
+			printf("goevmlab_opcodes_total{%v,op=\"%v\"} %d\n", label, name, n)
+		}
+	})
+	return err
 }
 
 type tracingResult struct {
 	Slow     bool
 	ExecTime time.Duration
 	Cmd      string
+	// Output is the return data reported by the client, as hex. Not all
+	// clients report it.
+	Output string
+	// Error is the execution error reported by the client, e.g.
+	// "gas uint64 overflow". Not all clients report it.
+	Error string
+	// LogsHash is the hash of the logs reported by the client, as hex. Not
+	// all clients report it.
+	LogsHash string
 }
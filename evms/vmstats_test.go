@@ -0,0 +1,145 @@
+// Copyright 2024 Martin Holst Swende
+// This file is part of the goevmlab library.
+//
+// The library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the goevmlab library. If not, see <http://www.gnu.org/licenses/>.
+
+package evms
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rgeraldes24/goevmlab/ops"
+)
+
+func TestWritePrometheus(t *testing.T) {
+	stat := new(VmStat)
+	stat.TraceDone(time.Now().Add(-20*time.Millisecond), "")
+	stat.CountOp(byte(ops.ADD))
+	stat.CountOp(byte(ops.ADD))
+	stat.CountOp(0xfe)
+	stat.CountOp(0x0c)
+
+	var buf bytes.Buffer
+	if err := stat.WritePrometheus(&buf, "geth"); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		"goevmlab_executions_total{vm=\"geth\"} 1\n",
+		"goevmlab_slow_executions_total{vm=\"geth\"} 0\n",
+		"goevmlab_execution_seconds_bucket{vm=\"geth\",le=\"0.01\"} 0\n",
+		"goevmlab_execution_seconds_bucket{vm=\"geth\",le=\"0.05\"} 1\n",
+		"goevmlab_execution_seconds_bucket{vm=\"geth\",le=\"+Inf\"} 1\n",
+		"goevmlab_execution_seconds_count{vm=\"geth\"} 1\n",
+		"goevmlab_opcodes_total{vm=\"geth\",op=\"ADD\"} 2\n",
+		"goevmlab_opcodes_total{vm=\"geth\",op=\"INVALID\"} 1\n",
+		"goevmlab_opcodes_total{vm=\"geth\",op=\"0x0c\"} 1\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in output:\n%v", want, out)
+		}
+	}
+	if strings.Contains(out, "op=\"MUL\"") {
+		t.Errorf("unexpected metric for unused opcode")
+	}
+}
+
+func TestWritePrometheusVms(t *testing.T) {
+	var (
+		geth = NewGethEVM("", "geth")
+		besu = NewBesuVM("", "besu")
+		buf  bytes.Buffer
+	)
+	geth.VmStats().TraceDone(time.Now(), "")
+	if err := WritePrometheus(&buf, geth, besu); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		"goevmlab_executions_total{vm=\"geth\"} 1\n",
+		"goevmlab_executions_total{vm=\"besu\"} 0\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in output:\n%v", want, out)
+		}
+	}
+	// Each family is written once
+	if have := strings.Count(out, "# TYPE goevmlab_executions_total counter\n"); have != 1 {
+		t.Errorf("got %d TYPE lines expected 1", have)
+	}
+}
+
+func TestSlowThreshold(t *testing.T) {
+	stat := new(VmStat)
+	stat.SetSlowThreshold(time.Millisecond)
+	stat.SetLogSlow(true)
+	if _, slow := stat.TraceDone(time.Now().Add(-10*time.Millisecond), "slow"); !slow {
+		t.Errorf("expected slow run")
+	}
+	if _, slow := stat.TraceDone(time.Now(), "fast"); slow {
+		t.Errorf("expected fast run")
+	}
+	if have := stat.SlowCount(); have != 1 {
+		t.Errorf("got %d slow runs expected 1", have)
+	}
+	// The threshold can be set through the vm
+	vm := Configure(NewGethEVM("", "geth"), WithSlowThreshold(time.Millisecond, false))
+	if _, slow := vm.(*GethEVM).VmStats().TraceDone(time.Now().Add(-10*time.Millisecond), "slow"); !slow {
+		t.Errorf("expected slow run")
+	}
+}
+
+func TestVmStatConcurrency(t *testing.T) {
+	const (
+		workers = 32
+		runs    = 200
+	)
+	var (
+		stat = new(VmStat)
+		wg   sync.WaitGroup
+	)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < runs; j++ {
+				stat.TraceDone(time.Now().Add(-time.Duration(i*runs+j)*time.Microsecond), "")
+				stat.CountOp(byte(ops.ADD))
+				stat.Stats()
+			}
+		}(i)
+	}
+	wg.Wait()
+	if have, want := stat.numExecs.Load(), uint64(workers*runs); have != want {
+		t.Errorf("executions: got %d expected %d", have, want)
+	}
+	var bucketed uint64
+	for i := range stat.execTimes {
+		bucketed += stat.execTimes[i].Load()
+	}
+	if have, want := bucketed, uint64(workers*runs); have != want {
+		t.Errorf("histogram: got %d expected %d", have, want)
+	}
+	if have, want := stat.opCounts[ops.ADD].Load(), uint64(workers*runs); have != want {
+		t.Errorf("ADD count: got %d expected %d", have, want)
+	}
+	// The longest run is at least as long as the longest start offset
+	if have, min := stat.longestTracingTime, time.Duration(workers*runs-1)*time.Microsecond; have < min {
+		t.Errorf("longest: got %v expected at least %v", have, min)
+	}
+}
@@ -289,7 +289,6 @@ type dumbTracer struct {
 	createCount uint64
 	copyCount   uint64
 	memSize     uint64
-	opCount     uint64
 
 	startGas  uint64
 	phase1Gas uint64
@@ -297,7 +296,7 @@ type dumbTracer struct {
 }
 
 func (d *dumbTracer) CaptureState(pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, rData []byte, depth int, err error) {
-	d.opCount++
+	d.BasicTracer.CaptureState(pc, op, gas, cost, scope, rData, depth, err)
 	if op == vm.EXTCODECOPY {
 		d.copyCount++
 		if d.phase1Gas == 0 {
@@ -326,6 +325,6 @@ Total gas spent: %d
 `, d.startGas-d.phase1Gas, d.memSize,
 		d.phase1Gas-d.phase2Gas, d.copyCount,
 		d.phase2Gas, d.createCount,
-		d.opCount,
+		d.StepCount(),
 		d.startGas)
 }
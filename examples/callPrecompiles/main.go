@@ -134,6 +134,7 @@ type dumbTracer struct {
 }
 
 func (d *dumbTracer) CaptureState(pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, rData []byte, depth int, err error) {
+	d.BasicTracer.CaptureState(pc, op, gas, cost, scope, rData, depth, err)
 	if op == vm.STATICCALL {
 		d.counter++
 	}
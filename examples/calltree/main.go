@@ -52,6 +52,7 @@ func (d *dumbTracer) CaptureEnd(output []byte, gasUsed uint64, err error) {
 }
 
 func (d *dumbTracer) CaptureState(pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, rData []byte, depth int, err error) {
+	d.BasicTracer.CaptureState(pc, op, gas, cost, scope, rData, depth, err)
 	if op == vm.CALL {
 		if depth == 1 {
 			fmt.Println("")
@@ -85,7 +86,7 @@ func runit() error {
 
 	// The self-call can be done a bit more clever, gas-wise
 
-	b.Op(ops.PC)      // get zero on stack (out size)
+	b.Zero()          // get zero on stack (out size)
 	b.Op(ops.DUP1)    // out offset
 	b.Op(ops.DUP1)    // insize
 	b.Op(ops.DUP1)    // inoffset
@@ -0,0 +1,133 @@
+// Copyright Martin Holst Swende
+// This file is part of the goevmlab library.
+//
+// The library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the goevmlab library. If not, see <http://www.gnu.org/licenses/>.
+
+package fuzzing
+
+import (
+	"math/big"
+
+	"github.com/rgeraldes24/goevmlab/ops"
+	"github.com/rgeraldes24/goevmlab/program"
+	"github.com/theQRL/go-zond/crypto"
+)
+
+// LoopCounterSlot is the storage slot where the code produced by BoundLoops
+// counts the backward jumps.
+var LoopCounterSlot = crypto.Keccak256Hash([]byte("goevmlab.BoundLoops"))
+
+// boundInstr is an instruction of the code to be bounded.
+type boundInstr struct {
+	pc     int
+	op     ops.OpCode
+	arg    []byte
+	target int  // for static jumps, the destination
+	static bool // whether this is a push of a static jump destination
+	gate   bool // whether a gate goes before this instruction
+}
+
+// BoundLoops rewrites the code so that it cannot loop forever: before each
+// backward jump, a gate is inserted which increments a counter in storage
+// (at LoopCounterSlot), and stops the execution once the counter exceeds
+// maxIters. Jumps with a computed destination are gated as well.
+//
+// Since the gates shift the code, the destinations of static jumps, i.e. a PUSH
+// of a JUMPDEST followed by JUMP or JUMPI, are relocated. Computed destinations
+// are not, and will typically end up invalid.
+func BoundLoops(code []byte, maxIters int) []byte {
+	var (
+		instrs    []*boundInstr
+		jumpdests = program.JumpdestBitmap(code)
+	)
+	for pc := 0; pc < len(code); {
+		op := ops.OpCode(code[pc])
+		end := pc + 1 + op.PushSize()
+		if end > len(code) {
+			end = len(code)
+		}
+		instrs = append(instrs, &boundInstr{pc: pc, op: op, arg: code[pc+1 : end]})
+		pc = end
+	}
+	for i, in := range instrs {
+		if in.op != ops.JUMP && in.op != ops.JUMPI {
+			continue
+		}
+		if i > 0 && instrs[i-1].op.IsPush() {
+			prev := instrs[i-1]
+			dest := new(big.Int).SetBytes(prev.arg)
+			if dest.IsUint64() && program.IsJumpdest(jumpdests, dest.Uint64()) {
+				prev.static = true
+				prev.target = int(dest.Uint64())
+				// Gate before the push, to not disturb the stack
+				prev.gate = prev.target <= prev.pc
+				continue
+			}
+		}
+		in.gate = true
+	}
+	// Lay out the new code, to find the new locations
+	var (
+		gateSize = len(loopGate(0, maxIters))
+		newPc    = make(map[int]int)
+		size     int
+	)
+	for _, in := range instrs {
+		if in.gate {
+			size += gateSize
+		}
+		newPc[in.pc] = size
+		if in.static {
+			size += 4 // PUSH3
+		} else {
+			size += 1 + len(in.arg)
+		}
+	}
+	// And emit it
+	out := make([]byte, 0, size)
+	for _, in := range instrs {
+		if in.gate {
+			out = append(out, loopGate(len(out), maxIters)...)
+		}
+		if in.static {
+			out = append(out, program.NewProgram().PushN(3, newPc[in.target]).Bytecode()...)
+			continue
+		}
+		out = append(out, byte(in.op))
+		out = append(out, in.arg...)
+	}
+	return out
+}
+
+// loopGate returns the code which increments the loop counter, and stops the
+// execution if it exceeds maxIters. The gate is placed at offset.
+func loopGate(offset int, maxIters int) []byte {
+	p := program.NewProgram()
+	p.PushHash(LoopCounterSlot)
+	p.Op(ops.SLOAD)
+	p.Push(1)
+	p.Op(ops.ADD)
+	p.Op(ops.DUP1)
+	p.PushHash(LoopCounterSlot)
+	p.Op(ops.SSTORE)
+	p.PushN(4, maxIters)
+	p.Op(ops.LT) // maxIters < counter
+	p.Op(ops.ISZERO)
+	// PUSH3, JUMPI and STOP remain before the JUMPDEST
+	p.PushN(3, offset+p.CurrentOffset()+6)
+	p.Op(ops.JUMPI)
+	p.Op(ops.STOP)
+	p.Op(ops.JUMPDEST)
+	return p.Bytecode()
+}
@@ -0,0 +1,95 @@
+// Copyright Martin Holst Swende
+// This file is part of the goevmlab library.
+//
+// The library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the goevmlab library. If not, see <http://www.gnu.org/licenses/>.
+
+package fuzzing
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/rgeraldes24/goevmlab/ops"
+	"github.com/rgeraldes24/goevmlab/program"
+	"github.com/theQRL/go-zond/common"
+	"github.com/theQRL/go-zond/core/vm/runtime"
+)
+
+// runBounded executes the code in-process, and returns the value of the loop
+// counter and of slot 0.
+func runBounded(t *testing.T, code []byte) (counter, slot0 common.Hash) {
+	t.Helper()
+	_, statedb, err := runtime.Execute(code, nil, &runtime.Config{GasLimit: 10_000_000})
+	if err != nil {
+		t.Fatalf("execution failed: %v", err)
+	}
+	addr := common.BytesToAddress([]byte("contract"))
+	return statedb.GetState(addr, LoopCounterSlot), statedb.GetState(addr, common.Hash{})
+}
+
+func TestBoundLoopsInfinite(t *testing.T) {
+	p := program.NewProgram()
+	loop := p.Jumpdest()
+	p.Push(1)
+	p.Op(ops.POP)
+	p.Jump(loop)
+
+	// Without bounds, the loop runs out of gas
+	if _, _, err := runtime.Execute(p.Bytecode(), nil, &runtime.Config{GasLimit: 10_000_000}); err == nil {
+		t.Fatalf("expected the unbounded loop to fail")
+	}
+	const maxIters = 10
+	counter, _ := runBounded(t, BoundLoops(p.Bytecode(), maxIters))
+	if have, want := counter.Big(), big.NewInt(maxIters+1); have.Cmp(want) != 0 {
+		t.Errorf("got %v iterations expected %v", have, want)
+	}
+}
+
+func TestBoundLoopsSemantics(t *testing.T) {
+	// A loop which runs three times, incrementing slot 0, preceded by a
+	// forward jump over an INVALID.
+	p := program.NewProgram()
+	p.Push(3)
+	p.Jump("start")
+	p.Op(ops.INVALID)
+	p.NamedJumpdest("start")
+	loop := p.Jumpdest()
+	p.Push(0)
+	p.Op(ops.SLOAD)
+	p.Push(1)
+	p.Op(ops.ADD)
+	p.Push(0)
+	p.Op(ops.SSTORE)
+	p.Push(1)
+	p.Op(ops.SWAP1)
+	p.Op(ops.SUB)
+	p.Op(ops.DUP1)
+	p.Push(loop)
+	p.Op(ops.JUMPI)
+	p.Op(ops.POP)
+
+	counter, slot0 := runBounded(t, BoundLoops(p.Bytecode(), 10))
+	if have, want := slot0.Big(), big.NewInt(3); have.Cmp(want) != 0 {
+		t.Errorf("slot 0: got %v expected %v", have, want)
+	}
+	// Two backward jumps taken, and the final, untaken, one gated
+	if have, want := counter.Big(), big.NewInt(3); have.Cmp(want) != 0 {
+		t.Errorf("counter: got %v expected %v", have, want)
+	}
+	// With a lower bound, the loop is cut short
+	_, slot0 = runBounded(t, BoundLoops(p.Bytecode(), 1))
+	if have, want := slot0.Big(), big.NewInt(2); have.Cmp(want) != 0 {
+		t.Errorf("bounded slot 0: got %v expected %v", have, want)
+	}
+}
@@ -0,0 +1,180 @@
+// Copyright 2024 Martin Holst Swende
+// This file is part of the goevmlab library.
+//
+// The library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the goevmlab library. If not, see <http://www.gnu.org/licenses/>.
+
+package fuzzing
+
+import (
+	"math/big"
+	"math/rand"
+
+	"github.com/rgeraldes24/goevmlab/ops"
+	"github.com/rgeraldes24/goevmlab/program"
+	"github.com/theQRL/go-zond/common"
+)
+
+// GenConfig configures the program generators.
+type GenConfig struct {
+	// MaxCallDepth is the maximum (static) call depth of generated programs,
+	// see program.MaxStaticCallDepth.
+	MaxCallDepth int
+	// MaxPieces is the maximum number of pieces of each program, 3 if zero.
+	MaxPieces int
+	// SstoreWeight, CallWeight and NestWeight are the relative likelihoods of
+	// a piece of a program being a storage write, a call, or a nested program
+	// which is created and called. If all are zero, they are 1, 1 and 2.
+	SstoreWeight, CallWeight, NestWeight int
+	// CallTypes are the ops used for calls. If empty, CALL, CALLCODE and
+	// DELEGATECALL are used.
+	CallTypes []ops.OpCode
+	// Targets are accounts which are called, in addition to the precompiles.
+	// The call tree factories deploy call trees at them.
+	Targets []common.Address
+	// ForwardGas makes the calls to the targets forward all gas, and use
+	// small values and memory areas, instead of random ones.
+	ForwardGas bool
+}
+
+// DefaultGenConfig is the configuration used by the factories.
+var DefaultGenConfig = GenConfig{
+	MaxCallDepth: 3,
+}
+
+// CallHeavyConfig returns a configuration which biases the generators towards
+// calls and creates, between a set of helper contracts, to stress the paths
+// of the interaction between contracts.
+func CallHeavyConfig() GenConfig {
+	var helpers []common.Address
+	for i := uint64(0); i < 4; i++ {
+		helpers = append(helpers, DeterministicAddr(i))
+	}
+	return GenConfig{
+		MaxCallDepth: 3,
+		MaxPieces:    6,
+		SstoreWeight: 1,
+		CallWeight:   4,
+		NestWeight:   3,
+		CallTypes:    []ops.OpCode{ops.CALL, ops.STATICCALL, ops.DELEGATECALL, ops.CALLCODE},
+		Targets:      helpers,
+		ForwardGas:   true,
+	}
+}
+
+func fillCallTree(gst *GstMaker, fork string) {
+	fillCallTreeWith(gst, DefaultGenConfig)
+}
+
+func fillCallHeavy(gst *GstMaker, fork string) {
+	fillCallTreeWith(gst, CallHeavyConfig())
+}
+
+func fillCallTreeWith(gst *GstMaker, cfg GenConfig) {
+	// The targets are shallow call trees, calling each other
+	helperCfg := cfg
+	helperCfg.MaxCallDepth = min(1, cfg.MaxCallDepth)
+	for _, addr := range cfg.Targets {
+		gst.AddAccount(addr, GenesisAccount{
+			Code:    RandCallTree(helperCfg),
+			Balance: new(big.Int),
+			Storage: make(map[common.Hash]common.Hash),
+		})
+	}
+	dest := common.HexToAddress("0x0000ca11")
+	gst.AddAccount(dest, GenesisAccount{
+		Code:    RandCallTree(cfg),
+		Balance: new(big.Int),
+		Storage: make(map[common.Hash]common.Hash),
+	})
+	// The transaction
+	gst.SetTx(&StTransaction{
+		// 8M gaslimit
+		GasLimit:   []uint64{8000000},
+		Nonce:      0,
+		Value:      []string{randHex(4)},
+		Data:       []string{""},
+		GasPrice:   big.NewInt(0x20),
+		To:         dest.Hex(),
+		Sender:     sender,
+		PrivateKey: pKey,
+	})
+}
+
+// RandCallTree generates a program which does storage writes, calls to
+// precompiles, and creates (and calls) nested programs of the same kind. The
+// nesting is bounded by cfg.MaxCallDepth, and deeper levels are less likely to
+// nest further.
+func RandCallTree(cfg GenConfig) []byte {
+	return randCallTree(cfg, 0)
+}
+
+func randCallTree(cfg GenConfig, depth int) []byte {
+	var (
+		p         = program.NewProgram()
+		canCall   = depth < cfg.MaxCallDepth
+		maxPieces = cfg.MaxPieces
+		sstoreW   = cfg.SstoreWeight
+		callW     = cfg.CallWeight
+		nestW     = cfg.NestWeight
+	)
+	if maxPieces == 0 {
+		maxPieces = 3
+	}
+	if sstoreW+callW+nestW == 0 {
+		sstoreW, callW, nestW = 1, 1, 2
+	}
+	pieces := 1 + rand.Intn(maxPieces)
+	for i := 0; i < pieces; i++ {
+		switch x := rand.Intn(sstoreW + callW + nestW); {
+		case x < sstoreW || !canCall:
+			p.Sstore(rand.Intn(5), rand.Intn(256))
+		case x < sstoreW+callW:
+			p.AddAll(randTreeCall(cfg))
+			p.Op(ops.POP)
+		default:
+			// Nest, but less likely the deeper we are
+			if rand.Intn(depth+1) != 0 {
+				p.Sstore(rand.Intn(5), rand.Intn(256))
+				continue
+			}
+			p.CreateAndCall(randCallTree(cfg, depth+1), rand.Intn(2) == 0, randTreeCallType(cfg))
+		}
+	}
+	return p.Bytecode()
+}
+
+func randTreeCallType(cfg GenConfig) ops.OpCode {
+	if len(cfg.CallTypes) == 0 {
+		return randCallType()
+	}
+	return cfg.CallTypes[rand.Intn(len(cfg.CallTypes))]
+}
+
+// randTreeCall returns a call to a precompile or one of the targets.
+func randTreeCall(cfg GenConfig) []byte {
+	op := randTreeCallType(cfg)
+	if len(cfg.Targets) == 0 || rand.Intn(4) == 0 {
+		addrGen := func() interface{} { return 1 + rand.Intn(9) }
+		return randCallOp(op, GasRandomizer(), addrGen, ValueRandomizer(), nil, nil)
+	}
+	addrGen := addressRandomizer(cfg.Targets)
+	if !cfg.ForwardGas {
+		return randCallOp(op, GasRandomizer(), addrGen, ValueRandomizer(), MemRandomizer(), MemRandomizer())
+	}
+	var (
+		small = func() interface{} { return rand.Intn(3) }
+		area  = func() (offset, size interface{}) { return 32 * rand.Intn(4), 32 * rand.Intn(3) }
+	)
+	return randCallOp(op, nil, addrGen, small, area, area)
+}
@@ -0,0 +1,105 @@
+// Copyright 2024 Martin Holst Swende
+// This file is part of the goevmlab library.
+//
+// The library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the goevmlab library. If not, see <http://www.gnu.org/licenses/>.
+
+package fuzzing
+
+import (
+	"testing"
+
+	"github.com/rgeraldes24/goevmlab/ops"
+	"github.com/rgeraldes24/goevmlab/program"
+	"github.com/theQRL/go-zond/core/rawdb"
+	"github.com/theQRL/go-zond/core/vm"
+)
+
+func TestCallTreeMaxDepth(t *testing.T) {
+	cfg := GenConfig{MaxCallDepth: 2}
+	var deepest int
+	for i := 0; i < 200; i++ {
+		code := RandCallTree(cfg)
+		depth := program.MaxStaticCallDepth(code)
+		if depth > cfg.MaxCallDepth {
+			t.Fatalf("depth %d exceeds max %d: %x", depth, cfg.MaxCallDepth, code)
+		}
+		if depth > deepest {
+			deepest = depth
+		}
+	}
+	if deepest != cfg.MaxCallDepth {
+		t.Errorf("max depth never reached, deepest %d", deepest)
+	}
+	// No calls at all
+	if depth := program.MaxStaticCallDepth(RandCallTree(GenConfig{})); depth != 0 {
+		t.Errorf("expected no calls, got depth %d", depth)
+	}
+}
+
+func TestCallHeavyConfig(t *testing.T) {
+	isCall := func(op ops.OpCode) bool {
+		switch op {
+		case ops.CALL, ops.CALLCODE, ops.DELEGATECALL, ops.STATICCALL, ops.CREATE, ops.CREATE2:
+			return true
+		}
+		return false
+	}
+	var (
+		cfg     = CallHeavyConfig()
+		runs    = 200
+		calling int
+		seen    = make(map[ops.OpCode]bool)
+	)
+	for i := 0; i < runs; i++ {
+		code := RandCallTree(cfg)
+		p := program.NewProgram()
+		p.AddAll(code)
+		if err := p.Validate(); err != nil {
+			t.Fatalf("invalid program: %v: %x", err, code)
+		}
+		found := false
+		for it := ops.NewInstructionIterator(code); it.Next(); {
+			if isCall(it.Op()) {
+				found = true
+				seen[it.Op()] = true
+			}
+		}
+		if found {
+			calling++
+		}
+	}
+	// Only a piece which is a single sstore lacks calls
+	if calling < runs*8/10 {
+		t.Errorf("got %d of %d programs with calls, expected at least 80%%", calling, runs)
+	}
+	for _, op := range []ops.OpCode{ops.CALL, ops.STATICCALL, ops.DELEGATECALL, ops.CREATE, ops.CREATE2} {
+		if !seen[op] {
+			t.Errorf("%v never generated", op)
+		}
+	}
+	// The factory deploys the helpers
+	gst := Factory("callheavy", "Shanghai")()
+	for _, addr := range cfg.Targets {
+		if acc, ok := (*gst.pre)[addr]; !ok || len(acc.Code) == 0 {
+			t.Errorf("helper %v not deployed", addr)
+		}
+	}
+	test, err := gst.ToStateTest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, _, _, err := test.RunNoVerify(test.Subtests()[0], vm.Config{}, false, rawdb.HashScheme); err != nil {
+		t.Fatal(err)
+	}
+}
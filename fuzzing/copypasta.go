@@ -26,6 +26,7 @@ import (
 	"github.com/theQRL/go-zond/common"
 	"github.com/theQRL/go-zond/common/hexutil"
 	"github.com/theQRL/go-zond/common/math"
+	"github.com/theQRL/go-zond/core/types"
 	"github.com/theQRL/go-zond/rlp"
 	"golang.org/x/crypto/sha3"
 )
@@ -151,14 +152,15 @@ type stEnvMarshaling struct {
 //go:generate gencodec -type StTransaction -field-override stTransactionMarshaling -out gen_sttransaction.go
 
 type StTransaction struct {
-	GasPrice   *big.Int       `json:"gasPrice"`
-	Nonce      uint64         `json:"nonce"`
-	To         string         `json:"to"`
-	Data       []string       `json:"data"`
-	GasLimit   []uint64       `json:"gasLimit"`
-	Value      []string       `json:"value"`
-	Sender     common.Address `json:"sender"`
-	PrivateKey []byte         `json:"secretKey"`
+	GasPrice    *big.Int            `json:"gasPrice"`
+	Nonce       uint64              `json:"nonce"`
+	To          string              `json:"to"`
+	Data        []string            `json:"data"`
+	AccessLists []*types.AccessList `json:"accessLists,omitempty"`
+	GasLimit    []uint64            `json:"gasLimit"`
+	Value       []string            `json:"value"`
+	Sender      common.Address      `json:"sender"`
+	PrivateKey  []byte              `json:"secretKey"`
 }
 
 type stTransactionMarshaling struct {
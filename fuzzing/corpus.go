@@ -0,0 +1,245 @@
+// Copyright 2024 Martin Holst Swende
+// This file is part of the goevmlab library.
+//
+// The library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the goevmlab library. If not, see <http://www.gnu.org/licenses/>.
+
+package fuzzing
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/rgeraldes24/goevmlab/ops"
+	"github.com/theQRL/go-zond/crypto"
+)
+
+// HashFunc hashes code, for deduplication of the corpus entries.
+type HashFunc func(code []byte) []byte
+
+// KeccakHash is the default HashFunc.
+func KeccakHash(code []byte) []byte {
+	return crypto.Keccak256(code)
+}
+
+// XXHash is a fast, non-cryptographic HashFunc, for in-memory deduplication
+// where collision resistance is not critical.
+func XXHash(code []byte) []byte {
+	return binary.BigEndian.AppendUint64(nil, xxhash.Sum64(code))
+}
+
+// CorpusOption configures a Corpus.
+type CorpusOption func(*Corpus)
+
+// WithHash sets the hash function used for deduplication.
+func WithHash(fn HashFunc) CorpusOption {
+	return func(c *Corpus) {
+		c.hash = fn
+	}
+}
+
+// Corpus is a set of programs, identified by id. Programs with identical
+// code are only stored once.
+type Corpus struct {
+	entries map[string][]byte
+	hashes  map[string]string // code hash -> id
+	hash    HashFunc
+}
+
+// NewCorpus creates an empty corpus.
+func NewCorpus(opts ...CorpusOption) *Corpus {
+	c := &Corpus{
+		entries: make(map[string][]byte),
+		hashes:  make(map[string]string),
+		hash:    KeccakHash,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Add adds the code to the corpus, replacing any previous entry with the
+// same id. If the corpus already holds the same code, nothing is added, and
+// false is returned.
+func (c *Corpus) Add(id string, code []byte) bool {
+	h := string(c.hash(code))
+	if _, exists := c.hashes[h]; exists {
+		return false
+	}
+	if old, exists := c.entries[id]; exists {
+		delete(c.hashes, string(c.hash(old)))
+	}
+	c.entries[id] = code
+	c.hashes[h] = id
+	return true
+}
+
+// Contains returns whether the corpus holds the given code.
+func (c *Corpus) Contains(code []byte) bool {
+	_, exists := c.hashes[string(c.hash(code))]
+	return exists
+}
+
+// Get returns the code of the entry with the given id, or nil.
+func (c *Corpus) Get(id string) []byte {
+	return c.entries[id]
+}
+
+// Len returns the number of entries in the corpus.
+func (c *Corpus) Len() int {
+	return len(c.entries)
+}
+
+// IDs returns the ids of all entries, sorted.
+func (c *Corpus) IDs() []string {
+	ids := make([]string, 0, len(c.entries))
+	for id := range c.entries {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// DiffCorpus compares two corpora by the hashes of the entries. It returns the
+// (sorted) ids of the entries of new whose code is not in old, and the ids of
+// the entries of old whose code is not in new. Entries which were merely
+// renamed are in neither.
+func DiffCorpus(old, new *Corpus) (added, removed []string) {
+	for _, id := range new.IDs() {
+		if !old.Contains(new.Get(id)) {
+			added = append(added, id)
+		}
+	}
+	for _, id := range old.IDs() {
+		if !new.Contains(old.Get(id)) {
+			removed = append(removed, id)
+		}
+	}
+	return added, removed
+}
+
+// Search returns the (sorted) ids of the entries whose code contains the
+// given sequence of instructions. The code is disassembled, so that push
+// immediates are not mistaken for instructions.
+func Search(corpus *Corpus, seq []ops.OpCode) []string {
+	var matches []string
+	for _, id := range corpus.IDs() {
+		if containsSequence(corpus.Get(id), seq) {
+			matches = append(matches, id)
+		}
+	}
+	return matches
+}
+
+func containsSequence(code []byte, seq []ops.OpCode) bool {
+	if len(seq) == 0 {
+		return true
+	}
+	var (
+		it      = ops.NewInstructionIterator(code)
+		program []ops.OpCode
+	)
+	for it.Next() {
+		program = append(program, it.Op())
+	}
+outer:
+	for i := 0; i+len(seq) <= len(program); i++ {
+		for j, op := range seq {
+			if program[i+j] != op {
+				continue outer
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// FilterByFork splits the (sorted) ids of the entries of the corpus into those
+// whose code only uses opcodes which are valid in the fork, and those which
+// use at least one opcode which is not. It panics if the fork is unknown.
+func FilterByFork(corpus *Corpus, fork string) (compatible, incompatible []string) {
+	f := ops.LookupFork(fork)
+	if f == nil {
+		panic(fmt.Sprintf("unknown fork %v", fork))
+	}
+	for _, id := range corpus.IDs() {
+		if validInFork(corpus.Get(id), f) {
+			compatible = append(compatible, id)
+		} else {
+			incompatible = append(incompatible, id)
+		}
+	}
+	return compatible, incompatible
+}
+
+func validInFork(code []byte, fork *ops.Fork) bool {
+	for it := ops.NewInstructionIterator(code); it.Next(); {
+		if !fork.IsValid(it.Op()) {
+			return false
+		}
+	}
+	return true
+}
+
+// RunCorpus runs each entry of the corpus through run, which typically
+// executes the code on a set of vms and compares the results. The ids of the
+// completed entries are appended to the progress file, and entries already
+// listed there are skipped, so an interrupted run can be resumed by calling
+// RunCorpus again with the same progress file. If run fails, RunCorpus stops,
+// and the failing entry is not marked as done. After each completed entry,
+// progress (if non-nil) is called with the number of done entries.
+// See evms.RunCorpus, which runs the entries on a set of vms.
+func RunCorpus(corpus *Corpus, run func(id string, code []byte) error, progressFile string, progress func(done, total int)) error {
+	done := make(map[string]bool)
+	if data, err := os.ReadFile(progressFile); err == nil {
+		for _, id := range strings.Split(string(data), "\n") {
+			if id != "" {
+				done[id] = true
+			}
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	f, err := os.OpenFile(progressFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var (
+		ids   = corpus.IDs()
+		count = 0
+	)
+	for _, id := range ids {
+		if done[id] {
+			count++
+			continue
+		}
+		if err := run(id, corpus.Get(id)); err != nil {
+			return fmt.Errorf("entry %v: %w", id, err)
+		}
+		if _, err := fmt.Fprintln(f, id); err != nil {
+			return err
+		}
+		count++
+		if progress != nil {
+			progress(count, len(ids))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,188 @@
+// Copyright 2024 Martin Holst Swende
+// This file is part of the goevmlab library.
+//
+// The library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the goevmlab library. If not, see <http://www.gnu.org/licenses/>.
+
+package fuzzing
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rgeraldes24/goevmlab/ops"
+	"github.com/rgeraldes24/goevmlab/program"
+	"github.com/theQRL/go-zond/common"
+)
+
+func TestSearch(t *testing.T) {
+	corpus := NewCorpus()
+	// A proper DUP1, CALL
+	p := program.NewProgram()
+	p.Push(0)
+	p.Op(ops.DUP1)
+	p.Op(ops.CALL)
+	corpus.Add("match", p.Bytecode())
+	// DUP1, CALL hidden in a push immediate
+	p = program.NewProgram()
+	p.Push([]byte{byte(ops.DUP1), byte(ops.CALL)})
+	p.Op(ops.POP)
+	corpus.Add("immediate", p.Bytecode())
+	// DUP1 and CALL, but not adjacent
+	p = program.NewProgram()
+	p.Op(ops.DUP1)
+	p.Op(ops.POP)
+	p.Op(ops.CALL)
+	corpus.Add("apart", p.Bytecode())
+	// The sequence at the very end
+	p = program.NewProgram()
+	p.Op(ops.STOP)
+	p.Op(ops.DUP1)
+	p.Op(ops.CALL)
+	corpus.Add("end", p.Bytecode())
+
+	have := Search(corpus, []ops.OpCode{ops.DUP1, ops.CALL})
+	if want := []string{"end", "match"}; fmt.Sprint(have) != fmt.Sprint(want) {
+		t.Errorf("got %v expected %v", have, want)
+	}
+	if have := Search(corpus, []ops.OpCode{ops.DELEGATECALL}); len(have) != 0 {
+		t.Errorf("got %v expected no matches", have)
+	}
+}
+
+func TestCorpusHash(t *testing.T) {
+	var (
+		a = []byte{byte(ops.PUSH1), 0x01, byte(ops.STOP)}
+		b = []byte{byte(ops.PUSH1), 0x02, byte(ops.STOP)}
+	)
+	for _, hash := range []HashFunc{KeccakHash, XXHash} {
+		if !bytes.Equal(hash(a), hash(common.CopyBytes(a))) {
+			t.Errorf("identical code: got different hashes")
+		}
+		if bytes.Equal(hash(a), hash(b)) {
+			t.Errorf("distinct code: got identical hashes")
+		}
+		corpus := NewCorpus(WithHash(hash))
+		if !corpus.Add("a", a) {
+			t.Errorf("expected a to be added")
+		}
+		if corpus.Add("dup", common.CopyBytes(a)) {
+			t.Errorf("expected duplicate to be rejected")
+		}
+		if !corpus.Add("b", b) {
+			t.Errorf("expected b to be added")
+		}
+		if have, want := corpus.Len(), 2; have != want {
+			t.Errorf("got %d entries expected %d", have, want)
+		}
+		// Replacing an entry releases the old code
+		corpus.Add("b", []byte{byte(ops.STOP)})
+		if corpus.Contains(b) {
+			t.Errorf("expected replaced code to be gone")
+		}
+	}
+}
+
+func TestDiffCorpus(t *testing.T) {
+	code := func(n byte) []byte {
+		return []byte{byte(ops.PUSH1), n, byte(ops.STOP)}
+	}
+	var (
+		old = NewCorpus()
+		new = NewCorpus()
+	)
+	old.Add("a", code(1))
+	old.Add("b", code(2))
+	old.Add("c", code(3))
+	new.Add("b", code(2))
+	new.Add("renamed", code(3))
+	new.Add("d", code(4))
+	new.Add("e", code(5))
+
+	added, removed := DiffCorpus(old, new)
+	if have, want := strings.Join(added, ","), "d,e"; have != want {
+		t.Errorf("added: got %v expected %v", have, want)
+	}
+	if have, want := strings.Join(removed, ","), "a"; have != want {
+		t.Errorf("removed: got %v expected %v", have, want)
+	}
+	if added, removed := DiffCorpus(old, old); len(added) != 0 || len(removed) != 0 {
+		t.Errorf("identical corpora: got %v, %v", added, removed)
+	}
+}
+
+func TestFilterByFork(t *testing.T) {
+	corpus := NewCorpus()
+	corpus.Add("push0", []byte{byte(ops.PUSH0), byte(ops.PUSH0), byte(ops.SSTORE)})
+	corpus.Add("push1", []byte{byte(ops.PUSH1), 0x00, byte(ops.PUSH1), 0x00, byte(ops.SSTORE)})
+	// PUSH0 (0x5f) as an immediate is not an instruction
+	corpus.Add("immediate", []byte{byte(ops.PUSH1), byte(ops.PUSH0), byte(ops.POP)})
+
+	compatible, incompatible := FilterByFork(corpus, "London")
+	if have, want := strings.Join(compatible, ","), "immediate,push1"; have != want {
+		t.Errorf("compatible: got %v expected %v", have, want)
+	}
+	if have, want := strings.Join(incompatible, ","), "push0"; have != want {
+		t.Errorf("incompatible: got %v expected %v", have, want)
+	}
+	if _, incompatible := FilterByFork(corpus, "Shanghai"); len(incompatible) != 0 {
+		t.Errorf("Shanghai: got %v expected none incompatible", incompatible)
+	}
+}
+
+func TestRunCorpus(t *testing.T) {
+	corpus := NewCorpus()
+	for i := 0; i < 5; i++ {
+		corpus.Add(fmt.Sprintf("entry-%d", i), []byte{byte(ops.PUSH1), byte(i)})
+	}
+	progressFile := filepath.Join(t.TempDir(), "progress.txt")
+	interrupt := errors.New("interrupted")
+
+	// The first run is interrupted at the fourth entry
+	var ran []string
+	err := RunCorpus(corpus, func(id string, code []byte) error {
+		if len(ran) == 3 {
+			return interrupt
+		}
+		ran = append(ran, id)
+		return nil
+	}, progressFile, nil)
+	if !errors.Is(err, interrupt) {
+		t.Fatalf("got %v expected %v", err, interrupt)
+	}
+	// The second run resumes at the fourth entry
+	ran = nil
+	var reported []int
+	err = RunCorpus(corpus, func(id string, code []byte) error {
+		ran = append(ran, id)
+		return nil
+	}, progressFile, func(done, total int) {
+		if total != 5 {
+			t.Errorf("got total %d expected 5", total)
+		}
+		reported = append(reported, done)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"entry-3", "entry-4"}; fmt.Sprint(ran) != fmt.Sprint(want) {
+		t.Errorf("got %v expected %v", ran, want)
+	}
+	if want := []int{4, 5}; fmt.Sprint(reported) != fmt.Sprint(want) {
+		t.Errorf("got progress %v expected %v", reported, want)
+	}
+}
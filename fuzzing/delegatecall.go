@@ -0,0 +1,63 @@
+// Copyright Martin Holst Swende
+// This file is part of the goevmlab library.
+//
+// The library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the goevmlab library. If not, see <http://www.gnu.org/licenses/>.
+
+package fuzzing
+
+import (
+	"math/big"
+
+	"github.com/rgeraldes24/goevmlab/ops"
+	"github.com/rgeraldes24/goevmlab/program"
+	"github.com/theQRL/go-zond/common"
+)
+
+// The accounts of the DelegateCallStorage scenario.
+var (
+	DelegateCaller = common.HexToAddress("0xff0a")
+	DelegateCallee = common.HexToAddress("0xff0b")
+)
+
+// DelegateCallStorage returns a test where DelegateCaller delegatecalls
+// DelegateCallee, which writes value to slot. The caller then reads the slot,
+// and stores what it sees in readSlot. Since delegatecall executes in the
+// context of the caller, both slots of the caller should hold value after
+// execution, while the storage of the callee is left untouched.
+func DelegateCallStorage(fork string, slot, readSlot, value common.Hash) *GstMaker {
+	gst := BasicStateTest(fork)
+
+	callee := program.NewProgram()
+	callee.Sstore(slot.Big(), value.Big())
+	gst.AddAccount(DelegateCallee, GenesisAccount{
+		Code:    callee.Bytecode(),
+		Balance: new(big.Int),
+		Storage: make(map[common.Hash]common.Hash),
+	})
+
+	caller := program.NewProgram()
+	caller.DelegateCall(nil, DelegateCallee, 0, 0, 0, 0)
+	caller.Op(ops.POP)
+	caller.Push(slot.Big())
+	caller.Op(ops.SLOAD)
+	caller.Push(readSlot.Big())
+	caller.Op(ops.SSTORE)
+	gst.AddAccount(DelegateCaller, GenesisAccount{
+		Code:    caller.Bytecode(),
+		Balance: new(big.Int),
+		Storage: make(map[common.Hash]common.Hash),
+	})
+	AddTransaction(&DelegateCaller, gst)
+	return gst
+}
@@ -0,0 +1,50 @@
+// Copyright Martin Holst Swende
+// This file is part of the goevmlab library.
+//
+// The library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the goevmlab library. If not, see <http://www.gnu.org/licenses/>.
+
+package fuzzing
+
+import (
+	"testing"
+
+	"github.com/theQRL/go-zond/common"
+	"github.com/theQRL/go-zond/core/rawdb"
+	"github.com/theQRL/go-zond/core/vm"
+)
+
+func TestDelegateCallStorage(t *testing.T) {
+	var (
+		slot     = common.HexToHash("0x05")
+		readSlot = common.HexToHash("0x06")
+		value    = common.HexToHash("0xc0ffee")
+	)
+	test, err := DelegateCallStorage("Shanghai", slot, readSlot, value).ToStateTest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, statedb, _, err := test.RunNoVerify(test.Subtests()[0], vm.Config{}, false, rawdb.HashScheme)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have := statedb.GetState(DelegateCaller, slot); have != value {
+		t.Errorf("caller slot: got %v expected %v", have, value)
+	}
+	if have := statedb.GetState(DelegateCaller, readSlot); have != value {
+		t.Errorf("caller read-back: got %v expected %v", have, value)
+	}
+	if have := statedb.GetState(DelegateCallee, slot); have != (common.Hash{}) {
+		t.Errorf("callee slot: got %v expected empty", have)
+	}
+}
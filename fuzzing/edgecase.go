@@ -0,0 +1,83 @@
+// Copyright 2024 Martin Holst Swende
+// This file is part of the goevmlab library.
+//
+// The library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the goevmlab library. If not, see <http://www.gnu.org/licenses/>.
+
+package fuzzing
+
+import (
+	"math/big"
+
+	"github.com/rgeraldes24/goevmlab/ops"
+	"github.com/rgeraldes24/goevmlab/program"
+	"github.com/theQRL/go-zond/common"
+)
+
+// EdgeCaseAccount is an account in one of the states where clients have
+// historically disagreed on EXTCODEHASH.
+type EdgeCaseAccount struct {
+	Name    string
+	Address common.Address
+	Account *GenesisAccount // nil if the account does not exist
+}
+
+// EdgeCaseAccounts returns accounts in all the states relevant for
+// EXTCODEHASH, EXTCODESIZE and BALANCE: nonexistent, empty but with balance,
+// empty (touched, i.e. present in the state, with zero balance and nonce),
+// and with code.
+func EdgeCaseAccounts() []EdgeCaseAccount {
+	account := func(balance int64, code []byte) *GenesisAccount {
+		return &GenesisAccount{
+			Code:    code,
+			Storage: make(map[common.Hash]common.Hash),
+			Balance: big.NewInt(balance),
+		}
+	}
+	return []EdgeCaseAccount{
+		{"nonexistent", common.HexToAddress("0xE1"), nil},
+		{"empty-with-balance", common.HexToAddress("0xE2"), account(1, []byte{})},
+		{"empty-touched", common.HexToAddress("0xE3"), account(0, []byte{})},
+		{"with-code", common.HexToAddress("0xE4"), account(0, []byte{0x60, 0x00, 0x60, 0x00, 0xf3})},
+	}
+}
+
+// EdgeCaseProgram returns a program which, for each account, stores
+// EXTCODEHASH, EXTCODESIZE and BALANCE in three consecutive slots.
+func EdgeCaseProgram(accounts []EdgeCaseAccount) []byte {
+	p := program.NewProgram()
+	for i, acc := range accounts {
+		p.ExtCodeHash(acc.Address)
+		p.Push(3 * i)
+		p.Op(ops.SSTORE)
+		p.ExtCodeSize(acc.Address)
+		p.Push(3*i + 1)
+		p.Op(ops.SSTORE)
+		p.Balance(acc.Address)
+		p.Push(3*i + 2)
+		p.Op(ops.SSTORE)
+	}
+	return p.Bytecode()
+}
+
+func fillEdgeCaseAccounts(gst *GstMaker, fork string) {
+	accounts := EdgeCaseAccounts()
+	for _, acc := range accounts {
+		if acc.Account != nil {
+			gst.AddAccount(acc.Address, *acc.Account)
+		}
+	}
+	dest := common.HexToAddress("0x00ca11")
+	gst.SetCode(dest, EdgeCaseProgram(accounts))
+	AddTransaction(&dest, gst)
+}
@@ -0,0 +1,73 @@
+// Copyright 2024 Martin Holst Swende
+// This file is part of the goevmlab library.
+//
+// The library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the goevmlab library. If not, see <http://www.gnu.org/licenses/>.
+
+package fuzzing
+
+import (
+	"testing"
+
+	"github.com/rgeraldes24/goevmlab/ops"
+	"github.com/theQRL/go-zond/common"
+)
+
+func TestEdgeCaseAccounts(t *testing.T) {
+	accounts := EdgeCaseAccounts()
+	if have, want := len(accounts), 4; have != want {
+		t.Fatalf("got %d accounts expected %d", have, want)
+	}
+	var (
+		nonexistent = accounts[0].Account
+		withBalance = accounts[1].Account
+		touched     = accounts[2].Account
+		withCode    = accounts[3].Account
+	)
+	if nonexistent != nil {
+		t.Errorf("nonexistent: got %v expected nil", nonexistent)
+	}
+	if withBalance == nil || len(withBalance.Code) != 0 || withBalance.Balance.Sign() == 0 {
+		t.Errorf("empty-with-balance: got %+v", withBalance)
+	}
+	if touched == nil || len(touched.Code) != 0 || touched.Balance.Sign() != 0 || touched.Nonce != 0 {
+		t.Errorf("empty-touched: got %+v", touched)
+	}
+	if withCode == nil || len(withCode.Code) == 0 {
+		t.Errorf("with-code: got %+v", withCode)
+	}
+	// Each account must be the operand of all three ops
+	referenced := make(map[common.Address]map[ops.OpCode]bool)
+	var last common.Address
+	it := ops.NewInstructionIterator(EdgeCaseProgram(accounts))
+	for it.Next() {
+		switch op := it.Op(); {
+		case op.IsPush():
+			last = common.BytesToAddress(it.Arg())
+		case op == ops.EXTCODEHASH, op == ops.EXTCODESIZE, op == ops.BALANCE:
+			if referenced[last] == nil {
+				referenced[last] = make(map[ops.OpCode]bool)
+			}
+			referenced[last][op] = true
+		}
+	}
+	for _, acc := range accounts {
+		if have := len(referenced[acc.Address]); have != 3 {
+			t.Errorf("%v: referenced by %d ops expected 3", acc.Name, have)
+		}
+	}
+	// The test must be fillable
+	if err := Factory("edgecases", "Shanghai")().Fill(nil); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,61 @@
+// Copyright 2024 Martin Holst Swende
+// This file is part of the goevmlab library.
+//
+// The library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the goevmlab library. If not, see <http://www.gnu.org/licenses/>.
+
+package fuzzing
+
+import (
+	"bytes"
+
+	"github.com/theQRL/go-zond/common"
+	"github.com/theQRL/go-zond/core/vm/runtime"
+)
+
+// equivalenceGas is the gas given to each execution by Equivalent.
+const equivalenceGas = 10_000_000
+
+// executionOutcome is the gas-independent outcome of an execution.
+type executionOutcome struct {
+	ok          bool
+	ret         []byte
+	storageRoot common.Hash
+}
+
+func execute(code, input []byte) executionOutcome {
+	ret, statedb, err := runtime.Execute(code, input, &runtime.Config{GasLimit: equivalenceGas})
+	// This is the address runtime.Execute places the code at
+	addr := common.BytesToAddress([]byte("contract"))
+	statedb.IntermediateRoot(true)
+	return executionOutcome{
+		ok:          err == nil,
+		ret:         ret,
+		storageRoot: statedb.GetStorageRoot(addr),
+	}
+}
+
+// Equivalent executes both programs in-process with each of the inputs as
+// calldata, and returns true if they behave the same: same success status,
+// same return (or revert) data and same storage afterwards. Gas usage is not
+// compared, but each execution is limited to 10M gas.
+func Equivalent(a, b []byte, inputs [][]byte) bool {
+	for _, input := range inputs {
+		outA, outB := execute(a, input), execute(b, input)
+		if outA.ok != outB.ok || !bytes.Equal(outA.ret, outB.ret) ||
+			outA.storageRoot != outB.storageRoot {
+			return false
+		}
+	}
+	return true
+}
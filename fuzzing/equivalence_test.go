@@ -0,0 +1,72 @@
+// Copyright 2024 Martin Holst Swende
+// This file is part of the goevmlab library.
+//
+// The library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the goevmlab library. If not, see <http://www.gnu.org/licenses/>.
+
+package fuzzing
+
+import (
+	"testing"
+
+	"github.com/rgeraldes24/goevmlab/ops"
+	"github.com/rgeraldes24/goevmlab/program"
+)
+
+func TestEquivalent(t *testing.T) {
+	inputs := [][]byte{nil, {1}, {0xff, 0xff}}
+	// Doubles the first word of calldata, stores and returns it
+	double := func(viaMul bool) []byte {
+		p := program.NewProgram()
+		p.Push(0)
+		p.Op(ops.CALLDATALOAD)
+		if viaMul {
+			p.Push(2)
+			p.Op(ops.MUL)
+		} else {
+			p.Op(ops.DUP1)
+			p.Op(ops.ADD)
+		}
+		p.Op(ops.DUP1)
+		p.Push(0)
+		p.Op(ops.SSTORE)
+		p.Push(0)
+		p.Op(ops.MSTORE)
+		p.Return(0, 32)
+		return p.Bytecode()
+	}
+	if !Equivalent(double(true), double(false), inputs) {
+		t.Errorf("expected programs to be equivalent")
+	}
+	// Same return value, but different storage
+	other := program.NewProgram()
+	other.Push(0)
+	other.Op(ops.CALLDATALOAD)
+	other.Push(2)
+	other.Op(ops.MUL)
+	other.Op(ops.DUP1)
+	other.Push(1)
+	other.Op(ops.SSTORE)
+	other.Push(0)
+	other.Op(ops.MSTORE)
+	other.Return(0, 32)
+	if Equivalent(double(true), other.Bytecode(), inputs) {
+		t.Errorf("expected programs to differ in storage")
+	}
+	// Differing return value
+	p := program.NewProgram()
+	p.Return(0, 32)
+	if Equivalent(double(true), p.Bytecode(), inputs) {
+		t.Errorf("expected programs to differ in return value")
+	}
+}
@@ -26,6 +26,10 @@ var fillers = map[string]func(*GstMaker, string){
 	"memops":       fillMemOps,
 	"sstore_sload": fillSstore,
 	"tstore_tload": fillTstore,
+	"calltree":     fillCallTree,
+	"callheavy":    fillCallHeavy,
+	"edgecases":    fillEdgeCaseAccounts,
+	"oog":          fillOutOfGas,
 }
 
 func Factory(name, fork string) func() *GstMaker {
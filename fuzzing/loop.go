@@ -0,0 +1,70 @@
+// Copyright 2024 Martin Holst Swende
+// This file is part of the goevmlab library.
+//
+// The library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the goevmlab library. If not, see <http://www.gnu.org/licenses/>.
+
+package fuzzing
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+
+	"github.com/theQRL/go-zond/common/hexutil"
+	"github.com/theQRL/go-zond/log"
+)
+
+// LoopConfig configures Loop.
+type LoopConfig struct {
+	Seed       int64  // The seed of the first iteration, incremented per iteration
+	Iterations int    // The number of iterations
+	CrashDir   string // The directory to write crash artifacts to
+}
+
+// Crash is the artifact written by Loop for a failing iteration.
+type Crash struct {
+	Seed  int64         `json:"seed"`
+	Code  hexutil.Bytes `json:"code"`
+	Error string        `json:"error"`
+}
+
+// Loop runs fn once per iteration, each time with a source of randomness
+// seeded with the iteration seed. The function returns the program it
+// generated, and an error if the iteration failed. For each failure, the seed,
+// program and error are written to crash-<seed>.json in the crash directory,
+// so the iteration can be replayed. The failing seeds are returned.
+func Loop(cfg LoopConfig, fn func(rnd *rand.Rand) ([]byte, error)) ([]int64, error) {
+	if err := os.MkdirAll(cfg.CrashDir, 0755); err != nil {
+		return nil, err
+	}
+	var failures []int64
+	for i := 0; i < cfg.Iterations; i++ {
+		seed := cfg.Seed + int64(i)
+		log.Debug("Fuzzing iteration", "iteration", i, "seed", seed)
+		code, err := fn(rand.New(rand.NewSource(seed)))
+		if err == nil {
+			continue
+		}
+		log.Warn("Fuzzing iteration failed", "seed", seed, "err", err)
+		failures = append(failures, seed)
+		data, _ := json.MarshalIndent(Crash{seed, code, err.Error()}, "", " ")
+		path := filepath.Join(cfg.CrashDir, fmt.Sprintf("crash-%d.json", seed))
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			return failures, err
+		}
+	}
+	return failures, nil
+}
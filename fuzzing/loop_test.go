@@ -0,0 +1,69 @@
+// Copyright 2024 Martin Holst Swende
+// This file is part of the goevmlab library.
+//
+// The library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the goevmlab library. If not, see <http://www.gnu.org/licenses/>.
+
+package fuzzing
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoop(t *testing.T) {
+	gen := func(rnd *rand.Rand) []byte {
+		code := make([]byte, 8)
+		rnd.Read(code)
+		return code
+	}
+	var (
+		dir     = t.TempDir()
+		badSeed = int64(105)
+		badCode = gen(rand.New(rand.NewSource(badSeed)))
+	)
+
+	failures, err := Loop(LoopConfig{Seed: 100, Iterations: 10, CrashDir: dir},
+		func(rnd *rand.Rand) ([]byte, error) {
+			code := gen(rnd)
+			if bytes.Equal(code, badCode) {
+				return code, errors.New("bad code")
+			}
+			return code, nil
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(failures) != 1 || failures[0] != badSeed {
+		t.Fatalf("got failures %v expected [%d]", failures, badSeed)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "crash-105.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var crash Crash
+	if err := json.Unmarshal(data, &crash); err != nil {
+		t.Fatal(err)
+	}
+	if crash.Seed != badSeed || !bytes.Equal(crash.Code, badCode) || crash.Error != "bad code" {
+		t.Errorf("wrong crash artifact: %v", string(data))
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("got %d crash artifacts expected 1", len(entries))
+	}
+}
@@ -0,0 +1,160 @@
+// Copyright 2024 Martin Holst Swende
+// This file is part of the goevmlab library.
+//
+// The library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the goevmlab library. If not, see <http://www.gnu.org/licenses/>.
+
+package fuzzing
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+
+	"github.com/theQRL/go-zond/common"
+	"github.com/theQRL/go-zond/common/hexutil"
+	"github.com/theQRL/go-zond/common/math"
+	"github.com/theQRL/go-zond/core/state"
+	"github.com/theQRL/go-zond/core/vm"
+)
+
+// MultiTxTest is a sequence of transactions from the sender, applied one
+// after another on a shared state, like the transactions of a block. Unlike a
+// statetest, it can thus test state carried across transactions.
+type MultiTxTest struct {
+	gst *GstMaker
+	txs []StTransaction
+}
+
+// NewMultiTxTest creates a test with the pre-state, env and fork of the given
+// statetest. The transaction of the statetest is not used.
+func NewMultiTxTest(gst *GstMaker) *MultiTxTest {
+	return &MultiTxTest{gst: gst}
+}
+
+// Fork returns the fork of the test, which is the (first) fork of the
+// statetest it was created from.
+func (t *MultiTxTest) Fork() string {
+	if len(t.gst.forks) == 0 {
+		return ""
+	}
+	return t.gst.forks[0]
+}
+
+// AddTx appends a transaction from the sender, calling the given address with
+// the given data.
+func (t *MultiTxTest) AddTx(to common.Address, data []byte) {
+	AddTransaction(&to, t.gst)
+	tx := t.gst.tx
+	tx.Data = []string{hexutil.Encode(data)}
+	tx.Nonce = (*t.gst.pre)[sender].Nonce + uint64(len(t.txs))
+	t.txs = append(t.txs, tx)
+}
+
+// Fill executes the transactions in-process, each as a statetest on top of
+// the post-state of the previous one, and returns the final state and root.
+func (t *MultiTxTest) Fill() (GenesisAlloc, common.Hash, error) {
+	var (
+		maker = *t.gst
+		alloc = *t.gst.pre
+		root  common.Hash
+	)
+	for i := range t.txs {
+		maker.SetPre(&alloc)
+		maker.SetTx(&t.txs[i])
+		statedb, postRoot, err := maker.execute(vm.Config{})
+		if err != nil {
+			return nil, common.Hash{}, fmt.Errorf("tx %d: %w", i, err)
+		}
+		// Reopen the state at the post root, since the dump of the committed
+		// state iterates the pre-state.
+		post, err := state.New(postRoot, statedb.Database(), nil)
+		if err != nil {
+			return nil, common.Hash{}, err
+		}
+		alloc, root = dumpAlloc(post), postRoot
+	}
+	return alloc, root, nil
+}
+
+// dumpAlloc converts the state into a genesis alloc.
+func dumpAlloc(statedb *state.StateDB) GenesisAlloc {
+	alloc := make(GenesisAlloc)
+	for addr, acc := range statedb.RawDump(&state.DumpConfig{}).Accounts {
+		balance, _ := new(big.Int).SetString(acc.Balance, 10)
+		account := GenesisAccount{
+			Code:    acc.Code,
+			Storage: make(map[common.Hash]common.Hash),
+			Balance: balance,
+			Nonce:   acc.Nonce,
+		}
+		for k, v := range acc.Storage {
+			account.Storage[k] = common.HexToHash(v)
+		}
+		if addr == sender {
+			account.PrivateKey = pKey
+		}
+		alloc[addr] = account
+	}
+	return alloc
+}
+
+// t8nTx is a transaction in the input format of `evm t8n`, which signs
+// transactions given with a secretKey.
+type t8nTx struct {
+	Input     hexutil.Bytes         `json:"input"`
+	Gas       math.HexOrDecimal64   `json:"gas"`
+	GasPrice  *math.HexOrDecimal256 `json:"gasPrice"`
+	Nonce     math.HexOrDecimal64   `json:"nonce"`
+	To        string                `json:"to"`
+	Value     string                `json:"value"`
+	V         string                `json:"v"`
+	R         string                `json:"r"`
+	S         string                `json:"s"`
+	SecretKey hexutil.Bytes         `json:"secretKey"`
+}
+
+// WriteT8nInputs writes the alloc, env and txs input files for `evm t8n`
+// into the directory, and returns their paths.
+func (t *MultiTxTest) WriteT8nInputs(dir string) (alloc, env, txs string, err error) {
+	var t8nTxs []t8nTx
+	for _, tx := range t.txs {
+		t8nTxs = append(t8nTxs, t8nTx{
+			Input:     hexutil.MustDecode(tx.Data[0]),
+			Gas:       math.HexOrDecimal64(tx.GasLimit[0]),
+			GasPrice:  (*math.HexOrDecimal256)(tx.GasPrice),
+			Nonce:     math.HexOrDecimal64(tx.Nonce),
+			To:        tx.To,
+			Value:     tx.Value[0],
+			V:         "0x0",
+			R:         "0x0",
+			S:         "0x0",
+			SecretKey: tx.PrivateKey,
+		})
+	}
+	alloc = filepath.Join(dir, "alloc.json")
+	env = filepath.Join(dir, "env.json")
+	txs = filepath.Join(dir, "txs.json")
+	for path, v := range map[string]any{alloc: t.gst.pre, env: t.gst.env, txs: t8nTxs} {
+		data, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return "", "", "", err
+		}
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			return "", "", "", err
+		}
+	}
+	return alloc, env, txs, nil
+}
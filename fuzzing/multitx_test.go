@@ -0,0 +1,81 @@
+// Copyright 2024 Martin Holst Swende
+// This file is part of the goevmlab library.
+//
+// The library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the goevmlab library. If not, see <http://www.gnu.org/licenses/>.
+
+package fuzzing
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/rgeraldes24/goevmlab/ops"
+	"github.com/rgeraldes24/goevmlab/program"
+	"github.com/theQRL/go-zond/common"
+)
+
+func TestMultiTxTest(t *testing.T) {
+	dest := common.HexToAddress("0xc0de")
+	// sstore(1, sload(0)); sstore(0, calldataload(0))
+	p := program.NewProgram()
+	p.Push(0)
+	p.Op(ops.SLOAD)
+	p.Push(1)
+	p.Op(ops.SSTORE)
+	p.Push(0)
+	p.Op(ops.CALLDATALOAD)
+	p.Push(0)
+	p.Op(ops.SSTORE)
+
+	gst := BasicStateTest("Shanghai")
+	gst.SetCode(dest, p.Bytecode())
+	test := NewMultiTxTest(gst)
+	test.AddTx(dest, common.BigToHash(common.Big1).Bytes()) // writes 1 to slot 0
+	test.AddTx(dest, nil)                                   // copies slot 0 to slot 1
+
+	alloc, root, err := test.Fill()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if root == (common.Hash{}) {
+		t.Errorf("missing root")
+	}
+	storage := alloc[dest].Storage
+	if have, want := storage[common.BigToHash(common.Big1)], common.BigToHash(common.Big1); have != want {
+		t.Errorf("second tx did not see the write: got %v expected %v", have, want)
+	}
+	if have := storage[common.Hash{}]; have != (common.Hash{}) {
+		t.Errorf("slot 0: got %v expected zero", have)
+	}
+	if have, want := alloc[sender].Nonce, uint64(2); have != want {
+		t.Errorf("sender nonce: got %d expected %d", have, want)
+	}
+	// The t8n inputs
+	_, _, txs, err := test.WriteT8nInputs(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(txs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var parsed []map[string]string
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		t.Fatal(err)
+	}
+	if len(parsed) != 2 || parsed[0]["nonce"] != "0x0" || parsed[1]["nonce"] != "0x1" {
+		t.Errorf("wrong txs: %v", string(data))
+	}
+}
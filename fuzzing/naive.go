@@ -63,7 +63,12 @@ func randomBytecode(f *ops.Fork) []byte {
 	p.Push(next())
 	p.Push(next())
 	for p.Size() < 1024 {
-		p.Op(f.RandomOp(next()))
+		op := f.RandomOp(next())
+		if ops.Deprecated(op, f.Name) {
+			// Weight down deprecated ops, by drawing again
+			op = f.RandomOp(next())
+		}
+		p.Op(op)
 	}
 	return p.Bytecode()
 }
@@ -0,0 +1,79 @@
+// Copyright Martin Holst Swende
+// This file is part of the goevmlab library.
+//
+// The library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the goevmlab library. If not, see <http://www.gnu.org/licenses/>.
+
+package fuzzing
+
+import (
+	"fmt"
+	"math/big"
+	"math/rand"
+
+	"github.com/rgeraldes24/goevmlab/ops"
+	"github.com/theQRL/go-zond/common"
+	"github.com/theQRL/go-zond/common/hexutil"
+	"github.com/theQRL/go-zond/core"
+)
+
+// EstimateGas returns the approximate gas needed to execute the code, assuming
+// it runs straight through: the sum of the static costs of the ops. Dynamic
+// costs are not included, so the actual gas use is typically higher.
+func EstimateGas(code []byte, fork ops.Fork) uint64 {
+	var (
+		gas uint64
+		it  = ops.NewInstructionIterator(code)
+	)
+	for it.Next() {
+		cost, _ := fork.StaticGas(it.Op())
+		gas += cost
+	}
+	return gas
+}
+
+// fillOutOfGas fills a test with a simple-ops program, and sets the gas of the
+// transaction just below what the program is estimated to need, so that the
+// execution halts with out-of-gas somewhere along the way.
+func fillOutOfGas(gst *GstMaker, fork string) {
+	dest := common.HexToAddress("0xd0de")
+	forkDef := ops.LookupFork(fork)
+	if forkDef == nil {
+		panic(fmt.Sprintf("bad fork %v", fork))
+	}
+	code := generateSimpleOpsProgram(forkDef)
+	gst.AddAccount(dest, GenesisAccount{
+		Code:    code,
+		Balance: new(big.Int),
+		Storage: make(map[common.Hash]common.Hash),
+	})
+	data := randHex(100)
+	intrinsic, err := core.IntrinsicGas(hexutil.MustDecode(data), nil, false)
+	if err != nil {
+		panic(err)
+	}
+	// Somewhere within the last tenth of the estimate
+	estimate := EstimateGas(code, *forkDef)
+	gas := intrinsic + estimate - 1 - uint64(rand.Int63n(int64(estimate/10+1)))
+	// The transaction
+	gst.SetTx(&StTransaction{
+		GasLimit:   []uint64{gas},
+		Nonce:      0,
+		Value:      []string{randHex(4)},
+		Data:       []string{data},
+		GasPrice:   big.NewInt(0x10),
+		To:         dest.Hex(),
+		Sender:     sender,
+		PrivateKey: pKey,
+	})
+}
@@ -0,0 +1,62 @@
+// Copyright Martin Holst Swende
+// This file is part of the goevmlab library.
+//
+// The library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the goevmlab library. If not, see <http://www.gnu.org/licenses/>.
+
+package fuzzing
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/rgeraldes24/goevmlab/ops"
+	"github.com/theQRL/go-zond/common"
+	"github.com/theQRL/go-zond/common/hexutil"
+	"github.com/theQRL/go-zond/core"
+)
+
+func TestEstimateGas(t *testing.T) {
+	fork := *ops.LookupFork("Shanghai")
+	// PUSH1 1, PUSH1 2, ADD, POP
+	if have, want := EstimateGas(common.FromHex("0x600160020150"), fork), uint64(3+3+3+2); have != want {
+		t.Errorf("got %d expected %d", have, want)
+	}
+}
+
+func TestOutOfGas(t *testing.T) {
+	var (
+		fork    = "Shanghai"
+		factory = Factory("oog", fork)
+		oog     int
+	)
+	for i := 0; i < 5; i++ {
+		gst := factory()
+		code := (*gst.pre)[common.HexToAddress("0xd0de")].Code
+		intrinsic, _ := core.IntrinsicGas(hexutil.MustDecode(gst.tx.Data[0]), nil, false)
+		estimate := intrinsic + EstimateGas(code, *ops.LookupFork(fork))
+		if have := gst.tx.GasLimit[0]; have >= estimate {
+			t.Errorf("gas %d not below estimate %d", have, estimate)
+		}
+		var trace bytes.Buffer
+		if err := gst.Fill(&trace); err != nil {
+			t.Fatal(err)
+		}
+		if bytes.Contains(trace.Bytes(), []byte("out of gas")) {
+			oog++
+		}
+	}
+	if oog == 0 {
+		t.Errorf("no execution halted with out-of-gas")
+	}
+}
@@ -27,6 +27,7 @@ import (
 	"github.com/rgeraldes24/goevmlab/program"
 	"github.com/theQRL/go-zond/common"
 	"github.com/theQRL/go-zond/common/hexutil"
+	"github.com/theQRL/go-zond/crypto"
 )
 
 type memFunc func() (offset, size interface{})
@@ -67,6 +68,20 @@ func addressRandomizer(addrs []common.Address) valFunc {
 	}
 }
 
+// DeterministicAddr returns an address derived from the given seed. The
+// addresses are well-spread, the same seed always yields the same address, and
+// the low range used for precompiles (0x00..0xff) is never returned.
+func DeterministicAddr(seed uint64) common.Address {
+	data := binary.BigEndian.AppendUint64(nil, seed)
+	for {
+		addr := common.BytesToAddress(crypto.Keccak256(data))
+		if new(big.Int).SetBytes(addr.Bytes()).Cmp(big.NewInt(0xff)) > 0 {
+			return addr
+		}
+		data = addr.Bytes()
+	}
+}
+
 func ValueRandomizer() valFunc {
 	// every 16th is zero
 	// Most are small, but every 16th is unbounded
@@ -98,6 +113,11 @@ func randCallType() ops.OpCode {
 }
 
 func RandCall(gas, addr, val valFunc, memIn, memOut memFunc) []byte {
+	return randCallOp(randCallType(), gas, addr, val, memIn, memOut)
+}
+
+// randCallOp is RandCall, using the given call op.
+func randCallOp(op ops.OpCode, gas, addr, val valFunc, memIn, memOut memFunc) []byte {
 	p := program.NewProgram()
 	if memOut != nil {
 		memOutOffset, memOutSize := memOut()
@@ -115,7 +135,6 @@ func RandCall(gas, addr, val valFunc, memIn, memOut memFunc) []byte {
 		p.Push(0)
 		p.Push(0)
 	}
-	op := randCallType()
 	if op == ops.CALL || op == ops.CALLCODE {
 		if val != nil {
 			p.Push(val()) //value
@@ -16,7 +16,31 @@
 
 package fuzzing
 
-// These tests are all commented out, since they're only useful if you want to
+import (
+	"math/big"
+	"testing"
+
+	"github.com/theQRL/go-zond/common"
+)
+
+func TestDeterministicAddr(t *testing.T) {
+	seen := make(map[common.Address]uint64)
+	for i := uint64(0); i < 10000; i++ {
+		addr := DeterministicAddr(i)
+		if have := DeterministicAddr(i); have != addr {
+			t.Fatalf("seed %d: not deterministic, %v != %v", i, have, addr)
+		}
+		if new(big.Int).SetBytes(addr.Bytes()).Cmp(big.NewInt(0x09)) <= 0 {
+			t.Fatalf("seed %d: precompile address %v", i, addr)
+		}
+		if prev, ok := seen[addr]; ok {
+			t.Fatalf("seed %d: collision with seed %d", i, prev)
+		}
+		seen[addr] = i
+	}
+}
+
+// The remaining tests are all commented out, since they're only useful if you want to
 // see the values. Not suitable for automated testing, as they don't ever fail
 
 /*
@@ -0,0 +1,80 @@
+// Copyright 2024 Martin Holst Swende
+// This file is part of the goevmlab library.
+//
+// The library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the goevmlab library. If not, see <http://www.gnu.org/licenses/>.
+
+package fuzzing
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/theQRL/go-zond/common"
+)
+
+// SeedFromDir loads the statetests (*.json) in the given directory, and
+// returns the code of all accounts in their pre-states, to be used as seeds
+// for mutation. Accounts without code are skipped, as is code which occurs
+// more than once. The order is deterministic: by file, then by address.
+func SeedFromDir(dir string) ([][]byte, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var seeds [][]byte
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		gst, err := FromGeneralStateTest(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed loading %v: %w", path, err)
+		}
+		var names []string
+		for name := range *gst {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			pre := (*gst)[name].Pre
+			var addrs []common.Address
+			for addr := range pre {
+				addrs = append(addrs, addr)
+			}
+			sort.Slice(addrs, func(i, j int) bool {
+				return bytes.Compare(addrs[i][:], addrs[j][:]) < 0
+			})
+			for _, addr := range addrs {
+				if code := pre[addr].Code; len(code) > 0 && !containsCode(seeds, code) {
+					seeds = append(seeds, code)
+				}
+			}
+		}
+	}
+	return seeds, nil
+}
+
+func containsCode(seeds [][]byte, code []byte) bool {
+	for _, seed := range seeds {
+		if bytes.Equal(seed, code) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,67 @@
+// Copyright 2024 Martin Holst Swende
+// This file is part of the goevmlab library.
+//
+// The library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the goevmlab library. If not, see <http://www.gnu.org/licenses/>.
+
+package fuzzing
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/theQRL/go-zond/common"
+)
+
+func TestSeedFromDir(t *testing.T) {
+	var (
+		dir   = t.TempDir()
+		codes = [][]byte{
+			{0x60, 0x01, 0x60, 0x00, 0x55}, // sstore(0, 1)
+			{0x60, 0x02, 0x60, 0x00, 0x55}, // sstore(0, 2)
+		}
+		dest = common.HexToAddress("0xc0de")
+	)
+	for i, code := range codes {
+		gst := BasicStateTest("Shanghai")
+		gst.SetCode(dest, code)
+		AddTransaction(&dest, gst)
+		data, err := json.Marshal(gst.ToGeneralStateTest("seed"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		name := filepath.Join(dir, []string{"a.json", "b.json"}[i])
+		if err := os.WriteFile(name, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Non-json files are ignored
+	if err := os.WriteFile(filepath.Join(dir, "README.md"), []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	seeds, err := SeedFromDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(seeds) != len(codes) {
+		t.Fatalf("expected %d seeds, got %d", len(codes), len(seeds))
+	}
+	for i, code := range codes {
+		if !bytes.Equal(seeds[i], code) {
+			t.Errorf("seed %d: got %x expected %x", i, seeds[i], code)
+		}
+	}
+}
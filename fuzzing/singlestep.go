@@ -0,0 +1,51 @@
+// Copyright Martin Holst Swende
+// This file is part of the goevmlab library.
+//
+// The library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the goevmlab library. If not, see <http://www.gnu.org/licenses/>.
+
+package fuzzing
+
+import (
+	"math/big"
+
+	"github.com/rgeraldes24/goevmlab/ops"
+	"github.com/rgeraldes24/goevmlab/program"
+	"github.com/theQRL/go-zond/common"
+)
+
+// SingleStepAddress is the account holding the program of a SingleStep test.
+var SingleStepAddress = common.HexToAddress("0x5157")
+
+// SingleStepProgram returns a program which stores mem in memory, pushes the
+// stack, given with the top item first, and executes op. The top item left by
+// the op, if any, is stored in slot zero, making the result of the op part of
+// the stateroot.
+func SingleStepProgram(op ops.OpCode, stack []*big.Int, mem []byte) []byte {
+	p := program.WithState(stack, mem)
+	p.Op(op)
+	if len(op.Pushes()) > 0 {
+		p.Push(0)
+		p.Op(ops.SSTORE)
+	}
+	return p.Bytecode()
+}
+
+// SingleStep returns a test executing SingleStepProgram on the given fork,
+// for comparing the outcome of a single op across the vms.
+func SingleStep(fork string, op ops.OpCode, stack []*big.Int, mem []byte) *GstMaker {
+	gst := BasicStateTest(fork)
+	gst.SetCode(SingleStepAddress, SingleStepProgram(op, stack, mem))
+	AddTransaction(&SingleStepAddress, gst)
+	return gst
+}
@@ -0,0 +1,56 @@
+// Copyright Martin Holst Swende
+// This file is part of the goevmlab library.
+//
+// The library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the goevmlab library. If not, see <http://www.gnu.org/licenses/>.
+
+package fuzzing
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/rgeraldes24/goevmlab/ops"
+	"github.com/theQRL/go-zond/common"
+	"github.com/theQRL/go-zond/core/rawdb"
+	"github.com/theQRL/go-zond/core/vm"
+)
+
+func TestSingleStep(t *testing.T) {
+	gst := SingleStep("Shanghai", ops.EXP, []*big.Int{big.NewInt(2), big.NewInt(10)}, nil)
+	test, err := gst.ToStateTest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, statedb, _, err := test.RunNoVerify(test.Subtests()[0], vm.Config{}, false, rawdb.HashScheme)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have, want := statedb.GetState(SingleStepAddress, common.Hash{}), common.BigToHash(big.NewInt(1024)); have != want {
+		t.Errorf("got %v expected %v", have, want)
+	}
+	// Memory is set up before the op
+	gst = SingleStep("Shanghai", ops.MLOAD, []*big.Int{big.NewInt(1)}, []byte{0xaa, 0xbb})
+	test, err = gst.ToStateTest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, statedb, _, err = test.RunNoVerify(test.Subtests()[0], vm.Config{}, false, rawdb.HashScheme)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := common.BytesToHash(append([]byte{0xbb}, make([]byte, 31)...))
+	if have := statedb.GetState(SingleStepAddress, common.Hash{}); have != want {
+		t.Errorf("got %v expected %v", have, want)
+	}
+}
@@ -17,14 +17,19 @@
 package fuzzing
 
 import (
+	"bytes"
 	"encoding/json"
+	"fmt"
 	"io"
 	"math/big"
 	"os"
 
+	"github.com/rgeraldes24/goevmlab/ops"
 	"github.com/theQRL/go-zond/common"
 	"github.com/theQRL/go-zond/common/hexutil"
 	"github.com/theQRL/go-zond/core/rawdb"
+	"github.com/theQRL/go-zond/core/state"
+	"github.com/theQRL/go-zond/core/types"
 	"github.com/theQRL/go-zond/core/vm"
 	"github.com/theQRL/go-zond/tests"
 	"github.com/theQRL/go-zond/zond/tracers/logger"
@@ -47,6 +52,7 @@ type GstMaker struct {
 	forks []string
 	root  common.Hash
 	logs  common.Hash
+	eoas  map[common.Address]bool // accounts added via WithEOA (true) or WithContract (false)
 }
 
 func NewGstMaker() *GstMaker {
@@ -79,7 +85,7 @@ func (g *GstMaker) AddAccount(address common.Address, a GenesisAccount) {
 	// We must not allow any code in genesis to start with `0xEF`, otherwise
 	// evmone will reject the test.
 	// If the constant 'DisallowEof' is set, then we change `0xEF...` into `0xEE...`
-	if DisallowEOF && len(a.Code) > 0 && a.Code[0] == 0xEF {
+	if DisallowEOF && len(a.Code) > 0 && a.Code[0] == 0xEF && !IsDelegation(a.Code) {
 		a.Code[0] = 0xEE
 	}
 	alloc := *g.pre
@@ -98,7 +104,7 @@ func (g *GstMaker) SetCode(address common.Address, code []byte) {
 	// We must not allow any code in genesis to start with `0xEF`, otherwise
 	// evmone will reject the test.
 	// If the constant 'DisallowEof' is set, then we change `0xEF...` into `0xEE...`
-	if DisallowEOF && len(code) > 0 && code[0] == 0xEF {
+	if DisallowEOF && len(code) > 0 && code[0] == 0xEF && !IsDelegation(code) {
 		code[0] = 0xEE
 	}
 	alloc := *g.pre
@@ -116,6 +122,101 @@ func (g *GstMaker) SetCode(address common.Address, code []byte) {
 	alloc[address] = account
 }
 
+// WithEOA adds an externally owned account, with the given balance, and
+// neither code nor storage.
+func (g *GstMaker) WithEOA(address common.Address, balance *big.Int) *GstMaker {
+	g.AddAccount(address, GenesisAccount{
+		Code:    []byte{},
+		Storage: make(map[common.Hash]common.Hash),
+		Balance: new(big.Int).Set(balance),
+	})
+	g.setKind(address, true)
+	return g
+}
+
+// WithContract adds a contract account, with the given code and balance. The
+// nonce is set to 1, as is the case for contracts since EIP-161.
+func (g *GstMaker) WithContract(address common.Address, code []byte, balance *big.Int) *GstMaker {
+	g.AddAccount(address, GenesisAccount{
+		Code:    code,
+		Storage: make(map[common.Hash]common.Hash),
+		Balance: new(big.Int).Set(balance),
+		Nonce:   1,
+	})
+	g.setKind(address, false)
+	return g
+}
+
+func (g *GstMaker) setKind(address common.Address, eoa bool) {
+	if g.eoas == nil {
+		g.eoas = make(map[common.Address]bool)
+	}
+	g.eoas[address] = eoa
+}
+
+// Validate checks that the accounts added via WithEOA still have no code, and
+// that the ones added via WithContract have code and a non-zero nonce.
+func (g *GstMaker) Validate() error {
+	alloc := *g.pre
+	for address, eoa := range g.eoas {
+		account, exist := alloc[address]
+		switch {
+		case !exist:
+			return fmt.Errorf("account %v missing from pre-state", address)
+		case eoa && len(account.Code) > 0:
+			return fmt.Errorf("eoa %v has code", address)
+		case !eoa && len(account.Code) == 0:
+			return fmt.Errorf("contract %v has no code", address)
+		case !eoa && account.Nonce == 0:
+			return fmt.Errorf("contract %v has zero nonce", address)
+		}
+	}
+	return nil
+}
+
+// DelegationPrefix is the prefix of an EIP-7702 delegation designator. An
+// account whose code is `0xef0100 || address` delegates execution to the code
+// at address.
+var DelegationPrefix = []byte{0xef, 0x01, 0x00}
+
+// DelegationDesignator returns the EIP-7702 designator code for delegating
+// to the given target.
+func DelegationDesignator(target common.Address) []byte {
+	return append(common.CopyBytes(DelegationPrefix), target.Bytes()...)
+}
+
+// IsDelegation returns true if the code is an EIP-7702 delegation designator.
+func IsDelegation(code []byte) bool {
+	return len(code) == len(DelegationPrefix)+common.AddressLength &&
+		bytes.HasPrefix(code, DelegationPrefix)
+}
+
+// SetDelegation makes the account at address delegate to the code at target,
+// (creating the account if it did not previously exist).
+// Unlike SetCode, the 0xEF-prefix is kept intact, since that is what marks the
+// code as a delegation designator.
+// Note: go-zond, and thereby Fill, does not implement EIP-7702, so the tests
+// can only be executed by clients which do.
+func (g *GstMaker) SetDelegation(address, target common.Address) {
+	alloc := *g.pre
+	account, exist := alloc[address]
+	if !exist {
+		account = GenesisAccount{
+			Storage: make(map[common.Hash]common.Hash),
+			Nonce:   0,
+			Balance: new(big.Int),
+		}
+	}
+	account.Code = DelegationDesignator(target)
+	alloc[address] = account
+}
+
+// SetPrevRandao sets the prevrandao (currentRandom) of the env, which is what
+// the DIFFICULTY opcode returns post-merge.
+func (g *GstMaker) SetPrevRandao(rnd common.Hash) {
+	g.env.Random = &rnd
+}
+
 func (g *GstMaker) SetResult(root, logs common.Hash) {
 	g.root = root
 	g.logs = logs
@@ -125,6 +226,80 @@ func (g *GstMaker) SetTx(tx *StTransaction) {
 	g.tx = *tx
 }
 
+// SetGasLimit sets the gas limit of the transaction.
+func (g *GstMaker) SetGasLimit(gas uint64) {
+	g.tx.GasLimit = []uint64{gas}
+}
+
+// SetValue sets the value sent by the transaction.
+func (g *GstMaker) SetValue(value *big.Int) {
+	g.tx.Value = []string{hexutil.EncodeBig(value)}
+}
+
+// SetAccessList sets the (EIP-2930) access list of the transaction, for
+// every data entry. The addresses and storage keys in the list are warm
+// (EIP-2929) from the start of the execution.
+func (g *GstMaker) SetAccessList(list types.AccessList) {
+	g.tx.AccessLists = make([]*types.AccessList, len(g.tx.Data))
+	for i := range g.tx.AccessLists {
+		g.tx.AccessLists[i] = &list
+	}
+}
+
+// TestInfo is metadata about the program of a generated test, to allow
+// triage without re-parsing the code. It is not part of the test, which
+// the clients would not accept, but stored next to it, see WriteTestInfo.
+type TestInfo struct {
+	CodeSize int `json:"codeSize"` // Size of the code at the tx destination
+	OpCount  int `json:"opCount"`  // Number of ops in that code
+}
+
+// NewTestInfo returns the info of the given code.
+func NewTestInfo(code []byte) *TestInfo {
+	info := &TestInfo{CodeSize: len(code)}
+	for it := ops.NewInstructionIterator(code); it.Next(); {
+		info.OpCount++
+	}
+	return info
+}
+
+// TestInfo returns the info of the code at the tx destination, or nil if
+// the tx does not call an account of the pre-state.
+func (g *GstMaker) TestInfo() *TestInfo {
+	if g.tx.To == "" {
+		return nil
+	}
+	account, ok := (*g.pre)[g.GetDestination()]
+	if !ok {
+		return nil
+	}
+	return NewTestInfo(account.Code)
+}
+
+// TestInfoPath returns the path of the info of the test at path.
+func TestInfoPath(path string) string {
+	return path + ".info"
+}
+
+// WriteTestInfo writes the info of the test at path, next to it.
+func WriteTestInfo(path string, info *TestInfo) error {
+	data, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(TestInfoPath(path), data, 0644)
+}
+
+// ReadTestInfo reads the info of the test at path. It returns zero values if
+// the test has no info.
+func ReadTestInfo(path string) TestInfo {
+	var info TestInfo
+	if data, err := os.ReadFile(TestInfoPath(path)); err == nil {
+		_ = json.Unmarshal(data, &info)
+	}
+	return info
+}
+
 func (g *GstMaker) ToSubTest() *stJSON {
 	st := &stJSON{}
 	st.Pre = *g.pre
@@ -160,8 +335,28 @@ func FromGeneralStateTest(name string) (*GeneralStateTest, error) {
 	return &gst, err
 }
 
-func (g *GstMaker) ToStateTest() (tests.StateTest, error) {
+// WriteTempTest writes the test to a temporary file, for the vms to execute.
+// The cleanup function removes the file.
+func WriteTempTest(test *GeneralStateTest) (path string, cleanup func(), err error) {
+	f, err := os.CreateTemp("", "statetest-*.json")
+	if err != nil {
+		return "", nil, err
+	}
+	cleanup = func() { os.Remove(f.Name()) }
+	if err = json.NewEncoder(f).Encode(test); err == nil {
+		err = f.Close()
+	} else {
+		f.Close()
+	}
+	if err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	return f.Name(), cleanup, nil
+}
 
+// ToStateTest converts the test into a go-zond statetest.
+func (g *GstMaker) ToStateTest() (tests.StateTest, error) {
 	stjson := g.ToSubTest()
 	var gethStateTest tests.StateTest
 	data, err := json.Marshal(stjson)
@@ -178,20 +373,42 @@ func (g *GstMaker) EnableFork(fork string) {
 	g.forks = append(g.forks, fork)
 }
 
-// FillTest uses go-ethereum internally to determine the state root and logs, and optionally
-// outputs the trace to the given writer (if non-nil)
-func (g *GstMaker) Fill(traceOutput io.Writer) error {
+// ForkPair returns two tests, identical except for the active fork: the
+// first for the fork before a transition, the second for the fork after it.
+// Running both on the vms shows where the behaviour changes due to the fork.
+func (g *GstMaker) ForkPair(name, before, after string) (*GeneralStateTest, *GeneralStateTest) {
+	forks := g.forks
+	defer func() { g.forks = forks }()
+	g.forks = []string{before}
+	a := g.ToGeneralStateTest(name)
+	g.forks = []string{after}
+	b := g.ToGeneralStateTest(name)
+	return a, b
+}
 
+// execute executes the test with the go-zond statetest runner, returning the
+// post state and its root. Like the clients, the runner uses the chain id of
+// the fork config, see tests.Forks.
+func (g *GstMaker) execute(cfg vm.Config) (*state.StateDB, common.Hash, error) {
 	test, err := g.ToStateTest()
 	if err != nil {
+		return nil, common.Hash{}, err
+	}
+	_, _, statedb, root, err := test.RunNoVerify(test.Subtests()[0], cfg, false, rawdb.HashScheme)
+	return statedb, root, err
+}
+
+// FillTest uses go-ethereum internally to determine the state root and logs, and optionally
+// outputs the trace to the given writer (if non-nil)
+func (g *GstMaker) Fill(traceOutput io.Writer) error {
+	if err := g.Validate(); err != nil {
 		return err
 	}
-	subtest := test.Subtests()[0]
 	cfg := vm.Config{}
 	if traceOutput != nil {
 		cfg.Tracer = logger.NewJSONLogger(&logger.Config{}, traceOutput)
 	}
-	_, _, statedb, root, err := test.RunNoVerify(subtest, cfg, false, rawdb.HashScheme)
+	statedb, root, err := g.execute(cfg)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,324 @@
+// Copyright 2024 Martin Holst Swende
+// This file is part of the goevmlab library.
+//
+// The library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the goevmlab library. If not, see <http://www.gnu.org/licenses/>.
+
+package fuzzing
+
+import (
+	"encoding/json"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rgeraldes24/goevmlab/ops"
+	"github.com/rgeraldes24/goevmlab/program"
+	"github.com/theQRL/go-zond/common"
+	"github.com/theQRL/go-zond/core/rawdb"
+	"github.com/theQRL/go-zond/core/types"
+	"github.com/theQRL/go-zond/core/vm"
+	"github.com/theQRL/go-zond/params"
+	"github.com/theQRL/go-zond/tests"
+)
+
+func TestDelegation(t *testing.T) {
+	var (
+		delegator = common.HexToAddress("0xde1e")
+		target    = common.HexToAddress("0x7a26")
+	)
+	gst := BasicStateTest("Shanghai")
+	gst.SetDelegation(delegator, target)
+
+	data, err := json.Marshal(gst.ToGeneralStateTest("delegation"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `"code":"0xef0100` + "0000000000000000000000000000000000007a26" + `"`
+	if !strings.Contains(string(data), want) {
+		t.Fatalf("delegation designator missing, want %v in\n%v", want, string(data))
+	}
+	// The designator must not be subjected to the 0xEF-rewrite, even when
+	// the account is touched again.
+	gst.AddAccount(delegator, (*gst.pre)[delegator])
+	if have := (*gst.pre)[delegator].Code; have[0] != 0xef {
+		t.Fatalf("designator rewritten: %x", have)
+	}
+}
+
+func TestAccountKinds(t *testing.T) {
+	var (
+		eoa      = common.HexToAddress("0xe0a")
+		contract = common.HexToAddress("0xc0de")
+		code     = []byte{0x60, 0x01, 0x60, 0x00, 0x55} // sstore(0, 1)
+	)
+	gst := BasicStateTest("Shanghai").
+		WithEOA(eoa, big.NewInt(100)).
+		WithContract(contract, code, big.NewInt(0))
+	AddTransaction(&contract, gst)
+	if err := gst.Fill(nil); err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(gst.ToGeneralStateTest("kinds"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var parsed map[string]struct {
+		Pre map[common.Address]struct {
+			Code  string `json:"code"`
+			Nonce string `json:"nonce"`
+		} `json:"pre"`
+	}
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		t.Fatal(err)
+	}
+	pre := parsed["kinds"].Pre
+	if have, want := pre[eoa].Code, "0x"; have != want {
+		t.Errorf("eoa code: got %v expected %v", have, want)
+	}
+	if have, want := pre[contract].Code, "0x6001600055"; have != want {
+		t.Errorf("contract code: got %v expected %v", have, want)
+	}
+	if have, want := pre[contract].Nonce, "0x1"; have != want {
+		t.Errorf("contract nonce: got %v expected %v", have, want)
+	}
+	// Giving the eoa code violates its kind
+	gst.SetCode(eoa, code)
+	if err := gst.Validate(); err == nil {
+		t.Errorf("expected validation error")
+	}
+	if err := gst.Fill(nil); err == nil {
+		t.Errorf("expected fill to fail")
+	}
+}
+
+func TestPrevRandao(t *testing.T) {
+	var (
+		dest = common.HexToAddress("0xc0de")
+		rnd  = common.HexToHash("0x1122334455667788990011223344556677889900112233445566778899001122")
+	)
+	gst := BasicStateTest("Shanghai")
+	gst.SetPrevRandao(rnd)
+	p := program.NewProgram()
+	p.PrevRandao()
+	p.Push(0)
+	p.Op(ops.SSTORE)
+	gst.SetCode(dest, p.Bytecode())
+	AddTransaction(&dest, gst)
+
+	test, err := gst.ToStateTest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, statedb, _, err := test.RunNoVerify(test.Subtests()[0], vm.Config{}, false, rawdb.HashScheme)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have := statedb.GetState(dest, common.Hash{}); have != rnd {
+		t.Errorf("got %v expected %v", have, rnd)
+	}
+}
+
+func TestChainID(t *testing.T) {
+	var (
+		dest    = common.HexToAddress("0xc0de")
+		balance = big.NewInt(0x1234)
+	)
+	gst := BasicStateTest("Shanghai")
+	p := program.NewProgram()
+	p.ChainId()
+	p.Push(0)
+	p.Op(ops.SSTORE)
+	p.SelfBalance()
+	p.Push(1)
+	p.Op(ops.SSTORE)
+	gst.WithContract(dest, p.Bytecode(), balance)
+	AddTransaction(&dest, gst)
+	gst.SetValue(new(big.Int))
+
+	// The clients execute the test with the chain id of the fork config, and
+	// so must the filler.
+	statedb, _, err := gst.execute(vm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have, want := statedb.GetState(dest, common.Hash{}), common.BigToHash(tests.Forks["Shanghai"].ChainID); have != want {
+		t.Errorf("chain id: got %v expected %v", have, want)
+	}
+	if have, want := statedb.GetState(dest, common.BigToHash(big.NewInt(1))), common.BigToHash(balance); have != want {
+		t.Errorf("self balance: got %v expected %v", have, want)
+	}
+}
+
+func TestWriteTempTest(t *testing.T) {
+	test := BasicStateTest("Shanghai").ToGeneralStateTest("temp")
+	path, cleanup, err := WriteTempTest(test)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var loaded GeneralStateTest
+	if err := json.Unmarshal(data, &loaded); err != nil {
+		t.Fatalf("invalid json: %v", err)
+	}
+	if _, ok := loaded["temp"]; !ok {
+		t.Errorf("test missing from %s", data)
+	}
+	cleanup()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("file not removed: %v", err)
+	}
+}
+
+func TestTestInfo(t *testing.T) {
+	dest := common.HexToAddress("0xc0de")
+	gst := BasicStateTest("Shanghai")
+	p := program.NewProgram()
+	p.Push(0x1234) // PUSH2
+	p.Push(0)      // PUSH1
+	p.Op(ops.SSTORE)
+	gst.SetCode(dest, p.Bytecode())
+	AddTransaction(&dest, gst)
+
+	if have, want := *gst.TestInfo(), (TestInfo{CodeSize: 6, OpCount: 3}); have != want {
+		t.Errorf("got %+v expected %+v", have, want)
+	}
+	// The info is not part of the test
+	data, err := json.Marshal(gst.ToGeneralStateTest("info"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "codeSize") {
+		t.Errorf("unexpected info in %s", data)
+	}
+}
+
+func TestForkPair(t *testing.T) {
+	dest := common.HexToAddress("0xc0de")
+	gst := BasicStateTest("Shanghai")
+	p := program.NewProgram()
+	p.Tstore(0, 1)
+	p.Push(0)
+	p.Op(ops.TLOAD)
+	p.Push(0)
+	p.Op(ops.SSTORE)
+	gst.SetCode(dest, p.Bytecode())
+	AddTransaction(&dest, gst)
+
+	before, after := gst.ForkPair("tstore", "Shanghai", "Cancun")
+	a, err := json.Marshal(before)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := json.Marshal(after)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(a) == string(b) {
+		t.Fatal("expected the tests to differ")
+	}
+	if have := strings.ReplaceAll(string(b), "Cancun", "Shanghai"); have != string(a) {
+		t.Errorf("tests differ in more than the fork:\n%s\n%s", a, b)
+	}
+	// TSTORE only exists after the fork
+	if ops.LookupFork("Shanghai").IsValid(ops.TSTORE) || !ops.LookupFork("Cancun").IsValid(ops.TSTORE) {
+		t.Errorf("expected TSTORE to be introduced in Cancun")
+	}
+	// Before the fork, the execution fails, after it the slot is written.
+	// go-zond does not define Cancun, so the transient storage of it
+	// (EIP-1153) is instead enabled on top of Shanghai.
+	for i, tt := range []struct {
+		fork string
+		want common.Hash
+	}{
+		{"Shanghai", common.Hash{}},
+		{"Shanghai+1153", common.BigToHash(big.NewInt(1))},
+	} {
+		gst.forks = []string{tt.fork}
+		statedb, _, err := gst.execute(vm.Config{})
+		if err != nil {
+			t.Fatalf("test %d: %v", i, err)
+		}
+		if have := statedb.GetState(dest, common.Hash{}); have != tt.want {
+			t.Errorf("test %d: got %v expected %v", i, have, tt.want)
+		}
+	}
+}
+
+func TestAccessList(t *testing.T) {
+	var (
+		dest = common.HexToAddress("0x2929")
+		slot = common.HexToHash("0x01")
+	)
+	// Store the gas spent by GAS, SLOAD, POP in slot 0
+	p := program.NewProgram()
+	p.Op(ops.GAS)
+	p.Push(slot.Bytes())
+	p.Op(ops.SLOAD)
+	p.Op(ops.POP)
+	p.Op(ops.GAS)
+	p.Op(ops.SWAP1)
+	p.Op(ops.SUB)
+	p.Push(0)
+	p.Op(ops.SSTORE)
+	sloadCost := func(list types.AccessList) uint64 {
+		t.Helper()
+		gst := BasicStateTest("Shanghai")
+		gst.SetCode(dest, p.Bytecode())
+		AddTransaction(&dest, gst)
+		if list != nil {
+			gst.SetAccessList(list)
+		}
+		test, err := gst.ToStateTest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _, statedb, _, err := test.RunNoVerify(test.Subtests()[0], vm.Config{}, false, rawdb.HashScheme)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Minus PUSH (3), POP (2) and GAS (2)
+		return statedb.GetState(dest, common.Hash{}).Big().Uint64() - 7
+	}
+	if have, want := sloadCost(nil), params.ColdSloadCostEIP2929; have != want {
+		t.Errorf("cold: got %v expected %v", have, want)
+	}
+	list := types.AccessList{{Address: dest, StorageKeys: []common.Hash{slot}}}
+	if have, want := sloadCost(list), params.WarmStorageReadCostEIP2929; have != want {
+		t.Errorf("warm: got %v expected %v", have, want)
+	}
+}
+
+func TestReadTestInfo(t *testing.T) {
+	dest := common.HexToAddress("0xc0de")
+	gst := BasicStateTest("Shanghai")
+	code := []byte{byte(ops.PUSH1), 0x01, byte(ops.PUSH1), 0x00, byte(ops.SSTORE), byte(ops.STOP)}
+	gst.SetCode(dest, code)
+	AddTransaction(&dest, gst)
+	path := filepath.Join(t.TempDir(), "info.json")
+	if err := WriteTestInfo(path, gst.TestInfo()); err != nil {
+		t.Fatal(err)
+	}
+	if info := ReadTestInfo(path); info.CodeSize != 6 || info.OpCount != 4 {
+		t.Errorf("got %d, %d expected 6, 4", info.CodeSize, info.OpCount)
+	}
+	// Tests without info give zero values
+	if info := ReadTestInfo("nonexistent.json"); info.CodeSize != 0 || info.OpCount != 0 {
+		t.Errorf("got %d, %d expected 0, 0", info.CodeSize, info.OpCount)
+	}
+}
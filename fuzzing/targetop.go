@@ -0,0 +1,69 @@
+// Copyright Martin Holst Swende
+// This file is part of the goevmlab library.
+//
+// The library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the goevmlab library. If not, see <http://www.gnu.org/licenses/>.
+
+package fuzzing
+
+import (
+	"math/big"
+	"math/rand"
+
+	"github.com/rgeraldes24/goevmlab/ops"
+	"github.com/rgeraldes24/goevmlab/program"
+)
+
+// targetOpRounds is the number of times TargetOp invokes the op.
+const targetOpRounds = 100
+
+// TargetOp generates a program which exercises the given op over and over,
+// each time with fresh operands: interesting integers, random words, or, for
+// ops touching memory, small offsets and sizes. The results of each
+// invocation are popped, so the stack stays valid and shallow throughout.
+func TargetOp(op ops.OpCode, rnd *rand.Rand) []byte {
+	p := program.NewProgram()
+	for i := 0; i < targetOpRounds; i++ {
+		if op.IsPush() {
+			arg := make([]byte, op.PushSize())
+			rnd.Read(arg)
+			p.Op(op)
+			p.AddAll(arg)
+		} else {
+			pops := op.Pops()
+			for j := len(pops) - 1; j >= 0; j-- {
+				p.Push(targetOperand(op, rnd))
+			}
+			p.Op(op)
+		}
+		for j := 0; j < len(op.Pushes()); j++ {
+			p.Op(ops.POP)
+		}
+	}
+	return p.Bytecode()
+}
+
+// targetOperand returns an operand for the op.
+func targetOperand(op ops.OpCode, rnd *rand.Rand) *big.Int {
+	if op.ExpandsMem() && rnd.Intn(4) != 0 {
+		// Mostly keep memory-touching ops from going out of gas immediately.
+		return big.NewInt(rnd.Int63n(1024))
+	}
+	if rnd.Intn(2) == 0 {
+		v, _ := new(big.Int).SetString(integers[rnd.Intn(len(integers))], 16)
+		return v
+	}
+	word := make([]byte, 1+rnd.Intn(32))
+	rnd.Read(word)
+	return new(big.Int).SetBytes(word)
+}
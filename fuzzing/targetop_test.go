@@ -0,0 +1,58 @@
+// Copyright Martin Holst Swende
+// This file is part of the goevmlab library.
+//
+// The library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the goevmlab library. If not, see <http://www.gnu.org/licenses/>.
+
+package fuzzing
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/rgeraldes24/goevmlab/ops"
+	"github.com/theQRL/go-zond/core/vm/runtime"
+)
+
+func TestTargetOp(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, op := range []ops.OpCode{ops.EXP, ops.ADDMOD, ops.DUP3, ops.SWAP2, ops.PUSH2, ops.MSTORE, ops.KECCAK256} {
+		var (
+			code  = TargetOp(op, rnd)
+			count int
+			depth int
+			it    = ops.NewInstructionIterator(code)
+		)
+		for it.Next() {
+			if it.Op() == op {
+				count++
+			}
+			if depth -= len(it.Op().Pops()); depth < 0 {
+				t.Fatalf("%v: stack underflow at pc %d", op, it.PC())
+			}
+			if depth += len(it.Op().Pushes()); depth > 1024 {
+				t.Fatalf("%v: stack overflow at pc %d", op, it.PC())
+			}
+		}
+		if err := it.Error(); err != nil {
+			t.Fatalf("%v: %v", op, err)
+		}
+		if count == 0 {
+			t.Errorf("%v: op not present in %x", op, code)
+		}
+	}
+	// And the program should execute without stack errors.
+	if _, _, err := runtime.Execute(TargetOp(ops.EXP, rnd), nil, nil); err != nil {
+		t.Errorf("execution failed: %v", err)
+	}
+}
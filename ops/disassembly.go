@@ -30,6 +30,31 @@ func InstructionCount(code []byte) int {
 	return c
 }
 
+// Immediate returns the immediate bytes of the instruction at pc, along with
+// the pc of the next instruction. If the immediate is truncated by the end of
+// the code, the available bytes are returned.
+// Only PUSH-instructions have immediates: EOF is not supported, so the EOF
+// instructions (RJUMP, RJUMPI, RJUMPV) are not defined in this instruction
+// set, and their opcodes are taken by TLOAD, TSTORE and MCOPY.
+func Immediate(code []byte, pc int) ([]byte, int) {
+	if pc < 0 || pc >= len(code) {
+		return nil, pc + 1
+	}
+	op := OpCode(code[pc])
+	if !op.IsPush() {
+		return nil, pc + 1
+	}
+	size := op.PushSize()
+	start, end := pc+1, pc+1+size
+	if start > len(code) {
+		start = len(code)
+	}
+	if end > len(code) {
+		end = len(code)
+	}
+	return code[start:end], pc + 1 + size
+}
+
 // Skips num instructions.
 func (it *instructionIterator) Skip(num int) {
 	c := 0
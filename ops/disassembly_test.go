@@ -0,0 +1,48 @@
+// Copyright 2024 Martin Holst Swende
+// This file is part of the goevmlab library.
+//
+// The library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the goevmlab library. If not, see <http://www.gnu.org/licenses/>.
+
+package ops
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/theQRL/go-zond/common"
+)
+
+func TestImmediate(t *testing.T) {
+	addr := common.FromHex("0xdeadbeefdeadbeefdeadbeefdeadbeefdeadbeef")
+	code := append([]byte{byte(PUSH20)}, addr...)
+	code = append(code, byte(MCOPY), byte(PUSH2), 0xff)
+
+	tests := []struct {
+		pc      int
+		imm     []byte
+		nextPc  int
+		comment string
+	}{
+		{0, addr, 21, "PUSH20"},
+		{21, nil, 22, "MCOPY, no immediate"},
+		{22, []byte{0xff}, 25, "truncated PUSH2"},
+		{25, nil, 26, "out of bounds"},
+	}
+	for i, tc := range tests {
+		imm, next := Immediate(code, tc.pc)
+		if !bytes.Equal(imm, tc.imm) || next != tc.nextPc {
+			t.Errorf("test %d (%v): got %x, %d expected %x, %d", i, tc.comment, imm, next, tc.imm, tc.nextPc)
+		}
+	}
+}
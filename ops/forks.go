@@ -168,6 +168,31 @@ func ValidOpcodesInFork(fork string) ([]OpCode, error) {
 	return nil, fmt.Errorf("fork %v not defined", fork)
 }
 
+// Deprecated returns true if the op is deprecated, or discouraged, in the
+// given fork. CALLCODE is always discouraged, since DELEGATECALL supersedes
+// it. SELFDESTRUCT is deprecated as of Cancun (EIP-6780), where it only deletes
+// accounts created in the same transaction.
+func Deprecated(op OpCode, fork string) bool {
+	switch op {
+	case CALLCODE:
+		return true
+	case SELFDESTRUCT:
+		return forkIndex(fork) >= forkIndex(cancun.Name)
+	}
+	return false
+}
+
+// forkIndex returns the position of the fork in the list of forks, or -1 if
+// the fork is not defined.
+func forkIndex(fork string) int {
+	for i, f := range forks {
+		if f.Name == fork {
+			return i
+		}
+	}
+	return -1
+}
+
 // RandomOp returns a random (valid) opcode
 func (f Fork) RandomOp(rnd byte) OpCode {
 	return f.ValidOpcodes[int(rnd)%len(f.ValidOpcodes)]
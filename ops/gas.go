@@ -0,0 +1,112 @@
+// Copyright 2024 Martin Holst Swende
+// This file is part of the goevmlab library.
+//
+// The library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the goevmlab library. If not, see <http://www.gnu.org/licenses/>.
+
+package ops
+
+// gasCost is the static part of the gas cost of an op. If dynamic is set, the
+// op also has a dynamic part, e.g. for memory expansion or account access.
+type gasCost struct {
+	base    uint64
+	dynamic bool
+}
+
+// gasTable holds the static gas costs as of Berlin (EIP-2929), where the
+// account and storage accessing ops cost the warm access cost, plus a dynamic
+// cold access surcharge.
+var gasTable = map[OpCode]gasCost{
+	STOP: {0, false}, ADD: {3, false}, MUL: {5, false}, SUB: {3, false},
+	DIV: {5, false}, SDIV: {5, false}, MOD: {5, false}, SMOD: {5, false},
+	ADDMOD: {8, false}, MULMOD: {8, false}, EXP: {10, true}, SIGNEXTEND: {5, false},
+
+	LT: {3, false}, GT: {3, false}, SLT: {3, false}, SGT: {3, false},
+	EQ: {3, false}, ISZERO: {3, false}, AND: {3, false}, OR: {3, false},
+	XOR: {3, false}, NOT: {3, false}, BYTE: {3, false}, SHL: {3, false},
+	SHR: {3, false}, SAR: {3, false},
+
+	KECCAK256: {30, true},
+
+	ADDRESS: {2, false}, BALANCE: {100, true}, ORIGIN: {2, false}, CALLER: {2, false},
+	CALLVALUE: {2, false}, CALLDATALOAD: {3, false}, CALLDATASIZE: {2, false},
+	CALLDATACOPY: {3, true}, CODESIZE: {2, false}, CODECOPY: {3, true},
+	GASPRICE: {2, false}, EXTCODESIZE: {100, true}, EXTCODECOPY: {100, true},
+	RETURNDATASIZE: {2, false}, RETURNDATACOPY: {3, true}, EXTCODEHASH: {100, true},
+
+	BLOCKHASH: {20, false}, COINBASE: {2, false}, TIMESTAMP: {2, false},
+	NUMBER: {2, false}, DIFFICULTY: {2, false}, GASLIMIT: {2, false},
+	CHAINID: {2, false}, SELFBALANCE: {5, false}, BASEFEE: {2, false},
+	BLOBHASH: {3, false}, BLOBBASEFEE: {2, false},
+
+	POP: {2, false}, MLOAD: {3, true}, MSTORE: {3, true}, MSTORE8: {3, true},
+	SLOAD: {100, true}, SSTORE: {100, true}, JUMP: {8, false}, JUMPI: {10, false},
+	PC: {2, false}, MSIZE: {2, false}, GAS: {2, false}, JUMPDEST: {1, false},
+	TLOAD: {100, false}, TSTORE: {100, false}, MCOPY: {3, true}, PUSH0: {2, false},
+
+	LOG0: {375, true}, LOG1: {750, true}, LOG2: {1125, true}, LOG3: {1500, true},
+	LOG4: {1875, true},
+
+	CREATE: {32000, true}, CALL: {100, true}, CALLCODE: {100, true},
+	RETURN: {0, true}, DELEGATECALL: {100, true}, CREATE2: {32000, true},
+	STATICCALL: {100, true}, REVERT: {0, true}, INVALID: {0, false},
+	SELFDESTRUCT: {5000, true},
+}
+
+// istanbulGasTable holds the costs which differ before Berlin, where
+// account and storage access had a fixed cost.
+var istanbulGasTable = map[OpCode]gasCost{
+	BALANCE: {700, false}, EXTCODESIZE: {700, false}, EXTCODECOPY: {700, true},
+	EXTCODEHASH: {700, false}, SLOAD: {800, false}, SSTORE: {800, true},
+	CALL: {700, true}, CALLCODE: {700, true}, DELEGATECALL: {700, true},
+	STATICCALL: {700, true},
+}
+
+func init() {
+	for op := PUSH1; op <= PUSH32; op++ {
+		gasTable[op] = gasCost{3, false}
+	}
+	for op := DUP1; op <= DUP16; op++ {
+		gasTable[op] = gasCost{3, false}
+	}
+	for op := SWAP1; op <= SWAP16; op++ {
+		gasTable[op] = gasCost{3, false}
+	}
+}
+
+// StaticGas returns the static gas cost of the op in the fork, and whether the
+// op also has a dynamic cost. For ops which are dynamically priced, the static
+// cost is the minimum cost, e.g. the warm access cost for CALL. Ops which are
+// not valid in the fork cost nothing.
+func (f Fork) StaticGas(op OpCode) (gas uint64, dynamic bool) {
+	if !f.IsValid(op) {
+		return 0, false
+	}
+	cost := gasTable[op]
+	if f.Name == istanbul.Name {
+		if c, ok := istanbulGasTable[op]; ok {
+			cost = c
+		}
+	}
+	return cost.base, cost.dynamic
+}
+
+// IsValid returns true if the op is valid in the fork.
+func (f Fork) IsValid(op OpCode) bool {
+	for _, valid := range f.ValidOpcodes {
+		if valid == op {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,36 @@
+// Copyright 2024 Martin Holst Swende
+// This file is part of the goevmlab library.
+//
+// The library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the goevmlab library. If not, see <http://www.gnu.org/licenses/>.
+
+package ops
+
+import "testing"
+
+func TestStaticGas(t *testing.T) {
+	for _, fork := range forks {
+		for _, op := range fork.ValidOpcodes {
+			if _, ok := gasTable[op]; !ok {
+				t.Errorf("%v: op %v missing from gas table", fork.Name, op)
+			}
+		}
+	}
+	shanghai := *LookupFork("Shanghai")
+	if gas, dyn := shanghai.StaticGas(SLOAD); gas != 100 || !dyn {
+		t.Errorf("got %d/%v expected 100/true", gas, dyn)
+	}
+	if gas, dyn := shanghai.StaticGas(TLOAD); gas != 0 || dyn {
+		t.Errorf("invalid op: got %d/%v expected 0/false", gas, dyn)
+	}
+}
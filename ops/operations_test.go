@@ -119,3 +119,21 @@ func testForkOpcodes(t *testing.T, fork string) {
 		}
 	}
 }
+
+func TestDeprecated(t *testing.T) {
+	for _, tc := range []struct {
+		op       OpCode
+		fork     string
+		expected bool
+	}{
+		{SELFDESTRUCT, "Shanghai", false},
+		{SELFDESTRUCT, "Cancun", true},
+		{CALLCODE, "Istanbul", true},
+		{CALLCODE, "Cancun", true},
+		{DELEGATECALL, "Cancun", false},
+	} {
+		if have := Deprecated(tc.op, tc.fork); have != tc.expected {
+			t.Errorf("%v in %v: got %v expected %v", tc.op, tc.fork, have, tc.expected)
+		}
+	}
+}
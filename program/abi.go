@@ -0,0 +1,67 @@
+// Copyright 2024 Martin Holst Swende
+// This file is part of the goevmlab library.
+//
+// The library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the goevmlab library. If not, see <http://www.gnu.org/licenses/>.
+
+package program
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/holiman/uint256"
+	"github.com/theQRL/go-zond/common"
+)
+
+// AbiEncode returns the calldata for calling the function with the given
+// selector: the selector, followed by the arguments padded to 32 bytes each.
+// The supported argument types are unsigned integers (int, uint64, *big.Int
+// and *uint256.Int) and common.Address.
+func AbiEncode(selector [4]byte, args ...interface{}) []byte {
+	data := append(make([]byte, 0, 4+32*len(args)), selector[:]...)
+	for _, arg := range args {
+		var word [32]byte
+		switch v := arg.(type) {
+		case int:
+			if v < 0 {
+				panic(fmt.Sprintf("negative argument %d", v))
+			}
+			new(big.Int).SetUint64(uint64(v)).FillBytes(word[:])
+		case uint64:
+			new(big.Int).SetUint64(v).FillBytes(word[:])
+		case *big.Int:
+			if v.Sign() < 0 || v.BitLen() > 256 {
+				panic(fmt.Sprintf("argument %v out of range", v))
+			}
+			v.FillBytes(word[:])
+		case *uint256.Int:
+			word = v.Bytes32()
+		case common.Address:
+			copy(word[32-common.AddressLength:], v.Bytes())
+		default:
+			panic(fmt.Sprintf("unsupported argument type %T", v))
+		}
+		data = append(data, word[:]...)
+	}
+	return data
+}
+
+// AbiCall places the ABI-encoded calldata in memory at offset 0, and CALLs the
+// address with all gas and no value. The success flag is left on the stack,
+// and the return data can be read using RETURNDATACOPY.
+func (p *Program) AbiCall(address interface{}, selector [4]byte, args ...interface{}) {
+	input := AbiEncode(selector, args...)
+	p.Mstore(input, 0)
+	p.Call(nil, address, 0, 0, len(input), 0, 0)
+}
@@ -0,0 +1,78 @@
+// Copyright 2024 Martin Holst Swende
+// This file is part of the goevmlab library.
+//
+// The library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the goevmlab library. If not, see <http://www.gnu.org/licenses/>.
+
+package program
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/holiman/uint256"
+	"github.com/rgeraldes24/goevmlab/ops"
+	"github.com/theQRL/go-zond/common"
+	"github.com/theQRL/go-zond/common/hexutil"
+	"github.com/theQRL/go-zond/core/rawdb"
+	"github.com/theQRL/go-zond/core/state"
+	"github.com/theQRL/go-zond/core/vm/runtime"
+)
+
+func TestAbiEncode(t *testing.T) {
+	have := AbiEncode([4]byte{0xa9, 0x05, 0x9c, 0xbb}, common.HexToAddress("0xc0de"), 1)
+	want := hexutil.MustDecode("0xa9059cbb" +
+		"000000000000000000000000000000000000000000000000000000000000c0de" +
+		"0000000000000000000000000000000000000000000000000000000000000001")
+	if !bytes.Equal(have, want) {
+		t.Errorf("got %x expected %x", have, want)
+	}
+}
+
+func TestAbiCall(t *testing.T) {
+	var (
+		echo       = common.HexToAddress("0xec80")
+		selector   = [4]byte{0x12, 0x34, 0x56, 0x78}
+		statedb, _ = state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	)
+	// The callee returns its calldata
+	callee := NewProgram()
+	callee.Op(ops.CALLDATASIZE)
+	callee.Push(0)
+	callee.Push(0)
+	callee.Op(ops.CALLDATACOPY)
+	callee.Op(ops.CALLDATASIZE)
+	callee.Push(0)
+	callee.Op(ops.RETURN)
+	statedb.CreateAccount(echo)
+	statedb.SetCode(echo, callee.Bytecode())
+
+	// The caller returns the returndata of the call
+	p := NewProgram()
+	p.AbiCall(echo, selector, uint256.NewInt(0x2a))
+	p.Op(ops.POP)
+	p.Op(ops.RETURNDATASIZE)
+	p.Push(0)
+	p.Push(0)
+	p.Op(ops.RETURNDATACOPY)
+	p.Op(ops.RETURNDATASIZE)
+	p.Push(0)
+	p.Op(ops.RETURN)
+	ret, _, err := runtime.Execute(p.Bytecode(), nil, &runtime.Config{State: statedb})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := AbiEncode(selector, 0x2a); !bytes.Equal(ret, want) {
+		t.Errorf("got %x expected %x", ret, want)
+	}
+}
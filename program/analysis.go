@@ -0,0 +1,329 @@
+// Copyright 2024 Martin Holst Swende
+// This file is part of the goevmlab library.
+//
+// The library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the goevmlab library. If not, see <http://www.gnu.org/licenses/>.
+
+package program
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/rgeraldes24/goevmlab/ops"
+)
+
+const stackLimit = 1024
+
+var (
+	ErrStackUnderflow = errors.New("stack underflow")
+	ErrStackOverflow  = errors.New("stack overflow")
+	ErrInvalidJump    = errors.New("invalid jump destination")
+)
+
+// isTerminator returns true for ops which halt the execution.
+func isTerminator(op ops.OpCode) bool {
+	switch op {
+	case ops.STOP, ops.RETURN, ops.REVERT, ops.INVALID, ops.SELFDESTRUCT:
+		return true
+	}
+	return false
+}
+
+// Validate checks the stack height along the straight-line path from the
+// start of the program, i.e. until the first halt, falling through any
+// conditional jumps. Jumps to a constant destination, pushed right before the
+// jump, are resolved: an unconditional jump is followed, unless the
+// destination has been visited already, and a destination which is not a
+// JUMPDEST is an error wrapping ErrInvalidJump. The path ends at jumps to
+// other destinations. It returns an error, wrapping ErrStackUnderflow or
+// ErrStackOverflow, for the first op on that path which would fail.
+func (p *Program) Validate() error {
+	var (
+		code    = p.Bytecode()
+		bitmap  = JumpdestBitmap(code)
+		visited = make(map[uint64]bool)
+		height  = 0
+		offset  uint64   // the offset of the code the iterator walks
+		target  *big.Int // the value pushed by the previous op, if any
+	)
+	for it := ops.NewInstructionIterator(code); it.Next(); {
+		var (
+			op = it.Op()
+			pc = offset + it.PC()
+		)
+		if !ops.IsDefined(op) {
+			return nil
+		}
+		if height < len(op.Pops()) {
+			return fmt.Errorf("%w at offset %d: %v with stack height %d", ErrStackUnderflow, pc, op, height)
+		}
+		if height += op.Stackdelta(); height > stackLimit {
+			return fmt.Errorf("%w at offset %d: %v with stack height %d", ErrStackOverflow, pc, op, height-op.Stackdelta())
+		}
+		if (op == ops.JUMP || op == ops.JUMPI) && target != nil {
+			if !target.IsUint64() || !IsJumpdest(bitmap, target.Uint64()) {
+				return fmt.Errorf("%w at offset %d: %v to %#x", ErrInvalidJump, pc, op, target)
+			}
+			if dest := target.Uint64(); op == ops.JUMP && !visited[dest] {
+				visited[dest] = true
+				offset, target = dest, nil
+				it = ops.NewInstructionIterator(code[dest:])
+				continue
+			}
+		}
+		if isTerminator(op) || op == ops.JUMP {
+			return nil
+		}
+		switch {
+		case op == ops.PUSH0:
+			target = new(big.Int)
+		case op.IsPush():
+			target = new(big.Int).SetBytes(it.Arg())
+		default:
+			target = nil
+		}
+	}
+	return nil
+}
+
+// HasObviousInfiniteLoop returns true if the code, when executed from the
+// start, falls through into a loop of the form
+//
+//	JUMPDEST <stack-only ops> PUSH <dest> JUMP
+//
+// which jumps back to its own JUMPDEST, and leaves the stack height unchanged.
+// Such a loop can only be exited by running out of gas.
+//
+// This is a heuristic: it only follows straight-line code from the start, and
+// only considers loop bodies without any side effects, so it misses a lot of
+// loops. It does, however, not report loops which do in fact terminate.
+func HasObviousInfiniteLoop(code []byte) bool {
+	var (
+		it     = ops.NewInstructionIterator(code)
+		height = 0
+	)
+	for it.Next() {
+		op := it.Op()
+		if op == ops.JUMPDEST && isInfiniteLoop(code, it.PC(), height) {
+			return true
+		}
+		if isTerminator(op) || op == ops.JUMP || op == ops.JUMPI {
+			return false
+		}
+		if !ops.IsDefined(op) || height < len(op.Pops()) {
+			return false
+		}
+		if height += op.Stackdelta(); height > stackLimit {
+			return false
+		}
+	}
+	return false
+}
+
+// isInfiniteLoop checks whether the JUMPDEST at dest starts a side-effect
+// free loop back to dest, when entered with the given stack height.
+func isInfiniteLoop(code []byte, dest uint64, height int) bool {
+	var (
+		it    = ops.NewInstructionIterator(code[dest:])
+		start = height
+	)
+	for it.Next() {
+		op := it.Op()
+		if op.IsPush() {
+			// Check for PUSH <dest> JUMP
+			pc := it.PC() + uint64(len(it.Arg())) + 1
+			if int(pc) < len(code[dest:]) && ops.OpCode(code[dest+pc]) == ops.JUMP &&
+				new(big.Int).SetBytes(it.Arg()).Cmp(new(big.Int).SetUint64(dest)) == 0 {
+				return height == start
+			}
+		} else if !isPure(op) {
+			return false
+		}
+		if height < len(op.Pops()) {
+			return false
+		}
+		if height += op.Stackdelta(); height > stackLimit {
+			return false
+		}
+	}
+	return false
+}
+
+// UnreachableCode returns the offsets of the instructions which can never be
+// executed: those following an unconditional halt or jump, up until the
+// next JUMPDEST. Data after a truncated PUSH at the end is not reported.
+func UnreachableCode(code []byte) []int {
+	var (
+		it        = ops.NewInstructionIterator(code)
+		reachable = true
+		offsets   []int
+	)
+	for it.Next() {
+		op := it.Op()
+		if op == ops.JUMPDEST {
+			reachable = true
+		}
+		if !reachable {
+			offsets = append(offsets, int(it.PC()))
+			continue
+		}
+		if isTerminator(op) || op == ops.JUMP || !ops.IsDefined(op) {
+			reachable = false
+		}
+	}
+	return offsets
+}
+
+// JumpdestBitmap returns a bitvector of the valid jump destinations in the
+// code, with the bit for pc set at bitmap[pc/8] & (0x80 >> (pc % 8)). A
+// JUMPDEST byte inside PUSH data is not a valid destination.
+func JumpdestBitmap(code []byte) []byte {
+	bitmap := make([]byte, (len(code)+7)/8)
+	for pc := 0; pc < len(code); pc++ {
+		op := ops.OpCode(code[pc])
+		if op == ops.JUMPDEST {
+			bitmap[pc/8] |= 0x80 >> (pc % 8)
+		} else if op.IsPush() {
+			pc += int(op - ops.PUSH1 + 1)
+		}
+	}
+	return bitmap
+}
+
+// IsJumpdest returns whether pc is set in the bitmap returned by
+// JumpdestBitmap.
+func IsJumpdest(bitmap []byte, pc uint64) bool {
+	if pc/8 >= uint64(len(bitmap)) {
+		return false
+	}
+	return bitmap[pc/8]&(0x80>>(pc%8)) != 0
+}
+
+// isPure returns true for ops which only operate on the stack, cannot
+// halt or branch, and are valid in all supported forks.
+func isPure(op ops.OpCode) bool {
+	switch {
+	case op >= ops.ADD && op <= ops.SIGNEXTEND:
+		return true
+	case op >= ops.LT && op <= ops.SAR:
+		return true
+	case op >= ops.DUP1 && op <= ops.DUP16:
+		return true
+	case op >= ops.SWAP1 && op <= ops.SWAP16:
+		return true
+	}
+	switch op {
+	case ops.ADDRESS, ops.ORIGIN, ops.CALLER, ops.CALLVALUE, ops.CALLDATALOAD,
+		ops.CALLDATASIZE, ops.CODESIZE, ops.GASPRICE, ops.COINBASE,
+		ops.TIMESTAMP, ops.NUMBER, ops.GASLIMIT, ops.POP, ops.PC, ops.MSIZE,
+		ops.GAS, ops.JUMPDEST:
+		return true
+	}
+	return false
+}
+
+// MaxStaticCallDepth estimates the call depth which the code can reach,
+// where a CALL-type op counts as one level, and a CREATE/CREATE2 counts as one
+// level plus the depth of the initcode. The initcode can only be examined if
+// it was placed in memory using constant values (e.g. via Program.Mstore),
+// as is done by Program.CreateAndCall. Otherwise, it counts as one level.
+//
+// The code is scanned linearly, ignoring jumps, and the depth of the code of
+// called contracts is not known, so this is only an estimate.
+func MaxStaticCallDepth(code []byte) int {
+	var (
+		it       = ops.NewInstructionIterator(code)
+		stack    []*big.Int // nil for unknown values
+		mem      = make(map[uint64]byte)
+		maxDepth = 0
+	)
+	pop := func() *big.Int {
+		if len(stack) == 0 {
+			return nil
+		}
+		v := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		return v
+	}
+	isSmall := func(v *big.Int) bool {
+		return v != nil && v.IsUint64() && v.Uint64() < 1<<20
+	}
+	for it.Next() {
+		op := it.Op()
+		switch {
+		case op.IsPush():
+			stack = append(stack, new(big.Int).SetBytes(it.Arg()))
+		case op == ops.PUSH0:
+			stack = append(stack, new(big.Int))
+		case op >= ops.DUP1 && op <= ops.DUP16:
+			n := int(op-ops.DUP1) + 1
+			if n > len(stack) {
+				stack = append(stack, nil)
+			} else {
+				stack = append(stack, stack[len(stack)-n])
+			}
+		case op >= ops.SWAP1 && op <= ops.SWAP16:
+			n := int(op-ops.SWAP1) + 1
+			if n < len(stack) {
+				top := len(stack) - 1
+				stack[top], stack[top-n] = stack[top-n], stack[top]
+			}
+		case op == ops.MSTORE || op == ops.MSTORE8:
+			offset, value := pop(), pop()
+			if !isSmall(offset) {
+				continue
+			}
+			if value == nil {
+				value = new(big.Int)
+			}
+			if op == ops.MSTORE8 {
+				mem[offset.Uint64()] = byte(value.Uint64())
+				continue
+			}
+			word := value.FillBytes(make([]byte, 32))
+			for i, b := range word {
+				mem[offset.Uint64()+uint64(i)] = b
+			}
+		case op == ops.CREATE || op == ops.CREATE2:
+			depth := 1
+			_, offset, size := pop(), pop(), pop()
+			if op == ops.CREATE2 {
+				pop() // salt
+			}
+			if isSmall(offset) && isSmall(size) {
+				initcode := make([]byte, size.Uint64())
+				for i := range initcode {
+					initcode[i] = mem[offset.Uint64()+uint64(i)]
+				}
+				depth += MaxStaticCallDepth(initcode)
+			}
+			if depth > maxDepth {
+				maxDepth = depth
+			}
+			stack = append(stack, nil)
+		default:
+			if op.IsCall() && maxDepth < 1 {
+				maxDepth = 1
+			}
+			for i := 0; i < len(op.Pops()); i++ {
+				pop()
+			}
+			for i := 0; i < len(op.Pushes()); i++ {
+				stack = append(stack, nil)
+			}
+		}
+	}
+	return maxDepth
+}
@@ -0,0 +1,257 @@
+// Copyright 2024 Martin Holst Swende
+// This file is part of the goevmlab library.
+//
+// The library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the goevmlab library. If not, see <http://www.gnu.org/licenses/>.
+
+package program
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/rgeraldes24/goevmlab/ops"
+)
+
+func TestHasObviousInfiniteLoop(t *testing.T) {
+	tests := []struct {
+		name     string
+		build    func(p *Program)
+		expected bool
+	}{
+		{"empty", func(p *Program) {}, false},
+		{"tight loop", func(p *Program) {
+			p.Push(1)
+			loop := p.Jumpdest()
+			p.Op(ops.DUP1)
+			p.Op(ops.ADD)
+			p.Jump(loop)
+		}, true},
+		{"loop after prefix", func(p *Program) {
+			p.Sstore(1, 1)
+			loop := p.Jumpdest()
+			p.Op(ops.GAS)
+			p.Op(ops.POP)
+			p.Jump(loop)
+		}, true},
+		{"finite loop", func(p *Program) {
+			// for i := 10; i != 0; i-- {}
+			p.Push(10)
+			loop := p.Jumpdest()
+			p.Push(1)
+			p.Op(ops.SWAP1)
+			p.Op(ops.SUB)
+			p.Op(ops.DUP1)
+			p.Push(loop)
+			p.Op(ops.JUMPI)
+		}, false},
+		{"stack growth", func(p *Program) {
+			// Overflows the stack after 1024 iterations
+			loop := p.Jumpdest()
+			p.Push(1)
+			p.Jump(loop)
+		}, false},
+		{"stack underflow", func(p *Program) {
+			loop := p.Jumpdest()
+			p.Op(ops.POP)
+			p.Jump(loop)
+		}, false},
+		{"side effect", func(p *Program) {
+			loop := p.Jumpdest()
+			p.Sstore(0, 1)
+			p.Jump(loop)
+		}, false},
+		{"unreachable", func(p *Program) {
+			p.Op(ops.STOP)
+			loop := p.Jumpdest()
+			p.Jump(loop)
+		}, false},
+		{"wrong destination", func(p *Program) {
+			p.Jumpdest()
+			p.Jump(0x10)
+		}, false},
+	}
+	for _, tc := range tests {
+		p := NewProgram()
+		tc.build(p)
+		if got := HasObviousInfiniteLoop(p.Bytecode()); got != tc.expected {
+			t.Errorf("test %v (%x): got %v expected %v", tc.name, p.Bytecode(), got, tc.expected)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	p := NewProgram()
+	for i := 0; i < 1024; i++ {
+		p.Push(1)
+	}
+	if err := p.Validate(); err != nil {
+		t.Fatalf("full stack: %v", err)
+	}
+	p.Push(1) // the 1025th item, at offset 2048
+	err := p.Validate()
+	if !errors.Is(err, ErrStackOverflow) {
+		t.Fatalf("got %v expected %v", err, ErrStackOverflow)
+	}
+	if !strings.Contains(err.Error(), "at offset 2048") {
+		t.Errorf("wrong offset: %v", err)
+	}
+	// Underflow
+	p = NewProgram()
+	p.Push(1)
+	p.Op(ops.ADD)
+	if err := p.Validate(); !errors.Is(err, ErrStackUnderflow) || !strings.Contains(err.Error(), "at offset 2") {
+		t.Errorf("got %v expected %v at offset 2", err, ErrStackUnderflow)
+	}
+	// Code after a halt is not on the path
+	p = NewProgram()
+	p.Stop()
+	p.Op(ops.POP)
+	if err := p.Validate(); err != nil {
+		t.Errorf("got %v expected no error", err)
+	}
+	// Jumps to a constant destination are followed
+	p = NewProgram()
+	p.Jump(4)
+	p.Stop()
+	p.Op(ops.JUMPDEST)
+	p.Op(ops.POP)
+	if err := p.Validate(); !errors.Is(err, ErrStackUnderflow) || !strings.Contains(err.Error(), "at offset 5") {
+		t.Errorf("got %v expected %v at offset 5", err, ErrStackUnderflow)
+	}
+	// A loop ends the path
+	p = NewProgram()
+	loop := p.Label()
+	p.Jumpdest()
+	p.Jump(loop)
+	if err := p.Validate(); err != nil {
+		t.Errorf("got %v expected no error", err)
+	}
+	// The destination must be a JUMPDEST, outside of push data
+	p = NewProgram()
+	p.JumpIf(6, 1)
+	p.Op(ops.PUSH1)
+	p.Op(ops.JUMPDEST)
+	if err := p.Validate(); !errors.Is(err, ErrInvalidJump) {
+		t.Errorf("got %v expected %v", err, ErrInvalidJump)
+	}
+}
+
+func TestMaxStaticCallDepth(t *testing.T) {
+	// inner does a call, and is created by mid, which is created by outer
+	inner := NewProgram()
+	inner.Call(nil, 0x1337, 0, 0, 0, 0, 0)
+	mid := NewProgram()
+	mid.Sstore(0, 1)
+	mid.CreateAndCall(inner.Bytecode(), true, ops.STATICCALL)
+	outer := NewProgram()
+	outer.CreateAndCall(mid.Bytecode(), false, ops.CALL)
+
+	tests := []struct {
+		code     []byte
+		expected int
+	}{
+		{nil, 0},
+		{[]byte{byte(ops.CREATE)}, 1}, // unknown initcode
+		{inner.Bytecode(), 1},
+		{mid.Bytecode(), 2},
+		{outer.Bytecode(), 3},
+	}
+	for i, tc := range tests {
+		if got := MaxStaticCallDepth(tc.code); got != tc.expected {
+			t.Errorf("test %d: got %v expected %v", i, got, tc.expected)
+		}
+	}
+}
+
+func TestJumpdestBitmap(t *testing.T) {
+	p := NewProgram()
+	p.Jumpdest()                                           // 0: valid
+	p.Push([]byte{byte(ops.JUMPDEST), byte(ops.JUMPDEST)}) // 1: PUSH2 5b5b
+	p.Jumpdest()                                           // 4: valid
+	p.Op(ops.PUSH32)                                       // 5: truncated push
+	p.Op(ops.JUMPDEST)                                     // 6: push data
+	code := p.Bytecode()
+	bitmap := JumpdestBitmap(code)
+	if have, want := len(bitmap), 1; have != want {
+		t.Fatalf("got %d bytes expected %d", have, want)
+	}
+	for pc := range code {
+		want := pc == 0 || pc == 4
+		if have := IsJumpdest(bitmap, uint64(pc)); have != want {
+			t.Errorf("pc %d: got %v expected %v", pc, have, want)
+		}
+	}
+	if IsJumpdest(bitmap, 100) {
+		t.Errorf("pc out of bounds marked as jumpdest")
+	}
+}
+
+func BenchmarkJumpdestBitmap(b *testing.B) {
+	p := NewProgram()
+	for p.Size() < 24*1024 {
+		p.Jumpdest()
+		p.Push([]byte{byte(ops.JUMPDEST), 0xff, byte(ops.JUMPDEST)})
+	}
+	code := p.Bytecode()
+	b.SetBytes(int64(len(code)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		JumpdestBitmap(code)
+	}
+}
+
+func TestUnreachableCode(t *testing.T) {
+	tests := []struct {
+		build    func(p *Program)
+		expected []int
+	}{
+		{ // Code after STOP
+			func(p *Program) {
+				p.Push(1)      // 0
+				p.Op(ops.STOP) // 2
+				p.Push(2)      // 3
+				p.Op(ops.POP)  // 5
+			},
+			[]int{3, 5},
+		},
+		{ // A JUMPDEST makes the code reachable again
+			func(p *Program) {
+				p.Jump(4)          // 0
+				p.Op(ops.ADD)      // 3
+				p.Op(ops.JUMPDEST) // 4
+				p.Op(ops.ADD)      // 5
+				p.Return(0, 0)     // 6
+				p.Op(ops.ADD)      // 11
+			},
+			[]int{3, 11},
+		},
+		{ // JUMPI falls through
+			func(p *Program) {
+				p.JumpIf(0, 1)
+				p.Op(ops.ADD)
+			},
+			nil,
+		},
+	}
+	for i, tc := range tests {
+		p := NewProgram()
+		tc.build(p)
+		got := UnreachableCode(p.Bytecode())
+		if fmt.Sprint(got) != fmt.Sprint(tc.expected) {
+			t.Errorf("test %d: got %v expected %v", i, got, tc.expected)
+		}
+	}
+}
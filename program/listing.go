@@ -0,0 +1,101 @@
+// Copyright 2024 Martin Holst Swende
+// This file is part of the goevmlab library.
+//
+// The library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the goevmlab library. If not, see <http://www.gnu.org/licenses/>.
+
+package program
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rgeraldes24/goevmlab/ops"
+)
+
+// Listing returns an assembly listing of the code, where each instruction is
+// annotated with its static gas cost in the given fork. Ops which also have a
+// dynamic cost are marked with "+dyn", and ops which are not valid in the
+// fork are marked "invalid". Example:
+//
+//	00000 PUSH1 0x01                      3
+//	00002 BALANCE                   100+dyn
+func Listing(code []byte, fork ops.Fork) string {
+	return listing(code, fork, nil)
+}
+
+// Listing returns the assembly listing of the program, including the comments
+// added with OpC.
+func (p *Program) Listing(fork ops.Fork) string {
+	return listing(p.Bytecode(), fork, p.comments)
+}
+
+// String returns the disassembly of the program, including the comments added
+// with OpC. Example:
+//
+//	00000 PUSH1 0x01
+//	00002 BALANCE ; check sender
+func (p *Program) String() string {
+	var (
+		b  strings.Builder
+		it = ops.NewInstructionIterator(p.Bytecode())
+	)
+	for it.Next() {
+		fmt.Fprintf(&b, "%05d %v", it.PC(), instruction(it.Op(), it.Arg()))
+		if comment, ok := p.comments[it.PC()]; ok {
+			fmt.Fprintf(&b, " ; %v", comment)
+		}
+		b.WriteString("\n")
+	}
+	if err := it.Error(); err != nil {
+		fmt.Fprintf(&b, "; %v\n", err)
+	}
+	return b.String()
+}
+
+// instruction formats the op along with its immediate argument, if any.
+func instruction(op ops.OpCode, arg []byte) string {
+	if len(arg) > 0 {
+		return fmt.Sprintf("%v 0x%x", op, arg)
+	}
+	return op.String()
+}
+
+// listing is Listing, with the comments keyed by offset.
+func listing(code []byte, fork ops.Fork, comments map[uint64]string) string {
+	var (
+		b  strings.Builder
+		it = ops.NewInstructionIterator(code)
+	)
+	for it.Next() {
+		op := it.Op()
+		var gas string
+		switch cost, dynamic := fork.StaticGas(op); {
+		case !fork.IsValid(op):
+			gas = "invalid"
+		case dynamic:
+			gas = fmt.Sprintf("%d+dyn", cost)
+		default:
+			gas = fmt.Sprintf("%d", cost)
+		}
+		fmt.Fprintf(&b, "%05d %-25s %7s", it.PC(), instruction(it.Op(), it.Arg()), gas)
+		if comment, ok := comments[it.PC()]; ok {
+			fmt.Fprintf(&b, " ; %v", comment)
+		}
+		b.WriteString("\n")
+	}
+	if err := it.Error(); err != nil {
+		fmt.Fprintf(&b, "; %v\n", err)
+	}
+	return b.String()
+}
@@ -0,0 +1,90 @@
+// Copyright 2024 Martin Holst Swende
+// This file is part of the goevmlab library.
+//
+// The library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the goevmlab library. If not, see <http://www.gnu.org/licenses/>.
+
+package program
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/rgeraldes24/goevmlab/ops"
+)
+
+func TestListing(t *testing.T) {
+	p := NewProgram()
+	p.Call(big.NewInt(0xffff), 0xc0de, 0, 0, 0, 0, 0)
+	p.Op(ops.POP)
+	p.Op(ops.TLOAD)
+	p.Op(ops.PUSH2)
+
+	listing := Listing(p.Bytecode(), *ops.LookupFork("Shanghai"))
+	lines := strings.Split(strings.TrimSpace(listing), "\n")
+	want := []string{
+		"00000 PUSH1 0x00                      3",
+		"00002 PUSH1 0x00                      3",
+		"00004 PUSH1 0x00                      3",
+		"00006 PUSH1 0x00                      3",
+		"00008 PUSH1 0x00                      3",
+		"00010 PUSH2 0xc0de                    3",
+		"00013 PUSH2 0xffff                    3",
+		"00016 CALL                      100+dyn",
+		"00017 POP                             2",
+		"00018 TLOAD                     invalid",
+		"; incomplete push instruction at 19",
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("got %d lines expected %d:\n%v", len(lines), len(want), listing)
+	}
+	for i := range want {
+		if lines[i] != want[i] {
+			t.Errorf("line %d: got %q expected %q", i, lines[i], want[i])
+		}
+	}
+	// Before Berlin, CALL had a higher static cost
+	listing = Listing([]byte{byte(ops.CALL)}, *ops.LookupFork("Istanbul"))
+	if want := "00000 CALL                      700+dyn\n"; listing != want {
+		t.Errorf("got %q expected %q", listing, want)
+	}
+}
+
+func TestComments(t *testing.T) {
+	p := NewProgram()
+	p.Push(0)
+	p.Push(0xc0de)
+	p.Op(ops.GAS)
+	p.OpC(ops.CALL, "call the target")
+	p.Op(ops.POP)
+
+	want := []string{
+		"00000 PUSH1 0x00",
+		"00002 PUSH2 0xc0de",
+		"00005 GAS",
+		"00006 CALL ; call the target",
+		"00007 POP",
+	}
+	if have := strings.Split(strings.TrimSpace(p.String()), "\n"); strings.Join(have, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got\n%v\nexpected\n%v", p.String(), strings.Join(want, "\n"))
+	}
+	listing := p.Listing(*ops.LookupFork("Shanghai"))
+	if want := "00006 CALL                      100+dyn ; call the target\n"; !strings.Contains(listing, want) {
+		t.Errorf("got\n%v\nexpected line %q", listing, want)
+	}
+	// The comments are not part of the code
+	if have, want := p.Hex(), "600061c0de5af150"; have != want {
+		t.Errorf("got %v expected %v", have, want)
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright 2024 Martin Holst Swende
+// This file is part of the goevmlab library.
+//
+// The library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the goevmlab library. If not, see <http://www.gnu.org/licenses/>.
+
+package program
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+
+	"github.com/rgeraldes24/goevmlab/ops"
+)
+
+// packMagic identifies a packed program, followed by the format version.
+var packMagic = []byte("gevm\x01")
+
+// Pack encodes the code along with the fork it targets, in a self-describing
+// format:
+//
+//	magic || version || len(fork) (1 byte) || fork || len(code) (4 bytes) || code || crc32
+//
+// where the CRC (IEEE) covers all the preceding bytes.
+func Pack(code []byte, fork ops.Fork) []byte {
+	data := append([]byte{}, packMagic...)
+	data = append(data, byte(len(fork.Name)))
+	data = append(data, fork.Name...)
+	data = binary.BigEndian.AppendUint32(data, uint32(len(code)))
+	data = append(data, code...)
+	return binary.BigEndian.AppendUint32(data, crc32.ChecksumIEEE(data))
+}
+
+// Unpack decodes a program encoded by Pack, and validates its CRC.
+func Unpack(data []byte) (code []byte, fork ops.Fork, err error) {
+	if len(data) < len(packMagic)+1+4+4 || !bytes.HasPrefix(data, packMagic) {
+		return nil, fork, errors.New("not a packed program")
+	}
+	body, sum := data[:len(data)-4], binary.BigEndian.Uint32(data[len(data)-4:])
+	if have := crc32.ChecksumIEEE(body); have != sum {
+		return nil, fork, fmt.Errorf("crc mismatch: have %08x, want %08x", have, sum)
+	}
+	rest := body[len(packMagic):]
+	nameLen := int(rest[0])
+	if len(rest) < 1+nameLen+4 {
+		return nil, fork, errors.New("truncated header")
+	}
+	name := string(rest[1 : 1+nameLen])
+	rest = rest[1+nameLen:]
+	if codeLen := binary.BigEndian.Uint32(rest); int(codeLen) != len(rest)-4 {
+		return nil, fork, fmt.Errorf("code length mismatch: have %d, want %d", len(rest)-4, codeLen)
+	}
+	f := ops.LookupFork(name)
+	if f == nil {
+		return nil, fork, fmt.Errorf("unknown fork %q", name)
+	}
+	return rest[4:], *f, nil
+}
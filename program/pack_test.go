@@ -0,0 +1,54 @@
+// Copyright 2024 Martin Holst Swende
+// This file is part of the goevmlab library.
+//
+// The library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the goevmlab library. If not, see <http://www.gnu.org/licenses/>.
+
+package program
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/rgeraldes24/goevmlab/ops"
+)
+
+func TestPack(t *testing.T) {
+	p := NewProgram()
+	p.Sstore(0, 1)
+	p.Finish()
+	fork := *ops.LookupFork("Shanghai")
+
+	packed := Pack(p.Bytecode(), fork)
+	code, have, err := Unpack(packed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(code, p.Bytecode()) {
+		t.Errorf("got %x expected %x", code, p.Bytecode())
+	}
+	if have.Name != fork.Name {
+		t.Errorf("got %v expected %v", have.Name, fork.Name)
+	}
+	// Any corrupted byte should be detected
+	for i := range packed {
+		corrupt := bytes.Clone(packed)
+		corrupt[i] ^= 0x01
+		if _, _, err := Unpack(corrupt); err == nil {
+			t.Errorf("byte %d: expected error", i)
+		}
+	}
+	if _, _, err := Unpack(packed[:len(packed)-1]); err == nil {
+		t.Errorf("truncated: expected error")
+	}
+}
@@ -0,0 +1,48 @@
+// Copyright 2024 Martin Holst Swende
+// This file is part of the goevmlab library.
+//
+// The library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the goevmlab library. If not, see <http://www.gnu.org/licenses/>.
+
+package program
+
+import (
+	"encoding/binary"
+)
+
+// ModexpAddr is the address of the modexp precompile.
+const ModexpAddr = 0x05
+
+// ModexpInput returns the input for the modexp precompile: the lengths of
+// base, exponent and modulus, as 32-byte words, followed by the values.
+func ModexpInput(base, exp, mod []byte) []byte {
+	input := make([]byte, 0, 96+len(base)+len(exp)+len(mod))
+	for _, v := range [][]byte{base, exp, mod} {
+		var word [32]byte
+		binary.BigEndian.PutUint64(word[24:], uint64(len(v)))
+		input = append(input, word[:]...)
+	}
+	input = append(input, base...)
+	input = append(input, exp...)
+	input = append(input, mod...)
+	return input
+}
+
+// CallModexp places the modexp input in memory, and does a STATICCALL to the
+// modexp precompile, with all gas. The result (len(mod) bytes) is written to
+// memory at offset 0, and the success flag is left on the stack.
+func (p *Program) CallModexp(base, exp, mod []byte) {
+	input := ModexpInput(base, exp, mod)
+	p.Mstore(input, 0)
+	p.StaticCall(nil, ModexpAddr, 0, len(input), 0, len(mod))
+}
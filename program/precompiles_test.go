@@ -0,0 +1,53 @@
+// Copyright 2024 Martin Holst Swende
+// This file is part of the goevmlab library.
+//
+// The library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the goevmlab library. If not, see <http://www.gnu.org/licenses/>.
+
+package program
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/rgeraldes24/goevmlab/ops"
+	"github.com/theQRL/go-zond/common/hexutil"
+	"github.com/theQRL/go-zond/core/vm/runtime"
+)
+
+func TestModexpInput(t *testing.T) {
+	have := hexutil.Encode(ModexpInput([]byte{3}, []byte{5}, []byte{0, 7}))
+	want := "0x" +
+		"0000000000000000000000000000000000000000000000000000000000000001" +
+		"0000000000000000000000000000000000000000000000000000000000000001" +
+		"0000000000000000000000000000000000000000000000000000000000000002" +
+		"03" + "05" + "0007"
+	if have != want {
+		t.Errorf("got %v expected %v", have, want)
+	}
+}
+
+func TestCallModexp(t *testing.T) {
+	// 3 ** 5 % 7 = 243 % 7 = 5
+	p := NewProgram()
+	p.CallModexp([]byte{3}, []byte{5}, []byte{0, 7})
+	p.Op(ops.POP)
+	p.Return(0, 2)
+	ret, _, err := runtime.Execute(p.Bytecode(), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []byte{0, 5}; !bytes.Equal(ret, want) {
+		t.Errorf("got %x expected %x", ret, want)
+	}
+}
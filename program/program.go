@@ -17,17 +17,33 @@
 package program
 
 import (
+	"encoding/binary"
 	"fmt"
 	"math/big"
+	"sort"
 
 	"github.com/holiman/uint256"
 	"github.com/rgeraldes24/goevmlab/ops"
 	"github.com/theQRL/go-zond/common"
+	"github.com/theQRL/go-zond/common/math"
 	"github.com/theQRL/go-zond/core/vm"
+	"github.com/theQRL/go-zond/crypto"
 )
 
 type Program struct {
-	code []byte
+	code   []byte
+	labels map[string]uint64 // named jumpdests
+	fixups []labelRef        // label references to resolve
+	nlabel int               // the number of generated labels
+	fork   string            // the targeted fork, if known
+	// comments on instructions, by offset, shown in the disassembly
+	comments map[uint64]string
+}
+
+// labelRef is a PUSH2 placeholder at pos, for the location of a label.
+type labelRef struct {
+	pos   int
+	label string
 }
 
 func NewProgram() *Program {
@@ -37,6 +53,18 @@ func NewProgram() *Program {
 	return p
 }
 
+// WithState returns a program with a prologue which stores memory at offset
+// zero and pushes the stack, given with the top item first. Execution of the
+// ops added to the program starts with exactly that stack and memory.
+func WithState(stack []*big.Int, memory []byte) *Program {
+	p := NewProgram()
+	p.Mstore(memory, 0)
+	for i := len(stack) - 1; i >= 0; i-- {
+		p.Push(stack[i])
+	}
+	return p
+}
+
 func (p *Program) add(op byte) {
 	p.code = append(p.code, op)
 }
@@ -58,6 +86,13 @@ func (p *Program) pushBig(val *big.Int) {
 
 }
 
+// WithFork sets the name of the fork which the program targets, allowing
+// helpers such as Zero to use the ops available in it.
+func (p *Program) WithFork(fork string) *Program {
+	p.fork = fork
+	return p
+}
+
 // AddAll adds the data to the Program
 func (p *Program) AddAll(data []byte) {
 	p.code = append(p.code, data...)
@@ -68,39 +103,121 @@ func (p *Program) Op(op ops.OpCode) {
 	p.add(byte(op))
 }
 
+// Undefined appends the byte b, which must not be a defined opcode, for
+// exercising the invalid opcode path. It panics if b is a defined opcode.
+func (p *Program) Undefined(b byte) *Program {
+	if ops.IsDefined(ops.OpCode(b)) {
+		panic(fmt.Sprintf("opcode %v is defined", ops.OpCode(b)))
+	}
+	p.add(b)
+	return p
+}
+
+// OpC appends the op, annotated with a comment which is shown in the
+// disassembly (String) and Listing of the program.
+func (p *Program) OpC(op ops.OpCode, comment string) *Program {
+	if p.comments == nil {
+		p.comments = make(map[uint64]string)
+	}
+	p.comments[uint64(len(p.code))] = comment
+	p.Op(op)
+	return p
+}
+
 // Push creates a PUSHX instruction with the data provided
 func (p *Program) Push(val interface{}) *Program {
+	p.pushBig(toBig(val))
+	return p
+}
+
+// PushN creates a PUSHN instruction, where the value is left-padded with zeros
+// to n bytes, e.g. PushN(4, 0x42) gives PUSH4 0x00000042. It panics if the value
+// does not fit in n bytes.
+func (p *Program) PushN(n int, val interface{}) *Program {
+	if n < 1 || n > 32 {
+		panic(fmt.Sprintf("invalid push size %d", n))
+	}
+	v := toBig(val)
+	if v == nil {
+		v = new(big.Int)
+	}
+	if len(v.Bytes()) > n {
+		panic(fmt.Sprintf("Push value too large, %d bytes, expected at most %d", len(v.Bytes()), n))
+	}
+	p.add(byte(vm.PUSH1) - 1 + byte(n))
+	p.AddAll(v.FillBytes(make([]byte, n)))
+	return p
+}
+
+// PushSigned pushes a signed value, encoding negative values as 32-byte two's
+// complement words, as used by SDIV, SMOD, SLT and SGT. It panics if the value
+// does not fit in 256 bits.
+func (p *Program) PushSigned(v *big.Int) *Program {
+	u := math.U256(new(big.Int).Set(v))
+	if math.S256(new(big.Int).Set(u)).Cmp(v) != 0 {
+		panic(fmt.Sprintf("Signed value out of range: %v", v))
+	}
+	p.pushBig(u)
+	return p
+}
+
+// toBig converts a value given to Push into a big.Int.
+func toBig(val interface{}) *big.Int {
 	switch v := val.(type) {
 	case int:
-		p.pushBig(new(big.Int).SetUint64(uint64(v)))
+		return new(big.Int).SetUint64(uint64(v))
 	case uint64:
-		p.pushBig(new(big.Int).SetUint64(v))
+		return new(big.Int).SetUint64(v)
 	case uint32:
-		p.pushBig(new(big.Int).SetUint64(uint64(v)))
+		return new(big.Int).SetUint64(uint64(v))
 	case *big.Int:
-		p.pushBig(v)
+		return v
 	case *uint256.Int:
-		p.pushBig(v.ToBig())
+		return v.ToBig()
 	case uint256.Int:
-		p.pushBig(v.ToBig())
+		return v.ToBig()
 	case common.Address:
-		p.pushBig(new(big.Int).SetBytes(v.Bytes()))
+		return new(big.Int).SetBytes(v.Bytes())
 	case *common.Address:
-		p.pushBig(new(big.Int).SetBytes(v.Bytes()))
+		return new(big.Int).SetBytes(v.Bytes())
 	case []byte:
-		p.pushBig(new(big.Int).SetBytes(v))
+		return new(big.Int).SetBytes(v)
 	case byte:
-		p.pushBig(new(big.Int).SetUint64(uint64(v)))
+		return new(big.Int).SetUint64(uint64(v))
 	case nil:
-		p.pushBig(nil)
+		return nil
 	default:
 		panic(fmt.Sprintf("unsupported type %v", v))
 	}
+}
+
+// PushAddress pushes the address as a full-width PUSH20, keeping leading zeros.
+func (p *Program) PushAddress(addr common.Address) *Program {
+	p.Op(ops.PUSH20)
+	p.AddAll(addr.Bytes())
 	return p
 }
 
-// Bytecode returns the Program bytecode
+// PushHash pushes the hash as a full-width PUSH32, keeping leading zeros.
+func (p *Program) PushHash(hash common.Hash) *Program {
+	p.Op(ops.PUSH32)
+	p.AddAll(hash.Bytes())
+	return p
+}
+
+// Bytecode returns the Program bytecode. Any references to named jumpdests are
+// resolved, it panics if a label is not defined.
 func (p *Program) Bytecode() []byte {
+	for _, ref := range p.fixups {
+		loc, ok := p.labels[ref.label]
+		if !ok {
+			panic(fmt.Sprintf("undefined label %q", ref.label))
+		}
+		if loc > math.MaxUint16 {
+			panic(fmt.Sprintf("label %q at offset %d does not fit in PUSH2", ref.label, loc))
+		}
+		binary.BigEndian.PutUint16(p.code[ref.pos:], uint16(loc))
+	}
 	return p.code
 }
 
@@ -109,6 +226,88 @@ func (p *Program) Hex() string {
 	return fmt.Sprintf("%02x", p.Bytecode())
 }
 
+// CodeCopy copies length bytes of the executing code, starting at codeOffset,
+// into memory at memOffset.
+func (p *Program) CodeCopy(memOffset, codeOffset, length interface{}) {
+	p.Push(length)
+	p.Push(codeOffset)
+	p.Push(memOffset)
+	p.Op(ops.CODECOPY)
+}
+
+// opWithOperands pushes the operands, last one first, so that the first
+// operand ends up on top of the stack, and then adds the op.
+func (p *Program) opWithOperands(op ops.OpCode, operands []interface{}) *Program {
+	for i := len(operands) - 1; i >= 0; i-- {
+		p.Push(operands[i])
+	}
+	p.Op(op)
+	return p
+}
+
+// Add adds an ADD, after pushing the given operands (if any). Any operands
+// not given are taken from the stack, e.g. Add(1) adds one to the stack top.
+func (p *Program) Add(operands ...interface{}) *Program {
+	return p.opWithOperands(ops.ADD, operands)
+}
+
+// Sub adds a SUB, after pushing the given operands (if any): Sub(a, b) = a - b.
+func (p *Program) Sub(operands ...interface{}) *Program {
+	return p.opWithOperands(ops.SUB, operands)
+}
+
+// Mul adds a MUL, after pushing the given operands (if any).
+func (p *Program) Mul(operands ...interface{}) *Program {
+	return p.opWithOperands(ops.MUL, operands)
+}
+
+// Div adds a DIV, after pushing the given operands (if any): Div(a, b) = a / b.
+func (p *Program) Div(operands ...interface{}) *Program {
+	return p.opWithOperands(ops.DIV, operands)
+}
+
+// And adds an AND, after pushing the given operands (if any).
+func (p *Program) And(operands ...interface{}) *Program {
+	return p.opWithOperands(ops.AND, operands)
+}
+
+// Or adds an OR, after pushing the given operands (if any).
+func (p *Program) Or(operands ...interface{}) *Program {
+	return p.opWithOperands(ops.OR, operands)
+}
+
+// Xor adds a XOR, after pushing the given operands (if any).
+func (p *Program) Xor(operands ...interface{}) *Program {
+	return p.opWithOperands(ops.XOR, operands)
+}
+
+// Not adds a NOT, after pushing the given operand (if any).
+func (p *Program) Not(operands ...interface{}) *Program {
+	return p.opWithOperands(ops.NOT, operands)
+}
+
+// Lt adds a LT, after pushing the given operands (if any): Lt(a, b) = a < b.
+func (p *Program) Lt(operands ...interface{}) *Program {
+	return p.opWithOperands(ops.LT, operands)
+}
+
+// Gt adds a GT, after pushing the given operands (if any): Gt(a, b) = a > b.
+func (p *Program) Gt(operands ...interface{}) *Program {
+	return p.opWithOperands(ops.GT, operands)
+}
+
+// Eq adds an EQ, after pushing the given operands (if any).
+func (p *Program) Eq(operands ...interface{}) *Program {
+	return p.opWithOperands(ops.EQ, operands)
+}
+
+// IsZero adds an ISZERO, after pushing the given operand (if any).
+func (p *Program) IsZero(operands ...interface{}) *Program {
+	return p.opWithOperands(ops.ISZERO, operands)
+}
+
+// ExtcodeCopy copies length bytes of the code at address, starting at
+// codeOffset, into memory at memOffset.
 func (p *Program) ExtcodeCopy(address, memOffset, codeOffset, length interface{}) {
 	p.Push(length)
 	p.Push(codeOffset)
@@ -117,6 +316,21 @@ func (p *Program) ExtcodeCopy(address, memOffset, codeOffset, length interface{}
 	p.Op(ops.EXTCODECOPY)
 }
 
+// Balance adds a BALANCE of the given address.
+func (p *Program) Balance(address interface{}) *Program {
+	return p.opWithOperands(ops.BALANCE, []interface{}{address})
+}
+
+// ExtCodeSize adds an EXTCODESIZE of the given address.
+func (p *Program) ExtCodeSize(address interface{}) *Program {
+	return p.opWithOperands(ops.EXTCODESIZE, []interface{}{address})
+}
+
+// ExtCodeHash adds an EXTCODEHASH of the given address.
+func (p *Program) ExtCodeHash(address interface{}) *Program {
+	return p.opWithOperands(ops.EXTCODEHASH, []interface{}{address})
+}
+
 // Call is a convenience function to make a call
 func (p *Program) Call(gas *big.Int, address, value, inOffset, inSize, outOffset, outSize interface{}) {
 	p.Push(outSize)
@@ -190,23 +404,167 @@ func (p *Program) Jumpdest() uint64 {
 	return here
 }
 
-// Jump pushes the destination and adds a JUMP
+// NamedJumpdest adds a JUMPDEST op, which can be jumped to by name, and
+// returns the PC of that instruction.
+func (p *Program) NamedJumpdest(label string) uint64 {
+	if _, exist := p.labels[label]; exist {
+		panic(fmt.Sprintf("label %q already defined", label))
+	}
+	if p.labels == nil {
+		p.labels = make(map[string]uint64)
+	}
+	here := p.Jumpdest()
+	p.labels[label] = here
+	return here
+}
+
+// newLabel returns a name for an internal jumpdest, which is unique within
+// the program.
+func (p *Program) newLabel() string {
+	p.nlabel++
+	return fmt.Sprintf(".L%d", p.nlabel)
+}
+
+// pushLocation pushes the jump destination, which is either an offset or
+// the name of a jumpdest. Named jumpdests may be defined later on, so they
+// are pushed as a PUSH2 placeholder, resolved by Bytecode.
+func (p *Program) pushLocation(loc interface{}) {
+	label, ok := loc.(string)
+	if !ok {
+		p.Push(loc)
+		return
+	}
+	p.Op(ops.PUSH2)
+	p.fixups = append(p.fixups, labelRef{len(p.code), label})
+	p.AddAll([]byte{0, 0})
+}
+
+// PushLabelOffset pushes the offset of the named jumpdest as data, e.g. to
+// store it and jump to it later on. The jumpdest may be defined later.
+func (p *Program) PushLabelOffset(name string) *Program {
+	p.pushLocation(name)
+	return p
+}
+
+// Jump pushes the destination and adds a JUMP. The destination is either an
+// offset, or the name of a jumpdest (see NamedJumpdest).
 func (p *Program) Jump(loc interface{}) {
-	p.Push(loc)
+	p.pushLocation(loc)
 	p.Op(ops.JUMP)
 }
 
-// Jump pushes the destination and adds a JUMP
+// JumpIf pushes the condition and destination, and adds a JUMPI. The
+// destination is either an offset, or the name of a jumpdest.
 func (p *Program) JumpIf(loc interface{}, condition interface{}) {
 	p.Push(condition)
-	p.Push(loc)
+	p.pushLocation(loc)
 	p.Op(ops.JUMPI)
 }
 
+// Switch dispatches on the selector: the body of the case whose key equals
+// the selector is executed, or def if there is none. If the selector is nil,
+// it is taken (and popped) from the stack. The dispatch is a ladder of
+// comparisons, in the order of the keys. After the body, the execution
+// continues after the switch. A nil def is an empty default.
+func (p *Program) Switch(selector interface{}, cases map[uint64]func(*Program), def func(*Program)) *Program {
+	keys := make([]uint64, 0, len(cases))
+	for key := range cases {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	var (
+		caseLabels = make([]string, len(keys))
+		end        = p.newLabel()
+	)
+	for i, key := range keys {
+		if selector == nil {
+			p.Op(ops.DUP1)
+		} else {
+			p.Push(selector)
+		}
+		p.Push(key)
+		p.Op(ops.EQ)
+		caseLabels[i] = p.newLabel()
+		p.pushLocation(caseLabels[i])
+		p.Op(ops.JUMPI)
+	}
+	body := func(fn func(*Program)) {
+		if selector == nil {
+			p.Op(ops.POP)
+		}
+		if fn != nil {
+			fn(p)
+		}
+		p.Jump(end)
+	}
+	body(def)
+	for i, key := range keys {
+		p.NamedJumpdest(caseLabels[i])
+		body(cases[key])
+	}
+	p.NamedJumpdest(end)
+	return p
+}
+
 func (p *Program) Size() int {
 	return len(p.code)
 }
 
+// CurrentOffset returns the offset at which the next op will be placed.
+func (p *Program) CurrentOffset() int {
+	return len(p.code)
+}
+
+// Zero puts a zero on the stack, as cheaply as possible: PUSH0 if the fork of
+// the program has it, PC if the op is at offset 0, and PUSH1 0x00 otherwise.
+func (p *Program) Zero() *Program {
+	if fork := ops.LookupFork(p.fork); fork != nil && fork.IsValid(ops.PUSH0) {
+		p.Op(ops.PUSH0)
+	} else if len(p.code) == 0 {
+		p.Op(ops.PC)
+	} else {
+		p.Push(0)
+	}
+	return p
+}
+
+// AssertionFailed is the revert data (as a 32-byte word) of a failed AssertEq.
+const AssertionFailed = 0xa55e7f1d
+
+// AssertEq pops the top of the stack and compares it to expected. On a
+// mismatch, the execution reverts with AssertionFailed as the revert data.
+func (p *Program) AssertEq(expected interface{}) *Program {
+	p.Push(expected)
+	p.Op(ops.EQ)
+	// The destination is known once the failure branch is emitted
+	p.Op(ops.PUSH2)
+	dest := len(p.code)
+	p.AddAll([]byte{0, 0})
+	p.Op(ops.JUMPI)
+	p.Push(AssertionFailed)
+	p.Push(0)
+	p.Op(ops.MSTORE)
+	p.Push(32)
+	p.Push(0)
+	p.Op(ops.REVERT)
+	loc := p.Jumpdest()
+	if loc > math.MaxUint16 {
+		panic(fmt.Sprintf("assertion jumpdest at offset %d does not fit in PUSH2", loc))
+	}
+	binary.BigEndian.PutUint16(p.code[dest:], uint16(loc))
+	return p
+}
+
+// Keccak puts the keccak256 hash of the given data on the stack, by storing
+// the data in memory at offset 0 and hashing it there.
+func (p *Program) Keccak(data []byte) *Program {
+	p.Mstore(data, 0)
+	p.Push(len(data))
+	p.Push(0)
+	p.Op(ops.KECCAK256)
+	return p
+}
+
 // InputToMemory stores the input (calldata) to memory as address (20 bytes).
 func (p *Program) InputAddressToStack(inputOffset uint32) {
 	p.Push(inputOffset)
@@ -324,11 +682,176 @@ func (p *Program) CreateAndCall(code []byte, isCreate2 bool, callOp ops.OpCode)
 	p.Op(ops.POP) // pop the address
 }
 
+// Initcode returns initcode which deploys the runtime code. The runtime code
+// is appended to the initcode, which copies it into memory with CODECOPY,
+// instead of pushing it a word at a time.
+func Initcode(runtime []byte) []byte {
+	var (
+		p      *Program
+		offset = 0
+	)
+	// The offset of the runtime code is the size of the prefix, which in turn
+	// depends on the size of the push of the offset.
+	for {
+		p = NewProgram()
+		p.CodeCopy(0, offset, len(runtime))
+		p.Return(0, uint32(len(runtime)))
+		if p.Size() == offset {
+			break
+		}
+		offset = p.Size()
+	}
+	return append(p.Bytecode(), runtime...)
+}
+
+// Create deploys the runtime code using CREATE, with the initcode from
+// Initcode, and leaves the address of the new contract (or zero if the
+// deployment failed) on the stack. Memory from offset 0 is overwritten.
+func (p *Program) Create(runtime []byte) *Program {
+	initcode := Initcode(runtime)
+	p.Mstore(initcode, 0)
+	p.Push(len(initcode)).Push(0).Push(0).Op(ops.CREATE)
+	return p
+}
+
+// CreateAndVerify deploys the runtime code using CREATE, and asserts (see
+// AssertEq) that the EXTCODEHASH of the new contract is the hash of the
+// runtime code. On success, the address of the new contract is left on the
+// stack.
+func (p *Program) CreateAndVerify(runtime []byte) *Program {
+	return p.createAndVerify(runtime, crypto.Keccak256Hash(runtime))
+}
+
+func (p *Program) createAndVerify(runtime []byte, expected common.Hash) *Program {
+	p.Create(runtime)
+	p.Op(ops.DUP1)
+	p.Op(ops.EXTCODEHASH)
+	return p.AssertEq(expected.Bytes())
+}
+
+// Create2Address puts the address at which CREATE2, executed by the current
+// contract with the given salt and initcode, deploys the contract on the
+// stack. The initcode is either a []byte or a *Program, and is hashed in
+// memory. Memory from offset 0 up to the larger of 96 and the size of the
+// initcode is overwritten.
+func (p *Program) Create2Address(salt, initcode interface{}) *Program {
+	switch code := initcode.(type) {
+	case []byte:
+		p.Keccak(code)
+	case *Program:
+		p.Keccak(code.Bytecode())
+	default:
+		panic(fmt.Sprintf("unsupported initcode type %T", initcode))
+	}
+	// keccak256(0xff ++ address ++ salt ++ keccak256(initcode)), with the
+	// 0xff at offset 11 and the address right-aligned up to offset 32
+	p.Push(64)
+	p.Op(ops.MSTORE)
+	p.Push(salt)
+	p.Push(32)
+	p.Op(ops.MSTORE)
+	p.Op(ops.ADDRESS)
+	p.Push(0)
+	p.Op(ops.MSTORE)
+	p.Push(0xff)
+	p.Push(11)
+	p.Op(ops.MSTORE8)
+	p.Push(85)
+	p.Push(11)
+	p.Op(ops.KECCAK256)
+	p.Push(new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 160), big.NewInt(1)))
+	p.Op(ops.AND)
+	return p
+}
+
+// Stop implements STOP (0x00)
+func (p *Program) Stop() {
+	p.Op(ops.STOP)
+}
+
+// Invalid implements INVALID (0xfe)
+func (p *Program) Invalid() {
+	p.Op(ops.INVALID)
+}
+
+// Finish adds a STOP, unless the last instruction of the program already
+// terminates the execution (STOP, RETURN, REVERT, INVALID or SELFDESTRUCT).
+// This makes the end of the program explicit, instead of relying on the
+// implicit STOP after the end of the code.
+func (p *Program) Finish() {
+	var (
+		it         = ops.NewInstructionIterator(p.code)
+		terminated = false
+	)
+	for it.Next() {
+		terminated = isTerminator(it.Op())
+	}
+	// An incomplete push at the end does not terminate
+	if terminated && it.Error() == nil {
+		return
+	}
+	p.Stop()
+}
+
 // Push0 implements PUSH0 (0x5f)
 func (p *Program) Push0() {
 	p.Op(ops.PUSH0)
 }
 
+// ChainId implements CHAINID (0x46)
+func (p *Program) ChainId() {
+	p.Op(ops.CHAINID)
+}
+
+// SelfBalance implements SELFBALANCE (0x47)
+func (p *Program) SelfBalance() {
+	p.Op(ops.SELFBALANCE)
+}
+
+// PrevRandao implements PREVRANDAO (0x44), formerly DIFFICULTY
+func (p *Program) PrevRandao() {
+	p.Op(ops.DIFFICULTY)
+}
+
+// Gas implements GAS (0x5a)
+func (p *Program) Gas() {
+	p.Op(ops.GAS)
+}
+
+// GasPrice implements GASPRICE (0x3a)
+func (p *Program) GasPrice() {
+	p.Op(ops.GASPRICE)
+}
+
+// Origin implements ORIGIN (0x32)
+func (p *Program) Origin() {
+	p.Op(ops.ORIGIN)
+}
+
+// Caller implements CALLER (0x33)
+func (p *Program) Caller() {
+	p.Op(ops.CALLER)
+}
+
+// CallValue implements CALLVALUE (0x34)
+func (p *Program) CallValue() {
+	p.Op(ops.CALLVALUE)
+}
+
+// ReturnDataSize implements RETURNDATASIZE (0x3d)
+func (p *Program) ReturnDataSize() {
+	p.Op(ops.RETURNDATASIZE)
+}
+
+// ReturnDataCopy copies size bytes of the returndata of the last call, starting
+// at offset, into memory at destOffset.
+func (p *Program) ReturnDataCopy(destOffset, offset, size interface{}) {
+	p.Push(size)
+	p.Push(offset)
+	p.Push(destOffset)
+	p.Op(ops.RETURNDATACOPY)
+}
+
 // RJump implements RJUMP (0x5c) - relative jump
 func (p *Program) RJump(relOffset uint16) {
 	panic("Need RJUMP defined")
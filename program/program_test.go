@@ -17,11 +17,20 @@
 package program
 
 import (
+	"bytes"
+	"errors"
 	"math/big"
+	"strings"
 	"testing"
 
 	"github.com/rgeraldes24/goevmlab/ops"
 	"github.com/theQRL/go-zond/common"
+	"github.com/theQRL/go-zond/core/rawdb"
+	"github.com/theQRL/go-zond/core/state"
+	"github.com/theQRL/go-zond/core/vm"
+	"github.com/theQRL/go-zond/core/vm/runtime"
+	"github.com/theQRL/go-zond/crypto"
+	"github.com/theQRL/go-zond/params"
 )
 
 func TestPush(t *testing.T) {
@@ -192,3 +201,661 @@ func TestCreateAndCall(t *testing.T) {
 	}
 
 }
+
+func TestCodeCopy(t *testing.T) {
+	p := NewProgram()
+	p.CodeCopy(0, 0, 4)
+	p.Return(0, 4)
+	if exp, got := "60046000600039"+"60046000f3", p.Hex(); got != exp {
+		t.Fatalf("got %v expected %v", got, exp)
+	}
+	ret, _, err := runtime.Execute(p.Bytecode(), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := p.Bytecode()[:4]; !bytes.Equal(ret, exp) {
+		t.Errorf("got %x expected %x", ret, exp)
+	}
+}
+
+func TestExtcodeCopy(t *testing.T) {
+	p := NewProgram()
+	p.ExtcodeCopy(common.HexToAddress("0x1337"), 1, 2, 3)
+	if exp, got := "600360026001611337"+"3c", p.Hex(); got != exp {
+		t.Errorf("got %v expected %v", got, exp)
+	}
+}
+
+// newState returns an empty in-memory state.
+func newState() *state.StateDB {
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	return statedb
+}
+
+// runCode sets the code at addr in the state of cfg, and calls it with the
+// given input. If cfg has no state, an empty one is used, which is returned.
+func runCode(addr common.Address, code, input []byte, cfg *runtime.Config) (*state.StateDB, []byte, error) {
+	if cfg.State == nil {
+		cfg.State = newState()
+	}
+	cfg.State.CreateAccount(addr)
+	cfg.State.SetCode(addr, code)
+	ret, _, err := runtime.Call(addr, input, cfg)
+	return cfg.State, ret, err
+}
+
+func TestAccountIntrospection(t *testing.T) {
+	var (
+		addr       = common.HexToAddress("0xc0de")
+		helper     = common.HexToAddress("0xbeef")
+		helperCode = []byte{0x60, 0x01, 0x60, 0x00, 0x55, 0x00}
+		balance    = big.NewInt(0x1337)
+		statedb    = newState()
+	)
+	p := NewProgram()
+	p.ExtCodeSize(helper)
+	p.Push(0)
+	p.Op(ops.SSTORE)
+	p.Balance(helper)
+	p.Push(1)
+	p.Op(ops.SSTORE)
+	p.ExtCodeHash(helper)
+	p.Push(2)
+	p.Op(ops.SSTORE)
+	if exp, got := "61beef3b60005561beef31600155"+"61beef3f600255", p.Hex(); got != exp {
+		t.Fatalf("got %v expected %v", got, exp)
+	}
+	statedb.CreateAccount(helper)
+	statedb.SetCode(helper, helperCode)
+	statedb.SetBalance(helper, balance)
+	if _, _, err := runCode(addr, p.Bytecode(), nil, &runtime.Config{State: statedb}); err != nil {
+		t.Fatal(err)
+	}
+	if got, exp := statedb.GetState(addr, common.Hash{}), common.BigToHash(big.NewInt(int64(len(helperCode)))); got != exp {
+		t.Errorf("extcodesize: got %v expected %v", got, exp)
+	}
+	if got, exp := statedb.GetState(addr, common.BigToHash(big.NewInt(1))), common.BigToHash(balance); got != exp {
+		t.Errorf("balance: got %v expected %v", got, exp)
+	}
+	if got, exp := statedb.GetState(addr, common.BigToHash(big.NewInt(2))), crypto.Keccak256Hash(helperCode); got != exp {
+		t.Errorf("extcodehash: got %v expected %v", got, exp)
+	}
+}
+
+func TestChainIdSelfBalance(t *testing.T) {
+	var (
+		addr    = common.HexToAddress("0xc0de")
+		chainId = big.NewInt(1337)
+		balance = big.NewInt(0xbeef)
+		statedb = newState()
+	)
+	p := NewProgram()
+	p.ChainId()
+	p.Push(0)
+	p.Op(ops.SSTORE)
+	p.SelfBalance()
+	p.Push(1)
+	p.Op(ops.SSTORE)
+	if exp, got := "4660005547600155", p.Hex(); got != exp {
+		t.Fatalf("got %v expected %v", got, exp)
+	}
+	statedb.SetBalance(addr, balance)
+	cfg := &runtime.Config{
+		State:       statedb,
+		ChainConfig: &params.ChainConfig{ChainID: chainId},
+	}
+	if _, _, err := runCode(addr, p.Bytecode(), nil, cfg); err != nil {
+		t.Fatal(err)
+	}
+	if got, exp := statedb.GetState(addr, common.Hash{}), common.BigToHash(chainId); got != exp {
+		t.Errorf("chainid: got %v expected %v", got, exp)
+	}
+	if got, exp := statedb.GetState(addr, common.BigToHash(big.NewInt(1))), common.BigToHash(balance); got != exp {
+		t.Errorf("selfbalance: got %v expected %v", got, exp)
+	}
+}
+
+func TestTxContext(t *testing.T) {
+	var (
+		addr   = common.HexToAddress("0xc0de")
+		sender = common.HexToAddress("0xa94f5374fce5edbc8e2a8697c15331677e6ebf0b")
+	)
+	p := NewProgram()
+	p.Origin()
+	p.Push(0)
+	p.Op(ops.SSTORE)
+	p.Caller()
+	p.Push(1)
+	p.Op(ops.SSTORE)
+	p.CallValue()
+	p.GasPrice()
+	p.Gas()
+	if exp, got := "3260005533600155343a5a", p.Hex(); got != exp {
+		t.Fatalf("got %v expected %v", got, exp)
+	}
+	statedb, _, err := runCode(addr, p.Bytecode(), nil, &runtime.Config{Origin: sender})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := common.BytesToHash(sender.Bytes())
+	if got := statedb.GetState(addr, common.Hash{}); got != want {
+		t.Errorf("origin: got %v expected %v", got, want)
+	}
+	if got := statedb.GetState(addr, common.BigToHash(big.NewInt(1))); got != want {
+		t.Errorf("caller: got %v expected %v", got, want)
+	}
+}
+
+func TestReturnDataCopy(t *testing.T) {
+	var (
+		addr    = common.HexToAddress("0xc0de")
+		callee  = common.HexToAddress("0xbeef")
+		output  = common.HexToHash("0xdeadbeef00000000000000000000000000000000000000000000000000c0ffee")
+		statedb = newState()
+	)
+	c := NewProgram()
+	c.ReturnData(output.Bytes())
+	statedb.CreateAccount(callee)
+	statedb.SetCode(callee, c.Bytecode())
+
+	p := NewProgram()
+	p.Call(nil, callee, 0, 0, 0, 0, 0)
+	p.Op(ops.POP)
+	p.ReturnDataSize()
+	p.Push(0)
+	p.Op(ops.SSTORE)
+	p.ReturnDataCopy(0, 0, 32)
+	p.Return(0, 32)
+	_, ret, err := runCode(addr, p.Bytecode(), nil, &runtime.Config{State: statedb})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(ret, output.Bytes()) {
+		t.Errorf("got %x expected %x", ret, output)
+	}
+	if got, exp := statedb.GetState(addr, common.Hash{}), common.BigToHash(big.NewInt(32)); got != exp {
+		t.Errorf("returndatasize: got %v expected %v", got, exp)
+	}
+}
+
+func TestArithmetic(t *testing.T) {
+	// Executes the program, and returns the stack top
+	run := func(p *Program) *big.Int {
+		p.Push(0)
+		p.Op(ops.MSTORE)
+		p.Return(0, 32)
+		ret, _, err := runtime.Execute(p.Bytecode(), nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return new(big.Int).SetBytes(ret)
+	}
+	{ // (3 + 4) == 7
+		p := NewProgram()
+		p.Add(3, 4).Eq(7)
+		if exp, got := "6004600301600714", p.Hex(); got != exp {
+			t.Fatalf("got %v expected %v", got, exp)
+		}
+		if got := run(p); got.Cmp(big.NewInt(1)) != 0 {
+			t.Errorf("got %v expected %v", got, 1)
+		}
+	}
+	{ // Operand order: 3 > 14 / (10 - 3)
+		p := NewProgram()
+		p.Sub(10, 3).Div(14).Gt(3)
+		if got := run(p); got.Cmp(big.NewInt(1)) != 0 {
+			t.Errorf("got %v expected %v", got, 1)
+		}
+	}
+	{ // ^(0xf0 | 0x0f) & 0xff == 0, with xor, mul and iszero
+		p := NewProgram()
+		p.Or(0xf0, 0x0f).Not().And(0xff).IsZero().Mul(2).Xor(3)
+		if got := run(p); got.Cmp(big.NewInt(1)) != 0 {
+			t.Errorf("got %v expected %v", got, 1)
+		}
+	}
+}
+
+func TestFinish(t *testing.T) {
+	tests := []struct {
+		build    func(p *Program)
+		expected string
+	}{
+		{func(p *Program) {}, "00"},
+		{func(p *Program) { p.Return(0, 0) }, "60006000f3"},
+		{func(p *Program) { p.Invalid() }, "fe"},
+		{func(p *Program) { p.Stop() }, "00"},
+		// The last byte is zero, but it's not a STOP
+		{func(p *Program) { p.Push(0) }, "600000"},
+		{func(p *Program) { p.Sstore(0, 1) }, "600160005500"},
+		{func(p *Program) { p.Op(ops.SELFDESTRUCT) }, "ff"},
+		// A truncated push is not a SELFDESTRUCT
+		{func(p *Program) { p.Op(ops.PUSH2); p.Op(ops.SELFDESTRUCT) }, "61ff00"},
+	}
+	for i, tc := range tests {
+		p := NewProgram()
+		tc.build(p)
+		p.Finish()
+		if got := p.Hex(); got != tc.expected {
+			t.Errorf("test %d: got %v expected %v", i, got, tc.expected)
+		}
+	}
+}
+
+func TestPushAddressHash(t *testing.T) {
+	p := NewProgram()
+	p.PushAddress(common.HexToAddress("0xff0a"))
+	if got, exp := p.Hex(), "73"+"000000000000000000000000000000000000ff0a"; got != exp {
+		t.Errorf("got %v expected %v", got, exp)
+	}
+	p = NewProgram()
+	p.PushHash(common.HexToHash("0x01"))
+	if got, exp := p.Hex(), "7f"+"0000000000000000000000000000000000000000000000000000000000000001"; got != exp {
+		t.Errorf("got %v expected %v", got, exp)
+	}
+}
+
+func TestPushN(t *testing.T) {
+	for i, tt := range []struct {
+		n   int
+		val interface{}
+		exp string
+	}{
+		{4, 0x42, "63" + "00000042"},
+		{1, 0, "60" + "00"},
+		{2, []byte{0xff, 0xff}, "61" + "ffff"},
+		{32, uint64(1), "7f" + "0000000000000000000000000000000000000000000000000000000000000001"},
+	} {
+		if got := NewProgram().PushN(tt.n, tt.val).Hex(); got != tt.exp {
+			t.Errorf("test %d: got %v expected %v", i, got, tt.exp)
+		}
+	}
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected panic for value not fitting")
+		}
+	}()
+	NewProgram().PushN(1, 0x100)
+}
+
+func TestUndefined(t *testing.T) {
+	if got, exp := NewProgram().Undefined(0x0c).Hex(), "0c"; got != exp {
+		t.Errorf("got %v expected %v", got, exp)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected panic for defined opcode")
+		}
+	}()
+	NewProgram().Undefined(byte(ops.ADD))
+}
+
+func TestWithState(t *testing.T) {
+	stack := []*big.Int{big.NewInt(0xaa), big.NewInt(0xbb), big.NewInt(0xcc)}
+	mem := []byte{0x01, 0x02, 0x03}
+	// body stores the stack, top first, in slots 0-2, and the memory in slot 3
+	body := func(p *Program) *Program {
+		for i := range stack {
+			p.Push(i)
+			p.Op(ops.SSTORE)
+		}
+		p.Push(0)
+		p.Op(ops.MLOAD)
+		p.Push(3)
+		p.Op(ops.SSTORE)
+		return p
+	}
+	_, statedb, err := runtime.Execute(body(WithState(stack, mem)).Bytecode(), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	contract := common.BytesToAddress([]byte("contract"))
+	for i, want := range []common.Hash{
+		common.BigToHash(stack[0]),
+		common.BigToHash(stack[1]),
+		common.BigToHash(stack[2]),
+		common.BytesToHash(append(mem, make([]byte, 29)...)),
+	} {
+		if have := statedb.GetState(contract, common.BigToHash(big.NewInt(int64(i)))); have != want {
+			t.Errorf("slot %d: got %v expected %v", i, have, want)
+		}
+	}
+	// Nothing else is left on the stack
+	p := body(WithState(stack, mem))
+	p.Op(ops.POP)
+	var underflow *vm.ErrStackUnderflow
+	if _, _, err := runtime.Execute(p.Bytecode(), nil, nil); !errors.As(err, &underflow) {
+		t.Errorf("got %v expected stack underflow", err)
+	}
+}
+
+func TestPushSigned(t *testing.T) {
+	if got, exp := NewProgram().PushSigned(big.NewInt(-1)).Hex(), "7f"+strings.Repeat("ff", 32); got != exp {
+		t.Errorf("got %v expected %v", got, exp)
+	}
+	if got, exp := NewProgram().PushSigned(big.NewInt(5)).Hex(), "6005"; got != exp {
+		t.Errorf("got %v expected %v", got, exp)
+	}
+	// -1 < 0 when signed, but not when unsigned
+	p := NewProgram()
+	p.Push(0)
+	p.PushSigned(big.NewInt(-1))
+	p.Op(ops.SLT)
+	p.Push(0)
+	p.Op(ops.SSTORE)
+	p.Push(0)
+	p.PushSigned(big.NewInt(-1))
+	p.Op(ops.LT)
+	p.Push(1)
+	p.Op(ops.SSTORE)
+	addr := common.HexToAddress("0x5167")
+	statedb, _, err := runCode(addr, p.Bytecode(), nil, new(runtime.Config))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, exp := statedb.GetState(addr, common.Hash{}), common.BigToHash(big.NewInt(1)); got != exp {
+		t.Errorf("slt: got %v expected %v", got, exp)
+	}
+	if got, exp := statedb.GetState(addr, common.BigToHash(big.NewInt(1))), (common.Hash{}); got != exp {
+		t.Errorf("lt: got %v expected %v", got, exp)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected panic for value out of range")
+		}
+	}()
+	NewProgram().PushSigned(new(big.Int).Lsh(big.NewInt(-1), 256))
+}
+
+func TestPushLabelOffset(t *testing.T) {
+	p := NewProgram()
+	p.PushLabelOffset("target") // 0: resolved later
+	p.Push(0)
+	p.Op(ops.MSTORE)
+	p.Push(0)
+	p.Op(ops.MLOAD)
+	p.Op(ops.JUMP)
+	p.Invalid()
+	dest := p.NamedJumpdest("target")
+	p.Sstore(0, 1)
+	if have, want := p.Bytecode()[:3], []byte{byte(ops.PUSH2), 0, byte(dest)}; !bytes.Equal(have, want) {
+		t.Errorf("got %x expected %x", have, want)
+	}
+	addr := common.HexToAddress("0x1abe1")
+	statedb, _, err := runCode(addr, p.Bytecode(), nil, new(runtime.Config))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, exp := statedb.GetState(addr, common.Hash{}), common.BigToHash(big.NewInt(1)); got != exp {
+		t.Errorf("got %v expected %v", got, exp)
+	}
+}
+
+func TestSwitch(t *testing.T) {
+	sentinel := func(v int) func(*Program) {
+		return func(p *Program) { p.Sstore(0, v) }
+	}
+	cases := map[uint64]func(*Program){
+		1: sentinel(0x11),
+		2: sentinel(0x22),
+		3: sentinel(0x33),
+	}
+	// Selector from calldata
+	p := NewProgram()
+	p.Push(0)
+	p.Op(ops.CALLDATALOAD)
+	p.Switch(nil, cases, sentinel(0xdd))
+	p.Sstore(1, 1) // reached after every case
+	addr := common.HexToAddress("0x5717c4")
+	for selector, want := range map[int64]int64{1: 0x11, 2: 0x22, 3: 0x33, 4: 0xdd, 0: 0xdd} {
+		input := common.BigToHash(big.NewInt(selector)).Bytes()
+		statedb, _, err := runCode(addr, p.Bytecode(), input, new(runtime.Config))
+		if err != nil {
+			t.Fatalf("selector %d: %v", selector, err)
+		}
+		if have := statedb.GetState(addr, common.Hash{}); have != common.BigToHash(big.NewInt(want)) {
+			t.Errorf("selector %d: got %v expected %#x", selector, have, want)
+		}
+		if have := statedb.GetState(addr, common.BigToHash(big.NewInt(1))); have != common.BigToHash(big.NewInt(1)) {
+			t.Errorf("selector %d: end of switch not reached", selector)
+		}
+	}
+	// Constant selector
+	p = NewProgram()
+	p.Switch(2, cases, nil)
+	_, statedb, err := runtime.Execute(p.Bytecode(), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have := statedb.GetState(common.BytesToAddress([]byte("contract")), common.Hash{}); have != common.BigToHash(big.NewInt(0x22)) {
+		t.Errorf("got %v expected 0x22", have)
+	}
+	// Nested switches get distinct labels
+	p = NewProgram()
+	p.Switch(1, map[uint64]func(*Program){
+		1: func(p *Program) { p.Switch(2, cases, nil) },
+	}, nil)
+	if _, statedb, err = runtime.Execute(p.Bytecode(), nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if have := statedb.GetState(common.BytesToAddress([]byte("contract")), common.Hash{}); have != common.BigToHash(big.NewInt(0x22)) {
+		t.Errorf("got %v expected 0x22", have)
+	}
+	// Destinations beyond PUSH2 are not truncated
+	p = NewProgram()
+	p.Switch(1, map[uint64]func(*Program){
+		1: func(p *Program) { p.AddAll(make([]byte, 0x10000)) },
+	}, nil)
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected panic for a destination beyond PUSH2")
+		}
+	}()
+	p.Bytecode()
+}
+
+func TestZero(t *testing.T) {
+	for i, tt := range []struct {
+		fork string
+		exp  string
+	}{
+		{"Shanghai", "5f" + "6001" + "5f"},
+		{"Cancun", "5f" + "6001" + "5f"},
+		{"London", "58" + "6001" + "6000"},
+		{"", "58" + "6001" + "6000"},
+	} {
+		p := NewProgram()
+		if tt.fork != "" {
+			p.WithFork(tt.fork)
+		}
+		p.Zero()
+		p.Push(1)
+		p.Zero()
+		if got := p.Hex(); got != tt.exp {
+			t.Errorf("test %d: got %v expected %v", i, got, tt.exp)
+		}
+	}
+}
+
+func TestCurrentOffset(t *testing.T) {
+	p := NewProgram()
+	if have := p.CurrentOffset(); have != 0 {
+		t.Errorf("got %d expected 0", have)
+	}
+	p.Push(1)                        // 2 bytes
+	p.PushN(4, 0x42)                 // 5 bytes
+	p.Op(ops.ADD)                    // 1 byte
+	p.Call(nil, 0xff, 0, 0, 0, 0, 0) // 6 pushes, GAS, CALL: 14 bytes
+	if have, want := p.CurrentOffset(), 22; have != want {
+		t.Errorf("got %d expected %d", have, want)
+	}
+	if have, want := p.CurrentOffset(), len(p.Bytecode()); have != want {
+		t.Errorf("got %d expected %d", have, want)
+	}
+	// The offset is where the next op ends up
+	if have, want := uint64(p.CurrentOffset()), p.Jumpdest(); have != want {
+		t.Errorf("got %d expected %d", have, want)
+	}
+}
+
+func TestJumpLabels(t *testing.T) {
+	p := NewProgram()
+	p.Jump(4)              // 0: offset-based
+	p.Invalid()            // 3
+	p.Jumpdest()           // 4
+	p.JumpIf("end", 1)     // 5: label-based, forward
+	p.Invalid()            // 11
+	p.NamedJumpdest("end") // 12
+	p.Sstore(0, 1)         // 13
+	if exp, got := "600456fe5b600161000c57fe5b6001600055", p.Hex(); got != exp {
+		t.Fatalf("got %v expected %v", got, exp)
+	}
+	_, statedb, err := runtime.Execute(p.Bytecode(), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := common.BytesToAddress([]byte("contract"))
+	if got, exp := statedb.GetState(addr, common.Hash{}), common.BigToHash(big.NewInt(1)); got != exp {
+		t.Errorf("got %v expected %v", got, exp)
+	}
+	// Undefined labels are caught when building
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected panic on undefined label")
+		}
+	}()
+	p = NewProgram()
+	p.Jump("nowhere")
+	p.Bytecode()
+}
+
+func TestAssertEq(t *testing.T) {
+	addr := common.HexToAddress("0xa55e")
+	run := func(expected interface{}) (*state.StateDB, []byte, error) {
+		p := NewProgram()
+		p.Add(2, 2)
+		p.AssertEq(expected)
+		p.Keccak([]byte("hello"))
+		p.AssertEq(crypto.Keccak256([]byte("hello")))
+		p.Sstore(0, 1)
+		return runCode(addr, p.Bytecode(), nil, new(runtime.Config))
+	}
+	statedb, _, err := run(4)
+	if err != nil {
+		t.Fatalf("2+2==4: %v", err)
+	}
+	if got, exp := statedb.GetState(addr, common.Hash{}), common.BigToHash(big.NewInt(1)); got != exp {
+		t.Errorf("got %v expected %v", got, exp)
+	}
+	_, ret, err := run(5)
+	if !errors.Is(err, vm.ErrExecutionReverted) {
+		t.Fatalf("2+2==5: got %v expected %v", err, vm.ErrExecutionReverted)
+	}
+	if got, exp := ret, common.BigToHash(big.NewInt(AssertionFailed)).Bytes(); !bytes.Equal(got, exp) {
+		t.Errorf("got %x expected %x", got, exp)
+	}
+	// The jump to the continuation must fit in the PUSH2
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected panic on jumpdest beyond 0xffff")
+		}
+	}()
+	p := NewProgram()
+	p.AddAll(make([]byte, 0x10000))
+	p.AssertEq(4)
+}
+
+func TestCreateAndVerify(t *testing.T) {
+	blob := common.FromHex("0x6042600055") // sstore(0, 0x42)
+	addr := common.HexToAddress("0xde9107")
+	p := NewProgram().CreateAndVerify(blob)
+	p.Push(0)
+	p.Op(ops.SSTORE) // store the address of the new contract
+	statedb, _, err := runCode(addr, p.Bytecode(), nil, new(runtime.Config))
+	if err != nil {
+		t.Fatal(err)
+	}
+	created := common.BytesToAddress(statedb.GetState(addr, common.Hash{}).Bytes())
+	if have := statedb.GetCode(created); !bytes.Equal(have, blob) {
+		t.Errorf("got %x expected %x", have, blob)
+	}
+	// A tampered expectation reverts
+	p = NewProgram().createAndVerify(blob, crypto.Keccak256Hash([]byte("tampered")))
+	_, ret, err := runCode(addr, p.Bytecode(), nil, new(runtime.Config))
+	if !errors.Is(err, vm.ErrExecutionReverted) {
+		t.Fatalf("got %v expected %v", err, vm.ErrExecutionReverted)
+	}
+	if have, want := ret, common.BigToHash(big.NewInt(AssertionFailed)).Bytes(); !bytes.Equal(have, want) {
+		t.Errorf("got %x expected %x", have, want)
+	}
+}
+
+func TestCreate2Address(t *testing.T) {
+	var (
+		addr = common.HexToAddress("0xc2ea7e")
+		salt = common.HexToHash("0x5a17")
+	)
+	ctor := NewProgram()
+	ctor.ReturnData([]byte{byte(ops.STOP)})
+	initcode := ctor.Bytecode()
+	// Compute the address in slot 0, then deploy the contract
+	p := NewProgram()
+	p.Create2Address(salt.Bytes(), ctor)
+	p.Push(0)
+	p.Op(ops.SSTORE)
+	p.CreateAndCall(initcode, true, ops.STATICCALL) // salt 0
+	p.Create2Address(0, initcode)
+	p.Push(1)
+	p.Op(ops.SSTORE)
+	statedb, _, err := runCode(addr, p.Bytecode(), nil, new(runtime.Config))
+	if err != nil {
+		t.Fatal(err)
+	}
+	inithash := crypto.Keccak256(initcode)
+	for slot, want := range []common.Address{
+		crypto.CreateAddress2(addr, salt, inithash),
+		crypto.CreateAddress2(addr, common.Hash{}, inithash),
+	} {
+		have := common.BytesToAddress(statedb.GetState(addr, common.BigToHash(big.NewInt(int64(slot)))).Bytes())
+		if have != want {
+			t.Errorf("slot %d: got %v expected %v", slot, have, want)
+		}
+	}
+	// The contract was deployed at the computed address
+	if code := statedb.GetCode(crypto.CreateAddress2(addr, common.Hash{}, inithash)); len(code) != 1 {
+		t.Errorf("got code %x expected the deployed contract", code)
+	}
+}
+
+func TestCreate(t *testing.T) {
+	runtimeCode := make([]byte, 100)
+	for i := range runtimeCode {
+		runtimeCode[i] = byte(i)
+	}
+	// Smaller than pushing the runtime code
+	ctor := NewProgram()
+	ctor.ReturnData(runtimeCode)
+	if have, pushed := len(Initcode(runtimeCode)), ctor.Size(); have >= pushed {
+		t.Errorf("got initcode size %d expected less than %d", have, pushed)
+	}
+	if have, want := len(Initcode(runtimeCode)), len(runtimeCode)+12; have != want {
+		t.Errorf("got initcode size %d expected %d", have, want)
+	}
+	// The length needs a wider push
+	long := bytes.Repeat([]byte{0xaa}, 300)
+	if code := Initcode(long); !bytes.Equal(code[14:], long) || code[3] != byte(ops.PUSH1) || code[4] != 14 {
+		t.Errorf("got %x expected runtime code at offset 14", code[:14])
+	}
+	addr := common.HexToAddress("0xc4ea7e")
+	p := NewProgram().Create(runtimeCode)
+	p.Push(0)
+	p.Op(ops.SSTORE)
+	statedb, _, err := runCode(addr, p.Bytecode(), nil, new(runtime.Config))
+	if err != nil {
+		t.Fatal(err)
+	}
+	created := common.BytesToAddress(statedb.GetState(addr, common.Hash{}).Bytes())
+	if have := statedb.GetCode(created); !bytes.Equal(have, runtimeCode) {
+		t.Errorf("got %x expected %x", have, runtimeCode)
+	}
+}
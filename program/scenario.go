@@ -0,0 +1,148 @@
+// Copyright 2024 Martin Holst Swende
+// This file is part of the goevmlab library.
+//
+// The library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the goevmlab library. If not, see <http://www.gnu.org/licenses/>.
+
+package program
+
+import (
+	"math/big"
+
+	"github.com/rgeraldes24/goevmlab/ops"
+	"github.com/theQRL/go-zond/common"
+	"github.com/theQRL/go-zond/core"
+)
+
+// CallScenario describes a set of contracts, and the calls between them.
+// Example, a chain of calls a -> b -> c, where c stores a value:
+//
+//	s := NewCallScenario()
+//	s.Contract(a).Call(b)
+//	s.Contract(b).Call(c)
+//	s.Contract(c).Body(func(p *Program) { p.Sstore(0, 1) })
+//	alloc := s.Alloc()
+type CallScenario struct {
+	contracts map[common.Address]*ScenarioContract
+}
+
+// ScenarioContract is a contract within a CallScenario. Its code consists of
+// the calls, in the order they were added, followed by the body.
+type ScenarioContract struct {
+	calls   []scenarioCall
+	body    []func(p *Program)
+	balance *big.Int
+}
+
+type scenarioCall struct {
+	op     ops.OpCode
+	target common.Address
+}
+
+// NewCallScenario creates an empty scenario.
+func NewCallScenario() *CallScenario {
+	return &CallScenario{
+		contracts: make(map[common.Address]*ScenarioContract),
+	}
+}
+
+// Contract returns the contract at the given address, adding it to the
+// scenario if it did not already exist.
+func (s *CallScenario) Contract(addr common.Address) *ScenarioContract {
+	c, ok := s.contracts[addr]
+	if !ok {
+		c = &ScenarioContract{balance: new(big.Int)}
+		s.contracts[addr] = c
+	}
+	return c
+}
+
+// Call adds a CALL to the target, passing along all gas.
+func (c *ScenarioContract) Call(target common.Address) *ScenarioContract {
+	c.calls = append(c.calls, scenarioCall{ops.CALL, target})
+	return c
+}
+
+// CallCode adds a CALLCODE to the target, passing along all gas.
+func (c *ScenarioContract) CallCode(target common.Address) *ScenarioContract {
+	c.calls = append(c.calls, scenarioCall{ops.CALLCODE, target})
+	return c
+}
+
+// DelegateCall adds a DELEGATECALL to the target, passing along all gas.
+func (c *ScenarioContract) DelegateCall(target common.Address) *ScenarioContract {
+	c.calls = append(c.calls, scenarioCall{ops.DELEGATECALL, target})
+	return c
+}
+
+// StaticCall adds a STATICCALL to the target, passing along all gas.
+func (c *ScenarioContract) StaticCall(target common.Address) *ScenarioContract {
+	c.calls = append(c.calls, scenarioCall{ops.STATICCALL, target})
+	return c
+}
+
+// Body adds code, which is executed after the calls.
+func (c *ScenarioContract) Body(fn func(p *Program)) *ScenarioContract {
+	c.body = append(c.body, fn)
+	return c
+}
+
+// Balance sets the balance of the contract.
+func (c *ScenarioContract) Balance(balance *big.Int) *ScenarioContract {
+	c.balance = balance
+	return c
+}
+
+// Bytecode returns the code of the contract.
+func (c *ScenarioContract) Bytecode() []byte {
+	p := NewProgram()
+	for _, call := range c.calls {
+		switch call.op {
+		case ops.CALL:
+			p.Call(nil, call.target, 0, 0, 0, 0, 0)
+		case ops.CALLCODE:
+			p.CallCode(nil, call.target, 0, 0, 0, 0, 0)
+		case ops.DELEGATECALL:
+			p.DelegateCall(nil, call.target, 0, 0, 0, 0)
+		case ops.STATICCALL:
+			p.StaticCall(nil, call.target, 0, 0, 0, 0)
+		}
+		p.Op(ops.POP) // pop the retval
+	}
+	for _, fn := range c.body {
+		fn(p)
+	}
+	return p.Bytecode()
+}
+
+// Bytecode returns the code of the contract at the given address, or nil
+// if it is not part of the scenario.
+func (s *CallScenario) Bytecode(addr common.Address) []byte {
+	if c, ok := s.contracts[addr]; ok {
+		return c.Bytecode()
+	}
+	return nil
+}
+
+// Alloc returns the genesis alloc containing all the contracts.
+func (s *CallScenario) Alloc() core.GenesisAlloc {
+	alloc := make(core.GenesisAlloc)
+	for addr, c := range s.contracts {
+		alloc[addr] = core.GenesisAccount{
+			Code:    c.Bytecode(),
+			Balance: new(big.Int).Set(c.balance),
+			Storage: make(map[common.Hash]common.Hash),
+		}
+	}
+	return alloc
+}
@@ -0,0 +1,97 @@
+// Copyright 2024 Martin Holst Swende
+// This file is part of the goevmlab library.
+//
+// The library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the goevmlab library. If not, see <http://www.gnu.org/licenses/>.
+
+package program
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/theQRL/go-zond/common"
+	"github.com/theQRL/go-zond/core/rawdb"
+	"github.com/theQRL/go-zond/core/state"
+	"github.com/theQRL/go-zond/core/vm"
+	"github.com/theQRL/go-zond/core/vm/runtime"
+)
+
+// callTreeTracer records the call frames entered, and the max depth reached.
+type callTreeTracer struct {
+	depth    int
+	maxDepth int
+	targets  []common.Address
+}
+
+func (t *callTreeTracer) CaptureTxStart(uint64) {}
+func (t *callTreeTracer) CaptureTxEnd(uint64)   {}
+func (t *callTreeTracer) CaptureStart(env *vm.EVM, from, to common.Address, create bool, input []byte, gas uint64, value *big.Int) {
+	t.depth, t.maxDepth = 1, 1
+	t.targets = append(t.targets, to)
+}
+func (t *callTreeTracer) CaptureEnd([]byte, uint64, error) {}
+func (t *callTreeTracer) CaptureEnter(typ vm.OpCode, from, to common.Address, input []byte, gas uint64, value *big.Int) {
+	t.depth++
+	if t.depth > t.maxDepth {
+		t.maxDepth = t.depth
+	}
+	t.targets = append(t.targets, to)
+}
+func (t *callTreeTracer) CaptureExit([]byte, uint64, error) { t.depth-- }
+func (t *callTreeTracer) CaptureState(uint64, vm.OpCode, uint64, uint64, *vm.ScopeContext, []byte, int, error) {
+}
+func (t *callTreeTracer) CaptureFault(uint64, vm.OpCode, uint64, uint64, *vm.ScopeContext, int, error) {
+}
+
+func TestCallScenario(t *testing.T) {
+	var (
+		a = common.HexToAddress("0xaa")
+		b = common.HexToAddress("0xbb")
+		c = common.HexToAddress("0xcc")
+	)
+	s := NewCallScenario()
+	s.Contract(a).Call(b)
+	s.Contract(b).Call(c)
+	s.Contract(c).Body(func(p *Program) { p.Sstore(0, 1) })
+
+	alloc := s.Alloc()
+	if len(alloc) != 3 {
+		t.Fatalf("expected 3 accounts, got %d", len(alloc))
+	}
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	for addr, acc := range alloc {
+		statedb.CreateAccount(addr)
+		statedb.SetCode(addr, acc.Code)
+		statedb.SetBalance(addr, acc.Balance)
+	}
+	tracer := new(callTreeTracer)
+	cfg := &runtime.Config{
+		State:     statedb,
+		GasLimit:  1_000_000,
+		EVMConfig: vm.Config{Tracer: tracer},
+	}
+	if _, _, err := runtime.Call(a, nil, cfg); err != nil {
+		t.Fatal(err)
+	}
+	if tracer.maxDepth != 3 {
+		t.Errorf("wrong depth, got %d expected %d", tracer.maxDepth, 3)
+	}
+	if want := []common.Address{a, b, c}; len(tracer.targets) != len(want) ||
+		tracer.targets[0] != a || tracer.targets[1] != b || tracer.targets[2] != c {
+		t.Errorf("wrong call tree, got %v expected %v", tracer.targets, want)
+	}
+	if got := statedb.GetState(c, common.Hash{}); got != common.BigToHash(big.NewInt(1)) {
+		t.Errorf("innermost contract not executed, slot 0: %v", got)
+	}
+}
@@ -129,7 +129,22 @@ func (t *TraceLine) Source() string {
 	return string(x)
 }
 
+// CompareOptions configures how trace lines are compared.
+type CompareOptions struct {
+	// IgnoreGasCost makes steps which differ only in the reported gasCost
+	// match. The remaining gas is still compared, so a difference which
+	// affects consensus is flagged anyway.
+	IgnoreGasCost bool
+}
+
+// Equals reports whether the steps match, ignoring the gasCost.
 func (t *TraceLine) Equals(other *TraceLine) bool {
+	return t.Matches(other, CompareOptions{IgnoreGasCost: true})
+}
+
+// Matches reports whether the steps match, with respect to op, pc, depth,
+// remaining gas, stack and (unless ignored) gasCost.
+func (t *TraceLine) Matches(other *TraceLine, opts CompareOptions) bool {
 	if t.Op() != other.Op() ||
 		t.log.Pc != other.log.Pc ||
 		t.log.Depth != other.log.Depth ||
@@ -137,6 +152,9 @@ func (t *TraceLine) Equals(other *TraceLine) bool {
 		t.log.Gas != other.log.Gas {
 		return false
 	}
+	if !opts.IgnoreGasCost && t.log.GasCost != other.log.GasCost {
+		return false
+	}
 	// Also inspect stack
 	for i, elem := range t.log.Stack {
 		if elem != other.log.Stack[i] {
@@ -144,7 +162,21 @@ func (t *TraceLine) Equals(other *TraceLine) bool {
 		}
 	}
 	return true
-	//t.Get("depth") == other.Get("pc")
+}
+
+// FirstDiff returns the index of the first step where the traces differ, or
+// -1 if they match. If one trace is shorter, but otherwise matches, the index
+// is the length of the shorter trace.
+func FirstDiff(a, b *Traces, opts CompareOptions) int {
+	for i, step := range a.Ops {
+		if i >= len(b.Ops) || !step.Matches(b.Ops[i], opts) {
+			return i
+		}
+	}
+	if len(b.Ops) > len(a.Ops) {
+		return len(a.Ops)
+	}
+	return -1
 }
 
 func convertToStructLog(op map[string]interface{}) (*logger.StructLog, error) {
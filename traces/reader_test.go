@@ -217,3 +217,34 @@ func TestReEncodeTrace(t *testing.T){
 	}
 }
 */
+
+func TestCompareGasCost(t *testing.T) {
+	read := func(gas, gasCost int) *Traces {
+		var b strings.Builder
+		fmt.Fprintf(&b, `{"pc":0,"op":96,"gas":"0x%x","gasCost":"0x3","depth":1,"stack":[],"opName":"PUSH1"}`+"\n", gas)
+		fmt.Fprintf(&b, `{"pc":2,"op":84,"gas":"0x%x","gasCost":"0x%x","depth":1,"stack":["0x1"],"opName":"SLOAD"}`+"\n", gas-3, gasCost)
+		tr, err := readJsonLines(strings.NewReader(b.String()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tr
+	}
+	var (
+		a       = read(1000, 2100)
+		cost    = read(1000, 100)
+		gas     = read(999, 2100)
+		lenient = CompareOptions{IgnoreGasCost: true}
+	)
+	if have := FirstDiff(a, cost, lenient); have != -1 {
+		t.Errorf("gasCost-only difference: got diff at %d expected match", have)
+	}
+	if have := FirstDiff(a, cost, CompareOptions{}); have != 1 {
+		t.Errorf("gasCost-only difference, strict: got %d expected 1", have)
+	}
+	if have := FirstDiff(a, gas, lenient); have != 0 {
+		t.Errorf("gas difference: got %d expected 0", have)
+	}
+	if !a.Ops[1].Equals(cost.Ops[1]) {
+		t.Errorf("Equals should ignore gasCost")
+	}
+}
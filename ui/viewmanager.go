@@ -46,7 +46,9 @@ type viewManager struct {
 	config *Config
 }
 
-func NewDiffviewManager(traces []*traces.Traces) {
+// NewDiffviewManager shows the traces side by side, with the steps of the later
+// traces which differ from the first one highlighted.
+func NewDiffviewManager(traces []*traces.Traces, opts traces.CompareOptions) {
 	root := tview.NewGrid().
 		SetRows(10, 0, 10, 10).
 		//SetColumns(10,0,0,0).
@@ -95,7 +97,7 @@ func NewDiffviewManager(traces []*traces.Traces) {
 				break
 			}
 			other := ops[i]
-			if !step.Equals(other) {
+			if !step.Matches(other, opts) {
 				manager.traceView.GetCell(i+1, 0).SetBackgroundColor(tcell.ColorRed)
 			}
 		}